@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/delonce/apishop/internal/app"
 	"github.com/delonce/apishop/internal/config"
@@ -16,8 +19,38 @@ func main() {
 	mainConfig := loadConfig(logger)
 	logger.Info("Config had loaded")
 
-	mainApp := app.NewApp(context.Background(), logger, mainConfig)
-	mainApp.StartConsumerApplication()
+	// Application stops on Ctrl+C and on termination by orchestrator
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	mainApp := app.NewApp(ctx, logger, mainConfig)
+
+	var err error
+
+	command := ""
+
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "migrate":
+		// 'apishop migrate ...' only changes database schema, see app.Migrate
+		err = mainApp.Migrate(os.Args[2:])
+	case "keys":
+		// 'apishop keys ...' manages API keys, new key is printed to stdout, see app.Keys
+		err = mainApp.Keys(os.Args[2:], os.Stdout)
+	default:
+		err = mainApp.StartConsumerApplication()
+	}
+
+	stop()
+
+	if err != nil {
+		logger.Errorf("Application stopped with error: %v", err)
+		os.Exit(1)
+	}
+
+	logger.Info("Application stopped")
 }
 
 func loadConfig(logger *logging.Logger) *config.Config {
@@ -2,9 +2,15 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/delonce/apishop/internal/config"
 	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/database/cache"
+	"github.com/delonce/apishop/internal/database/memory"
 	pgmanager "github.com/delonce/apishop/internal/database/postgres"
 	"github.com/delonce/apishop/internal/delivery"
 	"github.com/delonce/apishop/internal/server"
@@ -12,85 +18,338 @@ import (
 	"github.com/delonce/apishop/internal/service/subject"
 	postgresdb "github.com/delonce/apishop/pkg/dbclient"
 	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/metrics"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
 )
 
 type ConsumerApp struct {
-	ctx       context.Context
+	// Done when application should stop (e.g. SIGTERM)
+	stopCtx context.Context
+	// Context of all application's work, it is cancelled only after launched queries are drained
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	logger    *logging.Logger
 	appConfig *config.Config
+	pgxPool   *pgxpool.Pool
+	// Metrics of all parts of application, served on /metrics
+	registry *metrics.Registry
 }
 
 func NewApp(ctx context.Context, appLog *logging.Logger, cfg *config.Config) *ConsumerApp {
+	// Work context doesn't inherit stop signal, otherwise queries would be cancelled before draining
+	workCtx, cancel := context.WithCancel(context.Background())
+
 	return &ConsumerApp{
-		ctx:       ctx,
+		stopCtx:   ctx,
+		ctx:       workCtx,
+		cancel:    cancel,
 		logger:    appLog,
 		appConfig: cfg,
+		registry:  metrics.NewRegistry(),
 	}
 }
 
-func (app *ConsumerApp) StartConsumerApplication() {
-	prchSubj := app.createPurchaseSubject()
+func (app *ConsumerApp) StartConsumerApplication() error {
+	// Stops everything that was started with application context
+	defer app.cancel()
+
+	// Get storage for subs and handlers
+	prodDB, err := app.initStorage()
+
+	if err != nil {
+		return err
+	}
+
+	defer app.closeStorage()
+
+	// Otherwise schema is migrated by 'migrate' command before deploy
+	if app.appConfig.MigrateOnStart && app.pgxPool != nil {
+		if err := app.migrateOnStart(); err != nil {
+			return fmt.Errorf("migrations: %w", err)
+		}
+	}
+
+	// In-memory storage starts without keys and 'keys' command can't reach it, so admin key is made here
+	if app.appConfig.Storage == config.MemoryStorage {
+		if err := app.writeMemoryAdminKey(prodDB); err != nil {
+			return fmt.Errorf("admin key: %w", err)
+		}
+	}
+
+	// Old idempotency keys and sessions are useless after TTL
+	go app.cleanExpired(prodDB)
+
+	prchSubj, err := app.createPurchaseSubject(prodDB)
+
+	if err != nil {
+		return fmt.Errorf("purchase subject: %w", err)
+	}
+
 	app.logger.Info("Purchase subject has created")
-	router := app.createHTTPRouter(prchSubj)
-	app.startHTTPServer(router)
+
+	quoteSubj, err := app.createQuoteSubject(prodDB)
+
+	if err != nil {
+		return fmt.Errorf("quote subject: %w", err)
+	}
+
+	router, err := app.createHTTPRouter(prchSubj, quoteSubj, prodDB)
+
+	if err != nil {
+		return err
+	}
+
+	return app.startHTTPServer(router, prchSubj, quoteSubj)
 }
 
-func (app *ConsumerApp) startHTTPServer(router *httprouter.Router) {
+func (app *ConsumerApp) writeMemoryAdminKey(prodDB database.ProductDB) error {
+	// Key is shown only in file that is asked for in config, never in log
+	if app.appConfig.AdminKeyFile == "" {
+		app.logger.Warn("In-memory storage has no admin key, set ADMIN_KEY_FILE to get one")
+		return nil
+	}
+
+	rawKey, err := createAPIKey(prodDB, "memory-admin", database.RoleAdmin, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	// Only owner of process can read it
+	if err = os.WriteFile(app.appConfig.AdminKeyFile, []byte(rawKey+"\n"), 0600); err != nil {
+		return err
+	}
+
+	app.logger.Warnf("API key with role admin for in-memory storage is written to %s", app.appConfig.AdminKeyFile)
+
+	return nil
+}
+
+func (app *ConsumerApp) startHTTPServer(router *httprouter.Router, subjects ...subject.Subject) error {
 	app.logger.Info("Getting http server...")
 	appServer := server.GetNewServer(app.appConfig.Host, app.appConfig.Port, router)
 	app.logger.Infof("Listening on http://%s:%d", app.appConfig.Host, app.appConfig.Port)
 
-	appServer.ListenAndServe()
+	serverErr := make(chan error, 1)
+
+	go func() {
+		serverErr <- appServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		// Server hasn't started (e.g. port is busy) or has died, nothing to drain
+		return fmt.Errorf("http server stopped: %w", err)
+	case <-app.stopCtx.Done():
+		app.logger.Info("Stop signal received, shutting down...")
+	}
+
+	return app.shutdown(appServer, subjects)
 }
 
-func (app *ConsumerApp) createHTTPRouter(subj subject.Subject) *httprouter.Router {
-	transportManager := delivery.NewDeliveryManager(app.logger, subj)
+func (app *ConsumerApp) shutdown(appServer *http.Server, subjects []subject.Subject) error {
+	// All steps of shutdown share one deadline
+	ctx, cancel := context.WithTimeout(context.Background(), app.appConfig.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting queries and wait for active handlers
+	if err := appServer.Shutdown(ctx); err != nil {
+		// Handlers that didn't manage are cancelled with application context
+		app.logger.Errorf("Error shutting down http server, %v", err)
+		return fmt.Errorf("http server shutdown: %w", err)
+	}
+
+	// Subjects don't take new queries and wait for launched ones
+	for _, subj := range subjects {
+		if err := subj.Drain(ctx); err != nil {
+			app.logger.Errorf("Error draining subject, %v", err)
+			return fmt.Errorf("subject drain: %w", err)
+		}
+	}
+
+	app.logger.Info("All queries are done")
+
+	return nil
+}
+
+func (app *ConsumerApp) createHTTPRouter(subj, quoteSubj subject.Subject, prodDB database.ProductDB) (*httprouter.Router, error) {
+	// Wrong limits stop application, otherwise routes would stay unprotected
+	rateLimits, err := delivery.ParseRateLimits(app.appConfig.RateLimits)
+
+	if err != nil {
+		return nil, fmt.Errorf("rate limits: %w", err)
+	}
+
+	// Products are written only with categories that pricer can tax
+	taxes, err := app.taxPolicy()
+
+	if err != nil {
+		return nil, err
+	}
+
+	transportManager := delivery.NewDeliveryManager(app.logger, app.appConfig, subj, quoteSubj, prodDB, app.registry, rateLimits,
+		taxes.Rates)
 
 	// Before returning router we need to register urls
 	transportManager.Register()
 
-	return transportManager.GetRouter()
+	return transportManager.GetRouter(), nil
 }
 
-func (app *ConsumerApp) createPurchaseSubject() subject.Subject {
+func (app *ConsumerApp) createPurchaseSubject(prodDB database.ProductDB) (subject.Subject, error) {
 	// Creating a service that provides customer data
 
 	app.logger.Info("Creating purchase subject")
 
-	// Channel for subscribers (see service/consumer)
-	clientReply := make(chan consumer.ClientCheck)
-	jsonChannel := make(chan []byte)
+	taxes, err := app.taxPolicy()
 
-	// Get pool of connections for subs
-	prodDB := app.initDBPoolConnection()
+	if err != nil {
+		return nil, err
+	}
 
 	// Subject that joins created subscribers
-	purchSub := subject.GetPurchaseSubj(app.ctx, app.logger, jsonChannel)
+	// Subscribers exchange results through state of every query, not through shared channels
+	purchSub := subject.GetPurchaseSubj(app.ctx, app.logger, app.registry)
 
 	// Sub creating check of purchase
-	sender := consumer.GetCheckCreator("Check Creator", prodDB, app.logger, clientReply)
+	sender := consumer.GetCheckCreator("Check Creator", prodDB, app.logger, app.registry)
+	// Sub reading all products of order at once
+	catalogue := consumer.GetCatalogue("Catalogue", prodDB, app.logger)
+	// Sub counting costs with discounts and taxes
+	pricer := consumer.GetPricer("Pricer", prodDB, taxes, app.logger)
 	// Validating sub
-	validator := consumer.GetValidateSubscriber("Validator", prodDB, app.logger, clientReply)
+	validator := consumer.GetValidateSubscriber("Validator", app.logger)
 	// Reply client sub
-	replier := consumer.GetReplier("Replier", prodDB, app.logger, clientReply, jsonChannel)
+	replier := consumer.GetReplier("Replier", app.logger)
 
 	// Process of subscribing
-	purchSub.Subscribe(sender)
-	purchSub.Subscribe(validator)
-	purchSub.Subscribe(replier)
+	// Order doesn't matter, subject launches subscribers by their declared dependencies
+	for _, sub := range []consumer.Consumer{catalogue, pricer, sender, validator, replier} {
+		if err := purchSub.Subscribe(sub); err != nil {
+			return nil, err
+		}
+	}
+
+	return purchSub, nil
+}
+
+func (app *ConsumerApp) createQuoteSubject(prodDB database.ProductDB) (subject.Subject, error) {
+	// The same pricing as purchase, but without Check Creator nothing is written
+	app.logger.Info("Creating quote subject")
+
+	taxes, err := app.taxPolicy()
+
+	if err != nil {
+		return nil, err
+	}
+
+	quoteSub := subject.GetPurchaseSubj(app.ctx, app.logger, app.registry)
+
+	// Names differ from purchase subscribers, so their metrics aren't mixed
+	catalogue := consumer.GetCatalogue("Quote Catalogue", prodDB, app.logger)
+	pricer := consumer.GetPricer("Quote Pricer", prodDB, taxes, app.logger)
+	validator := consumer.GetValidateSubscriber("Quote Validator", app.logger)
+	quoter := consumer.GetQuoter("Quoter", app.logger)
+
+	for _, sub := range []consumer.Consumer{catalogue, pricer, validator, quoter} {
+		if err := quoteSub.Subscribe(sub); err != nil {
+			return nil, err
+		}
+	}
+
+	return quoteSub, nil
+}
+
+func (app *ConsumerApp) taxPolicy() (consumer.TaxPolicy, error) {
+	// Wrong rates stop application, otherwise checks would have wrong taxes
+	rates, err := consumer.ParseTaxRates(app.appConfig.TaxRates)
+
+	if err != nil {
+		return consumer.TaxPolicy{}, fmt.Errorf("tax rates: %w", err)
+	}
 
-	validator.SetSubAmount(purchSub.GetSubAmount() - 1)
+	return consumer.TaxPolicy{Rates: rates, Inclusive: app.appConfig.PricesIncludeTax}, nil
+}
+
+func (app *ConsumerApp) initStorage() (database.ProductDB, error) {
+	prodDB, err := app.openStorage()
+
+	if err != nil || app.appConfig.ProductCacheSize <= 0 {
+		return prodDB, err
+	}
 
-	return purchSub
+	return cache.NewCachedStorage(prodDB, app.appConfig.ProductCacheSize, app.appConfig.ProductCacheTTL, app.registry), nil
+}
+
+func (app *ConsumerApp) openStorage() (database.ProductDB, error) {
+	switch app.appConfig.Storage {
+	case config.PostgresStorage:
+		return app.initDBPoolConnection(), nil
+	case config.MemoryStorage:
+		// Database isn't needed, but everything is lost on restart
+		app.logger.Warn("Using in-memory storage, data won't be saved")
+		return memory.NewStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q, use %s or %s", app.appConfig.Storage,
+			config.PostgresStorage, config.MemoryStorage)
+	}
+}
+
+func (app *ConsumerApp) closeStorage() {
+	// In-memory storage has nothing to close
+	if app.pgxPool != nil {
+		app.closeDBPoolConnection()
+	}
 }
 
 func (app *ConsumerApp) initDBPoolConnection() database.ProductDB {
 	// Using pgxpool instead of default sql package
-	pgxPool := postgresdb.NewPostgresConnection(app.logger, app.appConfig.DBLogin, app.appConfig.DBPasswd,
+	app.pgxPool = postgresdb.NewPostgresConnection(app.logger, app.appConfig.DBLogin, app.appConfig.DBPasswd,
 		app.appConfig.DBAddr, app.appConfig.DBPort, app.appConfig.DBName)
+	postgresdb.RegisterPoolMetrics(app.registry, app.pgxPool)
 
-	storage := pgmanager.NewStorage(app.ctx, pgxPool, app.logger)
+	storage := pgmanager.NewStorage(app.ctx, app.pgxPool, app.logger)
 
 	return storage
 }
+
+func (app *ConsumerApp) closeDBPoolConnection() {
+	// Queries of application context have to be cancelled before, otherwise Close waits for them
+	app.cancel()
+	app.pgxPool.Close()
+
+	app.logger.Info("Connection to postgreSQL is closed")
+}
+
+func (app *ConsumerApp) cleanExpired(prodDB database.ProductDB) {
+	// Deletes expired keys and sessions every hour until application context is done
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := prodDB.DeleteExpiredIdempotencyKeys(time.Now().Add(-app.appConfig.IdempotencyTTL))
+
+			if err != nil {
+				app.logger.Errorf("Error cleaning idempotency keys, %v", err)
+				continue
+			}
+
+			app.logger.Infof("Deleted %d expired idempotency keys", deleted)
+
+			deleted, err = prodDB.DeleteExpiredSessions(time.Now())
+
+			if err != nil {
+				app.logger.Errorf("Error cleaning sessions, %v", err)
+				continue
+			}
+
+			app.logger.Infof("Deleted %d expired sessions", deleted)
+		}
+	}
+}
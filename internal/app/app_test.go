@@ -0,0 +1,470 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/config"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var checkDate = regexp.MustCompile(`"date":"[^"]+"`)
+
+func newTestRouter(t *testing.T) (*httprouter.Router, database.ProductDB) {
+	// The whole application with in-memory storage, without database and network
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		Storage:        config.MemoryStorage,
+		IdempotencyTTL: time.Hour,
+		TaxRates:       "standard=0,food=10",
+		SessionTTL:     time.Hour,
+		PasswordCost:   4, // bcrypt.MinCost, fast enough for tests
+		// Reserved stock has to be seen through cache
+		ProductCacheSize: 10,
+		ProductCacheTTL:  time.Hour,
+	}
+
+	app := NewApp(context.Background(), &logging.Logger{Entry: logrus.NewEntry(logger)}, cfg)
+	t.Cleanup(app.cancel)
+
+	prodDB, err := app.initStorage()
+	assert.Nil(t, err)
+
+	subj, err := app.createPurchaseSubject(prodDB)
+	assert.Nil(t, err)
+
+	quoteSubj, err := app.createQuoteSubject(prodDB)
+	assert.Nil(t, err)
+
+	router, err := app.createHTTPRouter(subj, quoteSubj, prodDB)
+	assert.Nil(t, err)
+
+	return router, prodDB
+}
+
+func newTestKey(t *testing.T, prodDB database.ProductDB, role database.Role) string {
+	rawKey, err := createAPIKey(prodDB, "test-"+string(role), role, time.Now())
+	assert.Nil(t, err)
+
+	return rawKey
+}
+
+func TestPurchaseFlow(t *testing.T) {
+	router, prodDB := newTestRouter(t)
+	// Back-office routes are used too
+	adminKey := newTestKey(t, prodDB, database.RoleAdmin)
+
+	steps := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+		expectedReqBody    string
+	}{
+		{
+			name:               "Create apple",
+			method:             "POST",
+			path:               "/products",
+			body:               `{"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":10}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":1,"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":10,"tax_category":"standard"}`,
+		},
+
+		{
+			name:               "Create melon",
+			method:             "POST",
+			path:               "/products",
+			body:               `{"name":"melon","cost":{"amount":300,"currency":"USD"},"amount":1}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":2,"name":"melon","cost":{"amount":300,"currency":"USD"},"amount":1,"tax_category":"standard"}`,
+		},
+
+		{
+			name:               "Quote doesn't buy",
+			method:             "POST",
+			path:               "/quote",
+			body:               `{"order":[{"product":"apple","amount":4},{"product":"melon","amount":2}]}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"subtotal":{"amount":1400,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":1400,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":800,"currency":"USD"},"req_amount":4,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"melon","pos_cost":{"amount":600,"currency":"USD"},"req_amount":2,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}],"is_confirmed":false,"error":["product: melon, requested_amount: 2, actually amount: 1"],"availability":[{"product":"apple","req_amount":4,"available":10,"in_stock":true},{"product":"melon","req_amount":2,"available":1,"in_stock":false}]}`,
+		},
+
+		{
+			name:               "Buy",
+			method:             "POST",
+			path:               "/",
+			body:               `{"order":[{"product":"apple","amount":4},{"product":"melon","amount":1}]}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":1,"subtotal":{"amount":1100,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":1100,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":800,"currency":"USD"},"req_amount":4,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"melon","pos_cost":{"amount":300,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}],"is_confirmed":true,"error":[]}`,
+		},
+
+		{
+			name:               "Body doesn't match OpenAPI document",
+			method:             "POST",
+			path:               "/",
+			body:               `{"order":[{"product":"apple","amount":-4}]}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"body doesn't match schema, see errors","code":"validation_failed","errors":[{"index":0,"field":"amount","reason":"should be at least 0"}]}`,
+		},
+
+		{
+			name:               "Stock is reserved",
+			method:             "GET",
+			path:               "/products/melon",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"id":2,"name":"melon","cost":{"amount":300,"currency":"USD"},"amount":0,"tax_category":"standard"}`,
+		},
+
+		{
+			name:               "Create kiwi in euros",
+			method:             "POST",
+			path:               "/products",
+			body:               `{"name":"kiwi","cost":{"amount":150,"currency":"EUR"},"amount":5}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":3,"name":"kiwi","cost":{"amount":150,"currency":"EUR"},"amount":5,"tax_category":"standard"}`,
+		},
+
+		{
+			name:               "Order can't mix currencies",
+			method:             "POST",
+			path:               "/",
+			body:               `{"order":[{"product":"apple","amount":1},{"product":"kiwi","amount":1}]}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"order has problems, see errors","code":"validation_failed","errors":[{"field":"product","reason":"product kiwi is sold in EUR, but order is in USD, buy it separately"}]}`,
+		},
+
+		{
+			name:               "Not enough melons",
+			method:             "POST",
+			path:               "/",
+			body:               `{"order":[{"product":"melon","amount":1}]}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"subtotal":{"amount":300,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":300,"currency":"USD"},"positions":[{"product":"melon","pos_cost":{"amount":300,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}],"is_confirmed":false,"error":["product: melon, requested_amount: 1, actually amount: 0"]}`,
+		},
+
+		{
+			name:               "Saved check",
+			method:             "GET",
+			path:               "/checks/1",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":1,"date":"DATE","is_confirmed":true,"subtotal":{"amount":1100,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":1100,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":800,"currency":"USD"},"req_amount":4,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"melon","pos_cost":{"amount":300,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}]}`,
+		},
+
+		{
+			name:               "Create bread with reduced tax",
+			method:             "POST",
+			path:               "/products",
+			body:               `{"name":"bread","cost":{"amount":250,"currency":"USD"},"amount":5,"tax_category":"food"}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":4,"name":"bread","cost":{"amount":250,"currency":"USD"},"amount":5,"tax_category":"food"}`,
+		},
+
+		{
+			name:               "Tax is added to cost",
+			method:             "POST",
+			path:               "/",
+			body:               `{"order":[{"product":"apple","amount":1},{"product":"bread","amount":2}]}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":2,"subtotal":{"amount":700,"currency":"USD"},"tax":{"amount":50,"currency":"USD"},"total_cost":{"amount":750,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":200,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"bread","pos_cost":{"amount":500,"currency":"USD"},"req_amount":2,"tax_category":"food","tax_rate":1000,"tax":{"amount":50,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}],"is_confirmed":true,"error":[]}`,
+		},
+
+		{
+			name:               "Saved check keeps tax",
+			method:             "GET",
+			path:               "/checks/2",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":2,"date":"DATE","is_confirmed":true,"subtotal":{"amount":700,"currency":"USD"},"tax":{"amount":50,"currency":"USD"},"total_cost":{"amount":750,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":200,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"bread","pos_cost":{"amount":500,"currency":"USD"},"req_amount":2,"tax_category":"food","tax_rate":1000,"tax":{"amount":50,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}]}`,
+		},
+
+		{
+			name:               "Rename and reprice bread",
+			method:             "PATCH",
+			path:               "/products/bread",
+			body:               `{"name":"rye bread","cost":{"amount":400,"currency":"USD"}}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"id":4,"name":"rye bread","cost":{"amount":400,"currency":"USD"},"amount":3,"tax_category":"food"}`,
+		},
+
+		{
+			name:               "Saved check keeps price and name of purchase",
+			method:             "GET",
+			path:               "/checks/2",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":2,"date":"DATE","is_confirmed":true,"subtotal":{"amount":700,"currency":"USD"},"tax":{"amount":50,"currency":"USD"},"total_cost":{"amount":750,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":200,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"bread","pos_cost":{"amount":500,"currency":"USD"},"req_amount":2,"tax_category":"food","tax_rate":1000,"tax":{"amount":50,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}]}`,
+		},
+
+		{
+			name:               "Product from check can't be deleted",
+			method:             "DELETE",
+			path:               "/products/apple",
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"product is used in checks, name: apple","code":"product_in_use"}`,
+		},
+
+		{
+			name:               "Create promo code",
+			method:             "POST",
+			path:               "/discounts",
+			body:               `{"promo_code":"SALE","kind":"percent","product":"apple","percent":10}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":1,"promo_code":"SALE","kind":"percent","product":"apple","percent":10}`,
+		},
+
+		{
+			name:               "Quote with promo code",
+			method:             "POST",
+			path:               "/quote",
+			body:               `{"order":[{"product":"apple","amount":3}],"promo_code":"SALE"}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"subtotal":{"amount":540,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":540,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":600,"currency":"USD"},"req_amount":3,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":60,"currency":"USD"},"discounts":[{"discount_id":1,"promo_code":"SALE","description":"10% off","amount":{"amount":60,"currency":"USD"}}]}],"is_confirmed":true,"error":[],"availability":[{"product":"apple","req_amount":3,"available":5,"in_stock":true}]}`,
+		},
+
+		{
+			name:               "Buy with promo code",
+			method:             "POST",
+			path:               "/",
+			body:               `{"order":[{"product":"apple","amount":3}],"promo_code":"SALE"}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":3,"subtotal":{"amount":540,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":540,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":600,"currency":"USD"},"req_amount":3,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":60,"currency":"USD"},"discounts":[{"discount_id":1,"promo_code":"SALE","description":"10% off","amount":{"amount":60,"currency":"USD"}}]}],"is_confirmed":true,"error":[]}`,
+		},
+
+		{
+			name:               "Saved check keeps discount of position",
+			method:             "GET",
+			path:               "/checks/3",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"check_id":3,"date":"DATE","is_confirmed":true,"subtotal":{"amount":540,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":540,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":600,"currency":"USD"},"req_amount":3,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":60,"currency":"USD"}}]}`,
+		},
+
+		{
+			name:               "Unknown promo code",
+			method:             "POST",
+			path:               "/quote",
+			body:               `{"order":[{"product":"apple","amount":3}],"promo_code":"NOPE"}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"order has problems, see errors","code":"validation_failed","errors":[{"field":"promo_code","reason":"promo code NOPE doesn't exist or has expired"}]}`,
+		},
+	}
+
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(step.method, step.path, bytes.NewBufferString(step.body))
+		req.Header.Set("X-API-Key", adminKey)
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, step.expectedStatusCode, w.Code, step.name)
+		// Date of check is generated at purchase
+		body := checkDate.ReplaceAllString(w.Body.String(), `"date":"DATE"`)
+		assert.Equal(t, step.expectedReqBody, body, step.name)
+	}
+}
+
+func TestCustomerFlow(t *testing.T) {
+	router, prodDB := newTestRouter(t)
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/products", bytes.NewBufferString(`{"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":10}`))
+	req.Header.Set("X-API-Key", newTestKey(t, prodDB, database.RoleOperator))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve("POST", "/customers", "", `{"email":"ann@example.com","password":"correct horse"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"id":1,"email":"ann@example.com"}`, w.Body.String())
+
+	w = serve("POST", "/customers", "", `{"email":"Ann@Example.com","password":"another horse"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve("POST", "/sessions", "", `{"email":"ann@example.com","password":"wrong horse"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve("POST", "/sessions", "", `{"email":"ann@example.com","password":"correct horse"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	session := struct {
+		Token string `json:"token"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &session))
+
+	// One anonymous purchase and one of customer
+	w = serve("POST", "/", "", `{"order":[{"product":"apple","amount":1}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve("POST", "/", session.Token, `{"order":[{"product":"apple","amount":2}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"check_id":2`)
+
+	// Customer sees only own check
+	w = serve("GET", "/me/checks", session.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"checks":[{"check_id":2,"date":"DATE","is_confirmed":true,"customer_id":1,"subtotal":{"amount":400,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":400,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":400,"currency":"USD"},"req_amount":2,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}]}],"page":1,"per_page":20,"has_more":false}`,
+		checkDate.ReplaceAllString(w.Body.String(), `"date":"DATE"`))
+
+	w = serve("DELETE", "/sessions/current", session.Token, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Token doesn't work after logout
+	w = serve("GET", "/me/checks", session.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve("POST", "/", session.Token, `{"order":[{"product":"apple","amount":1}]}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAPIKeyRoles(t *testing.T) {
+	router, prodDB := newTestRouter(t)
+
+	// Keys are made like by 'keys create' command
+	var out bytes.Buffer
+	assert.Nil(t, manageKeys(prodDB, []string{"create", "storefront", "customer"}, &out))
+	customerKey := strings.TrimSpace(out.String())
+	assert.True(t, strings.HasPrefix(customerKey, apiKeyPrefix))
+
+	operatorKey := newTestKey(t, prodDB, database.RoleOperator)
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	apple := `{"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":10}`
+
+	w := serve("POST", "/products", "", apple)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"critical_error":"send API key with role operator in header 'X-API-Key'","code":"unauthorized"}`, w.Body.String())
+
+	w = serve("POST", "/products", "apishop_unknown", apple)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve("POST", "/products", customerKey, apple)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, `{"critical_error":"API key with role customer can't do it, role operator is needed","code":"forbidden"}`, w.Body.String())
+
+	w = serve("POST", "/products", operatorKey, apple)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve("DELETE", "/products/apple", operatorKey, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Catalogue reads and purchase stay public for storefronts
+	w = serve("GET", "/products", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve("GET", "/products/apple", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve("POST", "/", "", `{"order":[{"product":"apple","amount":1}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	out.Reset()
+	assert.Nil(t, manageKeys(prodDB, []string{"revoke", "2"}, &out))
+	assert.Equal(t, "API key 2 is revoked\n", out.String())
+	assert.True(t, errors.Is(manageKeys(prodDB, []string{"revoke", "2"}, &out), database.ErrAPIKeyNotFound))
+
+	// Revoked key stops working at once
+	w = serve("PATCH", "/products/apple", operatorKey, `{"amount":20}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	out.Reset()
+	assert.Nil(t, manageKeys(prodDB, []string{"list"}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[1], customerKey[:apiKeyShownLength]+"...")
+	assert.NotContains(t, out.String(), customerKey)
+
+	assert.EqualError(t, manageKeys(prodDB, []string{"create", "root", "root"}, &out), `unknown role "root", use customer, operator or admin`)
+	assert.EqualError(t, manageKeys(prodDB, []string{"rotate"}, &out), "unknown keys command rotate, use create <name> <role>, list or revoke <id>")
+}
+
+func TestMemoryAdminKeyFile(t *testing.T) {
+	// Everything that is logged is kept to check that key isn't there
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+
+	keyFile := filepath.Join(t.TempDir(), "admin.key")
+	cfg := &config.Config{Storage: config.MemoryStorage, AdminKeyFile: keyFile}
+
+	app := NewApp(context.Background(), &logging.Logger{Entry: logrus.NewEntry(logger)}, cfg)
+	t.Cleanup(app.cancel)
+
+	prodDB, err := app.initStorage()
+	assert.Nil(t, err)
+
+	assert.Nil(t, app.writeMemoryAdminKey(prodDB))
+
+	content, err := os.ReadFile(keyFile)
+	assert.Nil(t, err)
+	rawKey := strings.TrimSpace(string(content))
+
+	info, err := os.Stat(keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	key, err := prodDB.SelectAPIKey(secret.Hash(rawKey))
+	assert.Nil(t, err)
+	assert.Equal(t, database.RoleAdmin, key.Role)
+
+	assert.Contains(t, logs.String(), keyFile)
+	assert.NotContains(t, logs.String(), rawKey)
+
+	// Without file in config admin key isn't made at all
+	cfg.AdminKeyFile = ""
+	assert.Nil(t, app.writeMemoryAdminKey(prodDB))
+
+	keys, err := prodDB.SelectAPIKeys()
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestMetricsAreServed(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	// Unknown product, Catalogue fails with 404
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"order":[{"product":"apple","amount":1}]}`)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `apishop_http_requests_total{method="POST",route="/",code="404"} 1`)
+	assert.Contains(t, w.Body.String(), `apishop_subscriber_update_errors_total{subscriber="Catalogue"} 1`)
+}
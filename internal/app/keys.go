@@ -0,0 +1,134 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/delonce/apishop/internal/config"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/secret"
+)
+
+const (
+	// Random part of API key
+	apiKeyBytes = 32
+	// Every key starts with it, so leaked key is easy to recognize
+	apiKeyPrefix = "apishop_"
+	// Start of key that is kept in storage, it is enough to tell keys apart in list
+	apiKeyShownLength = len(apiKeyPrefix) + 6
+)
+
+func (app *ConsumerApp) Keys(args []string, out io.Writer) error {
+	// Subcommand of application: keys [create <name> <role> | list | revoke <id>]
+	// In-memory storage is lost after command, so keys are kept only in postgres
+	if app.appConfig.Storage != config.PostgresStorage {
+		return fmt.Errorf("API keys work only with %s storage", config.PostgresStorage)
+	}
+
+	prodDB := app.initDBPoolConnection()
+	defer app.closeDBPoolConnection()
+
+	return manageKeys(prodDB, args, out)
+}
+
+func manageKeys(prodDB database.ProductDB, args []string, out io.Writer) error {
+	command := "list"
+
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "create":
+		if len(args) != 3 {
+			return errors.New("name and role of key are needed: keys create <name> <role>")
+		}
+
+		role, err := database.ParseRole(args[2])
+
+		if err != nil {
+			return err
+		}
+
+		rawKey, err := createAPIKey(prodDB, args[1], role, time.Now())
+
+		if err != nil {
+			return err
+		}
+
+		// Key is shown only once, storage can't restore it
+		_, err = fmt.Fprintln(out, rawKey)
+
+		return err
+	case "list":
+		keys, err := prodDB.SelectAPIKeys()
+
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "ID\tNAME\tKEY\tROLE\tCREATED\tREVOKED")
+
+		for _, key := range keys {
+			revoked := "-"
+
+			if key.RevokedAt != nil {
+				revoked = key.RevokedAt.UTC().Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(writer, "%d\t%s\t%s...\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.Role,
+				key.CreatedAt.UTC().Format(time.RFC3339), revoked)
+		}
+
+		return writer.Flush()
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("id of key is needed: keys revoke <id>")
+		}
+
+		id, err := strconv.ParseInt(args[1], 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("id of key should be integer, got %s", args[1])
+		}
+
+		if err := prodDB.RevokeAPIKey(id, time.Now()); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(out, "API key %d is revoked\n", id)
+
+		return err
+	default:
+		return fmt.Errorf("unknown keys command %s, use create <name> <role>, list or revoke <id>", command)
+	}
+}
+
+func createAPIKey(prodDB database.ProductDB, name string, role database.Role, at time.Time) (string, error) {
+	random, err := secret.New(apiKeyBytes)
+
+	if err != nil {
+		return "", err
+	}
+
+	rawKey := apiKeyPrefix + random
+
+	_, err = prodDB.InsertAPIKey(database.APIKey{
+		Name:      name,
+		Prefix:    rawKey[:apiKeyShownLength],
+		KeyHash:   secret.Hash(rawKey),
+		Role:      role,
+		CreatedAt: at,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return rawKey, nil
+}
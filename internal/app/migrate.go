@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/delonce/apishop/internal/config"
+	pgmanager "github.com/delonce/apishop/internal/database/postgres"
+)
+
+func (app *ConsumerApp) Migrate(args []string) error {
+	// Subcommand of application: migrate [up | down [steps] | version]
+	command := "up"
+
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	steps := 1
+
+	switch {
+	case command == "down" && len(args) > 1:
+		parsed, err := strconv.Atoi(args[1])
+
+		if err != nil || parsed < 1 {
+			return fmt.Errorf("amount of steps should be positive integer, got %s", args[1])
+		}
+
+		steps = parsed
+	case command != "up" && command != "down" && command != "version":
+		return fmt.Errorf("unknown migrate command %s, use up, down [steps] or version", command)
+	}
+
+	// In-memory storage doesn't have schema
+	if app.appConfig.Storage != config.PostgresStorage {
+		return fmt.Errorf("migrations work only with %s storage", config.PostgresStorage)
+	}
+
+	app.initDBPoolConnection()
+	defer app.closeDBPoolConnection()
+
+	migrator, err := pgmanager.NewMigrator(app.pgxPool, app.logger)
+
+	if err != nil {
+		return err
+	}
+
+	// Ctrl+C stops migrating, current migration is rolled back
+	switch command {
+	case "down":
+		return migrator.Down(app.stopCtx, steps)
+	case "version":
+		version, err := migrator.Version(app.stopCtx)
+
+		if err != nil {
+			return err
+		}
+
+		app.logger.Infof("Database schema version: %d", version)
+
+		return nil
+	default:
+		return migrator.Up(app.stopCtx)
+	}
+}
+
+func (app *ConsumerApp) migrateOnStart() error {
+	// Several instances can start together, advisory lock lets only one of them migrate
+	migrator, err := pgmanager.NewMigrator(app.pgxPool, app.logger)
+
+	if err != nil {
+		return err
+	}
+
+	return migrator.Up(app.stopCtx)
+}
@@ -2,20 +2,64 @@ package config
 
 import (
 	"sync"
+	"time"
 
 	"github.com/delonce/apishop/pkg/logging"
 	"github.com/spf13/viper"
 )
 
+// Implementations of database.ProductDB
+const (
+	PostgresStorage = "postgres"
+	// Without database, for local development and tests
+	MemoryStorage = "memory"
+)
+
 type Config struct {
 	Host string `mapstructure:"HOST"`
 	Port uint16 `mapstructure:"HOST_PORT"`
 
+	// PostgresStorage or MemoryStorage, DB_* values are used only by postgres
+	Storage string `mapstructure:"STORAGE"`
+
 	DBAddr   string `mapstructure:"DB_ADDR"`
 	DBPort   string `mapstructure:"DB_PORT"`
 	DBName   string `mapstructure:"DB_NAME"`
 	DBLogin  string `mapstructure:"DB_LOGIN"`
 	DBPasswd string `mapstructure:"DB_PASSWD"`
+
+	// How long reply to purchase query is kept for retries with the same Idempotency-Key
+	IdempotencyTTL time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+	// How long application waits for launched queries after stop signal
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// Apply database migrations when application starts
+	MigrateOnStart bool `mapstructure:"MIGRATE_ON_START"`
+
+	// Products cached for catalogue queries, 0 turns cache off
+	ProductCacheSize int `mapstructure:"PRODUCT_CACHE_SIZE"`
+	// Product changed not through application (e.g. by hand in database) is seen after this time
+	ProductCacheTTL time.Duration `mapstructure:"PRODUCT_CACHE_TTL"`
+
+	// Rates of tax categories of products in percents, e.g. "standard=20,reduced=5.5,zero=0"
+	TaxRates string `mapstructure:"TAX_RATES"`
+	// Costs of products already include tax, otherwise tax is added to them
+	PricesIncludeTax bool `mapstructure:"PRICES_INCLUDE_TAX"`
+
+	// How long token of customer works after login
+	SessionTTL time.Duration `mapstructure:"SESSION_TTL"`
+	// Cost of bcrypt for passwords of customers, every +1 doubles time of login
+	PasswordCost int `mapstructure:"PASSWORD_COST"`
+
+	// Token buckets of clients per route, e.g. "POST /=10/1s,POST /quote=20/1s,*=100/1s", empty turns limits off
+	// Route is method and pattern like in router (GET /products/:name), * is limit of other routes
+	// Buckets are kept in memory of every replica, so limit of the whole deployment is limit * replicas
+	RateLimits string `mapstructure:"RATE_LIMITS"`
+	// Application is behind proxy, client address is taken from X-Forwarded-For
+	TrustForwardedFor bool `mapstructure:"TRUST_FORWARDED_FOR"`
+
+	// In-memory storage writes admin key to this file on start, empty means it has no admin key
+	// Key isn't written to log, logs are shipped and kept
+	AdminKeyFile string `mapstructure:"ADMIN_KEY_FILE"`
 }
 
 var instance Config
@@ -29,6 +73,21 @@ func GetConfig(logger *logging.Logger) *Config {
 		viper.SetConfigFile(".env")
 		viper.AutomaticEnv()
 
+		// Values that can be omitted in .env file
+		viper.SetDefault("IDEMPOTENCY_TTL", "24h")
+		viper.SetDefault("SHUTDOWN_TIMEOUT", "15s")
+		viper.SetDefault("MIGRATE_ON_START", true)
+		viper.SetDefault("STORAGE", PostgresStorage)
+		viper.SetDefault("PRODUCT_CACHE_SIZE", 1000)
+		viper.SetDefault("PRODUCT_CACHE_TTL", "1m")
+		viper.SetDefault("TAX_RATES", "standard=0")
+		viper.SetDefault("PRICES_INCLUDE_TAX", false)
+		viper.SetDefault("SESSION_TTL", "24h")
+		viper.SetDefault("PASSWORD_COST", 10)
+		viper.SetDefault("RATE_LIMITS", "POST /=10/1s,POST /quote=20/1s,*=100/1s")
+		viper.SetDefault("TRUST_FORWARDED_FOR", false)
+		viper.SetDefault("ADMIN_KEY_FILE", "")
+
 		// Just reading our config
 		err := viper.ReadInConfig()
 
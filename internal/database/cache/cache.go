@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/metrics"
+)
+
+// Read-through cache of products in front of storage
+// Cache keeps name, cost and tax category of products, amounts are always read from storage by SelectStock,
+// so stock changed by another replica or by hand is seen at once
+// Reservations don't change cached fields, only UpdateProduct and DeleteProduct invalidate products
+type cachedDB struct {
+	database.ProductDB
+
+	products *lru
+	lookups  *metrics.CounterVec
+}
+
+func NewCachedStorage(prodDB database.ProductDB, size int, ttl time.Duration, registry *metrics.Registry) database.ProductDB {
+	cached := &cachedDB{
+		ProductDB: prodDB,
+		products:  newLRU(size, ttl),
+		lookups:   registry.Counter("apishop_product_cache_lookups_total", "Lookups of product cache by result.", "result"),
+	}
+
+	registry.GaugeFunc("apishop_product_cache_entries", "Products in cache.", func() float64 {
+		return float64(cached.products.len())
+	})
+	registry.CounterFunc("apishop_product_cache_evictions_total", "Products evicted from full cache.", func() float64 {
+		return float64(cached.products.evicted())
+	})
+
+	return cached
+}
+
+func (db *cachedDB) SelectProductByName(productName string) (*database.Product, error) {
+	if product, ok := db.products.get(productName); ok {
+		stock, err := db.ProductDB.SelectStock([]string{productName})
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Product was deleted not through cache (e.g. by another replica), storage will tell it
+		if amount, found := stock[productName]; found {
+			db.lookups.Inc("hit")
+			product.Amount = amount
+
+			return &product, nil
+		}
+	}
+
+	db.lookups.Inc("miss")
+
+	// Taken before query, so result isn't cached if product was changed meanwhile
+	version := db.products.version()
+	product, err := db.ProductDB.SelectProductByName(productName)
+
+	// Errors (e.g. product doesn't exist) aren't cached
+	if err != nil {
+		return nil, err
+	}
+
+	db.products.add(productName, *product, version)
+
+	return product, nil
+}
+
+func (db *cachedDB) SelectProductsByNames(productNames []string) ([]database.Product, error) {
+	// Order of purchase is priced by cached products, only stock is read from storage
+	products := make([]database.Product, 0, len(productNames))
+
+	for _, name := range productNames {
+		product, ok := db.products.get(name)
+
+		if !ok {
+			return db.selectMissed(productNames, len(products))
+		}
+
+		products = append(products, product)
+	}
+
+	stock, err := db.ProductDB.SelectStock(productNames)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range products {
+		amount, found := stock[products[i].Name]
+
+		if !found {
+			return db.selectMissed(productNames, i)
+		}
+
+		products[i].Amount = amount
+	}
+
+	db.lookups.Add(float64(len(products)), "hit")
+
+	// ORDER BY name like in storage
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	return products, nil
+}
+
+func (db *cachedDB) selectMissed(productNames []string, hits int) ([]database.Product, error) {
+	// Whole order is read with one query anyway, so products that were found in cache are refreshed too
+	db.lookups.Add(float64(hits), "hit")
+	db.lookups.Add(float64(len(productNames)-hits), "miss")
+
+	version := db.products.version()
+	products, err := db.ProductDB.SelectProductsByNames(productNames)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range products {
+		db.products.add(product.Name, product, version)
+	}
+
+	return products, nil
+}
+
+// New product isn't in cache, because misses aren't cached, so InsertProduct isn't wrapped
+
+func (db *cachedDB) UpdateProduct(productName string, product database.Product) error {
+	defer db.products.invalidate(productName, product.Name)
+	return db.ProductDB.UpdateProduct(productName, product)
+}
+
+func (db *cachedDB) DeleteProduct(productName string) error {
+	defer db.products.invalidate(productName)
+	return db.ProductDB.DeleteProduct(productName)
+}
+
+func (db *cachedDB) Begin() (database.ProductTx, error) {
+	tx, err := db.ProductDB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedTx{
+		ProductTx: tx,
+		products:  db.products,
+		changed:   &changedNames{},
+		root:      true,
+	}, nil
+}
+
+// Products changed by transaction and its savepoints
+type changedNames struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (changed *changedNames) add(names ...string) {
+	changed.mu.Lock()
+	changed.names = append(changed.names, names...)
+	changed.mu.Unlock()
+}
+
+// Transaction reads storage directly, because it has to see its own changes
+// Changed products are invalidated when the whole transaction is committed
+type cachedTx struct {
+	database.ProductTx
+
+	products *lru
+	changed  *changedNames
+	// Savepoints share names with their transaction
+	root bool
+}
+
+func (tx *cachedTx) UpdateProduct(productName string, product database.Product) error {
+	tx.changed.add(productName, product.Name)
+	return tx.ProductTx.UpdateProduct(productName, product)
+}
+
+func (tx *cachedTx) DeleteProduct(productName string) error {
+	tx.changed.add(productName)
+	return tx.ProductTx.DeleteProduct(productName)
+}
+
+func (tx *cachedTx) Begin() (database.ProductTx, error) {
+	savepoint, err := tx.ProductTx.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedTx{
+		ProductTx: savepoint,
+		products:  tx.products,
+		changed:   tx.changed,
+	}, nil
+}
+
+func (tx *cachedTx) Commit() error {
+	// Names of rolled back savepoints are invalidated too, it is just one more miss
+	if tx.root {
+		tx.changed.mu.Lock()
+		defer tx.changed.mu.Unlock()
+		defer tx.products.invalidate(tx.changed.names...)
+	}
+
+	return tx.ProductTx.Commit()
+}
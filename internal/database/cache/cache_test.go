@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/database/memory"
+	"github.com/delonce/apishop/pkg/metrics"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// Storage counting reads of whole products and reads of stock
+type countingDB struct {
+	database.ProductDB
+	reads      int
+	stockReads int
+}
+
+func (db *countingDB) SelectProductByName(productName string) (*database.Product, error) {
+	db.reads++
+	return db.ProductDB.SelectProductByName(productName)
+}
+
+func (db *countingDB) SelectProductsByNames(productNames []string) ([]database.Product, error) {
+	db.reads++
+	return db.ProductDB.SelectProductsByNames(productNames)
+}
+
+func (db *countingDB) SelectStock(productNames []string) (map[string]int64, error) {
+	db.stockReads++
+	return db.ProductDB.SelectStock(productNames)
+}
+
+func newTestCache(size int) (*cachedDB, *countingDB, *time.Time) {
+	storage := &countingDB{ProductDB: memory.NewStorage()}
+	storage.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+	storage.InsertProduct(database.Product{Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 5})
+	storage.InsertProduct(database.Product{Name: "kiwi", Cost: money.Money{Amount: 100, Currency: "USD"}, Amount: 1})
+
+	cached := NewCachedStorage(storage, size, time.Minute, nil).(*cachedDB)
+
+	now := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+	cached.products.now = func() time.Time { return now }
+
+	return cached, storage, &now
+}
+
+func TestHitsAndMisses(t *testing.T) {
+	registry := metrics.NewRegistry()
+	storage := &countingDB{ProductDB: memory.NewStorage()}
+	storage.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+
+	cached := NewCachedStorage(storage, 10, time.Minute, registry)
+
+	for i := 0; i < 3; i++ {
+		apple, err := cached.SelectProductByName("apple")
+		assert.Nil(t, err)
+		assert.Equal(t, money.Money{Amount: 200, Currency: "USD"}, apple.Cost)
+	}
+
+	// Unknown product isn't cached
+	_, err := cached.SelectProductByName("kiwi")
+	assert.True(t, errors.Is(err, database.ErrProductNotFound))
+	_, err = cached.SelectProductByName("kiwi")
+	assert.True(t, errors.Is(err, database.ErrProductNotFound))
+
+	assert.Equal(t, 3, storage.reads)
+	// Hits read only stock
+	assert.Equal(t, 2, storage.stockReads)
+
+	var out bytes.Buffer
+	registry.WriteTo(&out)
+	assert.Contains(t, out.String(), `apishop_product_cache_lookups_total{result="hit"} 2`)
+	assert.Contains(t, out.String(), `apishop_product_cache_lookups_total{result="miss"} 3`)
+	assert.Contains(t, out.String(), `apishop_product_cache_entries 1`)
+}
+
+func TestExpirationAndEviction(t *testing.T) {
+	cached, storage, now := newTestCache(2)
+
+	cached.SelectProductByName("apple")
+	cached.SelectProductByName("melon")
+
+	// Apple is used recently, so melon is evicted by kiwi
+	cached.SelectProductByName("apple")
+	cached.SelectProductByName("kiwi")
+	assert.Equal(t, 3, storage.reads)
+
+	cached.SelectProductByName("apple")
+	assert.Equal(t, 3, storage.reads)
+	cached.SelectProductByName("melon")
+	assert.Equal(t, 4, storage.reads)
+	assert.Equal(t, uint64(2), cached.products.evicted())
+
+	// Entry isn't used after ttl
+	*now = now.Add(time.Minute)
+	cached.SelectProductByName("melon")
+	assert.Equal(t, 5, storage.reads)
+}
+
+func TestInvalidation(t *testing.T) {
+	cached, _, _ := newTestCache(10)
+
+	cached.SelectProductByName("apple")
+	assert.Nil(t, cached.UpdateProduct("apple", database.Product{Name: "apple", Cost: money.Money{Amount: 250, Currency: "USD"}, Amount: 10}))
+
+	apple, _ := cached.SelectProductByName("apple")
+	assert.Equal(t, money.Money{Amount: 250, Currency: "USD"}, apple.Cost)
+
+	// Renamed product isn't found by old name
+	assert.Nil(t, cached.UpdateProduct("apple", database.Product{Name: "green apple", Cost: money.Money{Amount: 250, Currency: "USD"}, Amount: 10}))
+	_, err := cached.SelectProductByName("apple")
+	assert.True(t, errors.Is(err, database.ErrProductNotFound))
+
+	cached.SelectProductByName("melon")
+	assert.Nil(t, cached.DeleteProduct("melon"))
+	_, err = cached.SelectProductByName("melon")
+	assert.True(t, errors.Is(err, database.ErrProductNotFound))
+}
+
+func TestTransactionInvalidation(t *testing.T) {
+	cached, _, _ := newTestCache(10)
+	cached.SelectProductByName("apple")
+
+	tx, err := cached.Begin()
+	assert.Nil(t, err)
+
+	savepoint, err := tx.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, savepoint.ReserveProducts(map[string]int64{"apple": 4}))
+	assert.Nil(t, savepoint.Commit())
+
+	// Transaction sees its own changes, cache keeps committed amount until commit
+	inTx, _ := tx.SelectProductByName("apple")
+	assert.Equal(t, int64(6), inTx.Amount)
+
+	apple, _ := cached.SelectProductByName("apple")
+	assert.Equal(t, int64(10), apple.Amount)
+
+	assert.Nil(t, tx.Commit())
+
+	apple, _ = cached.SelectProductByName("apple")
+	assert.Equal(t, int64(6), apple.Amount)
+
+	// Rolled back transaction doesn't change anything
+	err = database.WithinTransaction(cached, func(tx database.ProductDB) error {
+		tx.ReserveProducts(map[string]int64{"apple": 6})
+		return errors.New("some mock error")
+	})
+	assert.EqualError(t, err, "some mock error")
+
+	apple, _ = cached.SelectProductByName("apple")
+	assert.Equal(t, int64(6), apple.Amount)
+}
+
+func TestStaleReadIsNotCached(t *testing.T) {
+	cached, _, _ := newTestCache(10)
+
+	// Product is read, then changed before reader puts it to cache
+	version := cached.products.version()
+	apple, _ := cached.ProductDB.SelectProductByName("apple")
+	assert.Nil(t, cached.UpdateProduct("apple", database.Product{Name: "apple", Cost: money.Money{Amount: 250, Currency: "USD"}, Amount: 10}))
+	cached.products.add("apple", *apple, version)
+
+	_, ok := cached.products.get("apple")
+	assert.False(t, ok)
+}
+
+func TestStockIsNotCached(t *testing.T) {
+	cached, storage, _ := newTestCache(10)
+	cached.SelectProductByName("apple")
+
+	// Reservation that didn't go through cache, e.g. by another replica
+	assert.Nil(t, cached.ProductDB.ReserveProducts(map[string]int64{"apple": 4}))
+
+	apple, err := cached.SelectProductByName("apple")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), apple.Amount)
+
+	entry, _ := cached.products.get("apple")
+	assert.Equal(t, int64(0), entry.Amount)
+
+	// Reservation through cache doesn't invalidate product, it isn't read again
+	assert.Nil(t, cached.ReserveProducts(map[string]int64{"apple": 1}))
+
+	products, err := cached.SelectProductsByNames([]string{"apple"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), products[0].Amount)
+	assert.Equal(t, 1, storage.reads)
+}
+
+func TestOrderIsPricedFromCache(t *testing.T) {
+	cached, storage, _ := newTestCache(10)
+
+	// Miss of one product reads the whole order
+	cached.SelectProductByName("melon")
+	products, err := cached.SelectProductsByNames([]string{"melon", "apple"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, storage.reads)
+	assert.Equal(t, []string{"apple", "melon"}, []string{products[0].Name, products[1].Name})
+
+	// Then only stock is read
+	products, err = cached.SelectProductsByNames([]string{"melon", "apple"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, storage.reads)
+	assert.Equal(t, 1, storage.stockReads)
+	assert.Equal(t, []database.Product{
+		{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10, TaxCategory: "standard"},
+		{ID: 2, Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 5, TaxCategory: "standard"},
+	}, products)
+
+	// Product deleted not through cache is absent like in storage
+	assert.Nil(t, cached.ProductDB.DeleteProduct("melon"))
+	products, err = cached.SelectProductsByNames([]string{"melon", "apple"})
+	assert.Nil(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, 3, storage.reads)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+type entry struct {
+	name      string
+	product   database.Product
+	expiresAt time.Time
+}
+
+// Least recently used products, every entry lives not longer than ttl
+type lru struct {
+	mu   sync.Mutex
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	// Front is the most recently used entry
+	order   *list.List
+	entries map[string]*list.Element
+
+	// Incremented by every invalidation, see version
+	generation uint64
+	evictions  uint64
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (cache *lru) get(name string) (database.Product, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	elem, ok := cache.entries[name]
+
+	if !ok {
+		return database.Product{}, false
+	}
+
+	cached := elem.Value.(*entry)
+
+	// Expired entry is removed on access, there is no background cleaning
+	if !cache.now().Before(cached.expiresAt) {
+		cache.remove(elem)
+		return database.Product{}, false
+	}
+
+	cache.order.MoveToFront(elem)
+
+	return cached.product, true
+}
+
+func (cache *lru) version() uint64 {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.generation
+}
+
+func (cache *lru) add(name string, product database.Product, version uint64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// Product was read before some invalidation, it can be already changed in database
+	if version != cache.generation {
+		return
+	}
+
+	// Stock changes with every purchase, it is always read from storage
+	product.Amount = 0
+	expiresAt := cache.now().Add(cache.ttl)
+
+	if elem, ok := cache.entries[name]; ok {
+		elem.Value = &entry{name: name, product: product, expiresAt: expiresAt}
+		cache.order.MoveToFront(elem)
+
+		return
+	}
+
+	cache.entries[name] = cache.order.PushFront(&entry{name: name, product: product, expiresAt: expiresAt})
+
+	if cache.order.Len() > cache.size {
+		cache.remove(cache.order.Back())
+		cache.evictions++
+	}
+}
+
+func (cache *lru) invalidate(names ...string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.generation++
+
+	for _, name := range names {
+		if elem, ok := cache.entries[name]; ok {
+			cache.remove(elem)
+		}
+	}
+}
+
+func (cache *lru) len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.order.Len()
+}
+
+func (cache *lru) evicted() uint64 {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.evictions
+}
+
+func (cache *lru) remove(elem *list.Element) {
+	cache.order.Remove(elem)
+	delete(cache.entries, elem.Value.(*entry).name)
+}
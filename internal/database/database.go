@@ -1,10 +1,97 @@
 package database
 
+import "time"
+
 // Interface provides interaction with database
 //go:generate mockgen -source=database.go -destination=mocks/mock.go
 type ProductDB interface {
 	SelectProductByName(productName string) (*Product, error)
+	// Row stays locked till the end of transaction, so concurrent reservation waits
+	// and amount that is written back isn't stale
+	SelectProductForUpdate(productName string) (*Product, error)
+	// One query for the whole order, names that don't exist are just absent in result
+	SelectProductsByNames(productNames []string) ([]Product, error)
+	// Only amounts of products, names that don't exist are absent too
+	// Cache keeps the rest of product and reads stock by it
+	SelectStock(productNames []string) (map[string]int64, error)
+
+	// Catalogue management, methods return ErrProductNotFound if name doesn't exist
+	SelectProducts() ([]Product, error)
+	InsertProduct(product Product) (int64, error)
+	UpdateProduct(productName string, product Product) error
+	DeleteProduct(productName string) error
 
 	InsertCheck(purchCheck Check) (int64, error)
 	InsertProductFromCheck(position Order) error
+
+	// Checks are read with all positions, ErrCheckNotFound if id doesn't exist
+	SelectCheckByID(checkID int64) (*Check, error)
+	SelectChecks(filter CheckFilter) ([]Check, error)
+
+	// Discount rules of pricing, DeleteDiscount returns ErrDiscountNotFound if id doesn't exist
+	// Active discounts are the ones without promo code and the ones of promoCode that work at the moment
+	SelectActiveDiscounts(promoCode string, at time.Time) ([]Discount, error)
+	SelectDiscounts() ([]Discount, error)
+	InsertDiscount(discount Discount) (int64, error)
+	DeleteDiscount(discountID int64) error
+
+	// Customers and their sessions, InsertCustomer returns ErrCustomerExists if email is taken
+	// SelectSession returns ErrSessionNotFound if token is unknown or session expired before at
+	InsertCustomer(customer Customer) (int64, error)
+	SelectCustomerByEmail(email string) (*Customer, error)
+	InsertSession(session Session) error
+	SelectSession(tokenHash string, at time.Time) (*Session, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpiredSessions(at time.Time) (int64, error)
+
+	// API keys of services and staff, SelectAPIKey and RevokeAPIKey return ErrAPIKeyNotFound
+	// if key is unknown or already revoked
+	InsertAPIKey(key APIKey) (int64, error)
+	SelectAPIKey(keyHash string) (*APIKey, error)
+	SelectAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id int64, at time.Time) error
+
+	// Idempotency keys of purchase queries
+	// Claim returns false if the key exists and was created after expiredBefore
+	ClaimIdempotencyKey(key IdempotencyKey, expiredBefore time.Time) (bool, error)
+	SelectIdempotencyKey(key string) (*IdempotencyKey, error)
+	SaveIdempotencyResponse(key IdempotencyKey) error
+	DeleteIdempotencyKey(key string) error
+	DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error)
+
+	// Atomically decrements amount of every product in order
+	// Either all positions are reserved or nothing changes and ErrInsufficientStock is returned
+	ReserveProducts(order map[string]int64) error
+
+	// Starts unit of work, all methods of returned ProductTx are executed in one transaction
+	Begin() (ProductTx, error)
+
+	// Checks that storage is reachable, used by readiness probe
+	Ping() error
+}
+
+// Transaction over ProductDB, must be finished with Commit or Rollback
+// Begin inside of transaction starts nested one (savepoint)
+type ProductTx interface {
+	ProductDB
+
+	Commit() error
+	Rollback() error
+}
+
+func WithinTransaction(db ProductDB, fn func(tx ProductDB) error) error {
+	// Runs fn in transaction: commits if fn returns nil and rolls back otherwise
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		// Caller is interested in error of fn, not in error of rollback
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,39 @@
+package database
+
+import "errors"
+
+// Errors that can be returned by ProductDB implementations
+// Callers should compare them with errors.Is because storage wraps them with details
+var (
+	// Requested amount of product is bigger than stock (e.g. concurrent buyer was faster)
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// Table product doesn't have row with requested name
+	ErrProductNotFound = errors.New("product doesn't exist")
+	// Product with the same name is already in table product
+	ErrProductExists = errors.New("product already exists")
+	// Product is referenced by positions of existing checks and can't be deleted
+	ErrProductInUse = errors.New("product is used in checks")
+
+	// Table "check" doesn't have row with requested id
+	ErrCheckNotFound = errors.New("check doesn't exist")
+
+	// Customer with the same email is already in table customer
+	ErrCustomerExists = errors.New("customer already exists")
+	// Table customer doesn't have row with requested email
+	ErrCustomerNotFound = errors.New("customer doesn't exist")
+	// Table session doesn't have requested token or session has expired
+	ErrSessionNotFound = errors.New("session doesn't exist")
+
+	// Table api_key doesn't have requested key or key was revoked
+	ErrAPIKeyNotFound = errors.New("API key doesn't exist")
+
+	// Table discount doesn't have row with requested id
+	ErrDiscountNotFound = errors.New("discount doesn't exist")
+
+	// Database can't be reached (connection refused or lost, timeout), query can be retried later
+	ErrStorageUnavailable = errors.New("storage is unavailable")
+
+	// Table idempotency_key doesn't have requested key
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key doesn't exist")
+)
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+func (db *memoryDB) InsertAPIKey(key database.APIKey) (int64, error) {
+	err := db.acc.write(func(st *state) error {
+		// CHECK constraint of column api_key.role
+		if _, err := database.ParseRole(string(key.Role)); err != nil {
+			return err
+		}
+
+		// UNIQUE constraint of column api_key.key_hash
+		if _, ok := st.apiKeyByHash(key.KeyHash); ok {
+			return errors.New("API key with this hash already exists")
+		}
+
+		key.ID = st.nextAPIKeyID
+		st.nextAPIKeyID++
+		st.apiKeys[key.ID] = key
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return key.ID, nil
+}
+
+func (db *memoryDB) SelectAPIKey(keyHash string) (*database.APIKey, error) {
+	var key database.APIKey
+
+	err := db.acc.read(func(st *state) error {
+		// Revoked key is the same as unknown one
+		found, ok := st.apiKeyByHash(keyHash)
+
+		if !ok || found.RevokedAt != nil {
+			return database.ErrAPIKeyNotFound
+		}
+
+		key = found
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (db *memoryDB) SelectAPIKeys() ([]database.APIKey, error) {
+	keys := []database.APIKey{}
+
+	err := db.acc.read(func(st *state) error {
+		for _, key := range st.apiKeys {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys, nil
+}
+
+func (db *memoryDB) RevokeAPIKey(id int64, at time.Time) error {
+	return db.acc.write(func(st *state) error {
+		key, ok := st.apiKeys[id]
+
+		if !ok || key.RevokedAt != nil {
+			return fmt.Errorf("%w, id: %d", database.ErrAPIKeyNotFound, id)
+		}
+
+		key.RevokedAt = &at
+		st.apiKeys[id] = key
+
+		return nil
+	})
+}
+
+func (st *state) apiKeyByHash(keyHash string) (database.APIKey, bool) {
+	for _, key := range st.apiKeys {
+		if key.KeyHash == keyHash {
+			return key, true
+		}
+	}
+
+	return database.APIKey{}, false
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+func (db *memoryDB) InsertCustomer(customer database.Customer) (int64, error) {
+	err := db.acc.write(func(st *state) error {
+		// CHECK constraint of column customer.email
+		if customer.Email != strings.ToLower(customer.Email) {
+			return errors.New("email of customer should be in lower case")
+		}
+
+		// UNIQUE constraint of column customer.email
+		if _, ok := st.customerByEmail(customer.Email); ok {
+			return fmt.Errorf("%w, email: %s", database.ErrCustomerExists, customer.Email)
+		}
+
+		customer.ID = st.nextCustomerID
+		st.nextCustomerID++
+		st.customers[customer.ID] = customer
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return customer.ID, nil
+}
+
+func (db *memoryDB) SelectCustomerByEmail(email string) (*database.Customer, error) {
+	var customer database.Customer
+
+	err := db.acc.read(func(st *state) error {
+		found, ok := st.customerByEmail(email)
+
+		if !ok {
+			return fmt.Errorf("%w, email: %s", database.ErrCustomerNotFound, email)
+		}
+
+		customer = found
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+func (db *memoryDB) InsertSession(session database.Session) error {
+	return db.acc.write(func(st *state) error {
+		// Foreign key of table session
+		if _, ok := st.customers[session.CustomerID]; !ok {
+			return fmt.Errorf("customer with id %d doesn't exist", session.CustomerID)
+		}
+
+		// Primary key of table session
+		if _, ok := st.sessions[session.TokenHash]; ok {
+			return errors.New("session with this token already exists")
+		}
+
+		st.sessions[session.TokenHash] = session
+
+		return nil
+	})
+}
+
+func (db *memoryDB) SelectSession(tokenHash string, at time.Time) (*database.Session, error) {
+	var session database.Session
+
+	err := db.acc.read(func(st *state) error {
+		// Expired session is the same as unknown one
+		found, ok := st.sessions[tokenHash]
+
+		if !ok || !found.ExpiresAt.After(at) {
+			return database.ErrSessionNotFound
+		}
+
+		session = found
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (db *memoryDB) DeleteSession(tokenHash string) error {
+	return db.acc.write(func(st *state) error {
+		delete(st.sessions, tokenHash)
+		return nil
+	})
+}
+
+func (db *memoryDB) DeleteExpiredSessions(at time.Time) (int64, error) {
+	var deleted int64
+
+	err := db.acc.write(func(st *state) error {
+		for tokenHash, session := range st.sessions {
+			if !session.ExpiresAt.After(at) {
+				delete(st.sessions, tokenHash)
+				deleted++
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+func (st *state) customerByEmail(email string) (database.Customer, bool) {
+	for _, customer := range st.customers {
+		if customer.Email == email {
+			return customer, true
+		}
+	}
+
+	return database.Customer{}, false
+}
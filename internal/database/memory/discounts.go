@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+func (db *memoryDB) SelectActiveDiscounts(promoCode string, at time.Time) ([]database.Discount, error) {
+	discounts := []database.Discount{}
+
+	err := db.acc.read(func(st *state) error {
+		for _, row := range st.sortedDiscounts() {
+			if row.PromoCode != "" && row.PromoCode != promoCode {
+				continue
+			}
+
+			if (row.StartsAt != nil && at.Before(*row.StartsAt)) || (row.EndsAt != nil && !at.Before(*row.EndsAt)) {
+				continue
+			}
+
+			discounts = append(discounts, st.discount(row))
+		}
+
+		return nil
+	})
+
+	return discounts, err
+}
+
+func (db *memoryDB) SelectDiscounts() ([]database.Discount, error) {
+	discounts := []database.Discount{}
+
+	err := db.acc.read(func(st *state) error {
+		for _, row := range st.sortedDiscounts() {
+			discounts = append(discounts, st.discount(row))
+		}
+
+		return nil
+	})
+
+	return discounts, err
+}
+
+func (db *memoryDB) InsertDiscount(discount database.Discount) (int64, error) {
+	err := db.acc.write(func(st *state) error {
+		if err := checkDiscount(discount); err != nil {
+			return err
+		}
+
+		row := discountRow{Discount: discount}
+
+		// Foreign key of table discount
+		if discount.Product != "" {
+			product, ok := st.productByName(discount.Product)
+
+			if !ok {
+				return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, discount.Product)
+			}
+
+			row.productID = product.ID
+		}
+
+		row.ID = st.nextDiscountID
+		st.nextDiscountID++
+		st.discounts[row.ID] = row
+
+		discount.ID = row.ID
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return discount.ID, nil
+}
+
+func (db *memoryDB) DeleteDiscount(discountID int64) error {
+	return db.acc.write(func(st *state) error {
+		if _, ok := st.discounts[discountID]; !ok {
+			return fmt.Errorf("%w, id: %d", database.ErrDiscountNotFound, discountID)
+		}
+
+		delete(st.discounts, discountID)
+
+		return nil
+	})
+}
+
+func (st *state) sortedDiscounts() []discountRow {
+	// ORDER BY id
+	rows := make([]discountRow, 0, len(st.discounts))
+
+	for _, row := range st.discounts {
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].ID < rows[j].ID
+	})
+
+	return rows
+}
+
+func (st *state) discount(row discountRow) database.Discount {
+	// Name is joined from table product like in postgres
+	discount := row.Discount
+	discount.Product = ""
+
+	if row.productID != 0 {
+		discount.Product = st.products[row.productID].Name
+	}
+
+	return discount
+}
+
+func checkDiscount(discount database.Discount) error {
+	// CHECK constraints of table discount
+	switch discount.Kind {
+	case database.DiscountPercent:
+		if discount.Percent < 1 || discount.Percent > 100 {
+			return errors.New("percent of discount should be from 1 to 100")
+		}
+	case database.DiscountFixed:
+		if discount.Fixed.Amount <= 0 || !currencyCode.MatchString(discount.Fixed.Currency) {
+			return errors.New("fixed discount should have positive amount and currency")
+		}
+	case database.DiscountBuyXGetY:
+		if discount.Buy <= 0 || discount.Free <= 0 || discount.Product == "" {
+			return errors.New("buy x get y discount should have positive amounts and product")
+		}
+	default:
+		return fmt.Errorf("unknown kind of discount %q", discount.Kind)
+	}
+
+	if discount.StartsAt != nil && discount.EndsAt != nil && !discount.StartsAt.Before(*discount.EndsAt) {
+		return errors.New("discount should start before it ends")
+	}
+
+	return nil
+}
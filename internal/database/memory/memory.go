@@ -0,0 +1,277 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+// Returned by Commit, Rollback and queries of already finished transaction
+var errTxClosed = errors.New("transaction is already closed")
+
+// Tables of storage, the same as in postgres
+type state struct {
+	products map[int64]database.Product
+	checks   map[int64]database.Check
+	orders   []database.Order
+	idemKeys map[string]database.IdempotencyKey
+	// Product of discount is kept by id like foreign key, so renamed product keeps its discounts
+	discounts map[int64]discountRow
+	customers map[int64]database.Customer
+	sessions  map[string]database.Session
+	apiKeys   map[int64]database.APIKey
+
+	// Sequences are part of state, so they are rolled back with it (unlike postgres, ids have no gaps)
+	nextProductID  int64
+	nextCheckID    int64
+	nextOrderID    int64
+	nextDiscountID int64
+	nextCustomerID int64
+	nextAPIKeyID   int64
+}
+
+type discountRow struct {
+	database.Discount
+	productID int64 // 0 for discount of the whole cart
+}
+
+func newState() *state {
+	return &state{
+		products:       make(map[int64]database.Product),
+		checks:         make(map[int64]database.Check),
+		idemKeys:       make(map[string]database.IdempotencyKey),
+		discounts:      make(map[int64]discountRow),
+		customers:      make(map[int64]database.Customer),
+		sessions:       make(map[string]database.Session),
+		apiKeys:        make(map[int64]database.APIKey),
+		nextProductID:  1,
+		nextCheckID:    1,
+		nextOrderID:    1,
+		nextDiscountID: 1,
+		nextCustomerID: 1,
+		nextAPIKeyID:   1,
+	}
+}
+
+func (st *state) clone() *state {
+	// Rows are values, so copying of maps is enough
+	cloned := &state{
+		products:       make(map[int64]database.Product, len(st.products)),
+		checks:         make(map[int64]database.Check, len(st.checks)),
+		orders:         append([]database.Order{}, st.orders...),
+		idemKeys:       make(map[string]database.IdempotencyKey, len(st.idemKeys)),
+		discounts:      make(map[int64]discountRow, len(st.discounts)),
+		customers:      make(map[int64]database.Customer, len(st.customers)),
+		sessions:       make(map[string]database.Session, len(st.sessions)),
+		apiKeys:        make(map[int64]database.APIKey, len(st.apiKeys)),
+		nextProductID:  st.nextProductID,
+		nextCheckID:    st.nextCheckID,
+		nextOrderID:    st.nextOrderID,
+		nextDiscountID: st.nextDiscountID,
+		nextCustomerID: st.nextCustomerID,
+		nextAPIKeyID:   st.nextAPIKeyID,
+	}
+
+	for id, product := range st.products {
+		cloned.products[id] = product
+	}
+
+	for id, purchCheck := range st.checks {
+		cloned.checks[id] = purchCheck
+	}
+
+	for key, idemKey := range st.idemKeys {
+		cloned.idemKeys[key] = idemKey
+	}
+
+	for id, discount := range st.discounts {
+		cloned.discounts[id] = discount
+	}
+
+	for id, customer := range st.customers {
+		cloned.customers[id] = customer
+	}
+
+	for tokenHash, session := range st.sessions {
+		cloned.sessions[tokenHash] = session
+	}
+
+	// Revoking replaces RevokedAt, so pointer can be shared
+	for id, key := range st.apiKeys {
+		cloned.apiKeys[id] = key
+	}
+
+	return cloned
+}
+
+// Access to state of storage or of transaction
+type access interface {
+	// fn only reads state
+	read(fn func(st *state) error) error
+	// fn changes state in place, so it checks everything before the first change
+	// Error means that nothing was changed, so every write is atomic like one SQL statement
+	write(fn func(st *state) error) error
+	begin() (*transaction, error)
+}
+
+// Committed state, shared by all users of storage
+type store struct {
+	// Only one writer at a time: single write or the whole transaction
+	// It is like SERIALIZABLE isolation, that's enough for development and tests
+	writeMu sync.Mutex
+
+	// Readers see committed state even while transaction is in progress
+	mu        sync.RWMutex
+	committed *state
+}
+
+func (s *store) read(fn func(st *state) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(s.committed)
+}
+
+func (s *store) write(fn func(st *state) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	// Single statement isn't copied, readers wait for it like for row lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fn(s.committed)
+}
+
+func (s *store) begin() (*transaction, error) {
+	// Lock is released by Commit or Rollback
+	// Writes to storage itself inside of transaction would deadlock, use transaction instead
+	s.writeMu.Lock()
+
+	// The only copy of state for the whole transaction, its statements change it in place
+	// Committed state is changed only under writeMu, so it can be read without mu here
+	return &transaction{
+		store:   s,
+		working: s.committed.clone(),
+	}, nil
+}
+
+func (s *store) publish(working *state) {
+	s.mu.Lock()
+	s.committed = working
+	s.mu.Unlock()
+}
+
+// State of transaction, it is used by one goroutine like pgx.Tx
+type transaction struct {
+	store *store
+	// Not nil for nested transaction (savepoint)
+	parent  *transaction
+	working *state
+	done    bool
+}
+
+func (tx *transaction) read(fn func(st *state) error) error {
+	if tx.done {
+		return errTxClosed
+	}
+
+	return fn(tx.working)
+}
+
+func (tx *transaction) write(fn func(st *state) error) error {
+	if tx.done {
+		return errTxClosed
+	}
+
+	return fn(tx.working)
+}
+
+func (tx *transaction) begin() (*transaction, error) {
+	if tx.done {
+		return nil, errTxClosed
+	}
+
+	// Savepoint works on copy of transaction state and gives it back on commit
+	return &transaction{
+		store:   tx.store,
+		parent:  tx,
+		working: tx.working.clone(),
+	}, nil
+}
+
+func (tx *transaction) commit() error {
+	if tx.done {
+		return errTxClosed
+	}
+
+	tx.done = true
+
+	if tx.parent != nil {
+		tx.parent.working = tx.working
+		return nil
+	}
+
+	tx.store.publish(tx.working)
+	tx.store.writeMu.Unlock()
+
+	return nil
+}
+
+func (tx *transaction) rollback() error {
+	// Rollback after commit is allowed, so it can be deferred
+	if tx.done {
+		return nil
+	}
+
+	tx.done = true
+
+	if tx.parent == nil {
+		tx.store.writeMu.Unlock()
+	}
+
+	return nil
+}
+
+type memoryDB struct {
+	acc access
+}
+
+type memoryTx struct {
+	*memoryDB
+	tx *transaction
+}
+
+// Storage without database for local development and tests
+// Every instance is empty and independent
+func NewStorage() database.ProductDB {
+	return &memoryDB{
+		acc: &store{committed: newState()},
+	}
+}
+
+func (db *memoryDB) Begin() (database.ProductTx, error) {
+	tx, err := db.acc.begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &memoryTx{
+		memoryDB: &memoryDB{acc: tx},
+		tx:       tx,
+	}, nil
+}
+
+func (db *memoryDB) Ping() error {
+	return nil
+}
+
+func (memTx *memoryTx) Commit() error {
+	return memTx.tx.commit()
+}
+
+func (memTx *memoryTx) Rollback() error {
+	return memTx.tx.rollback()
+}
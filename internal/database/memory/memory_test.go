@@ -0,0 +1,423 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogue(t *testing.T) {
+	db := NewStorage()
+
+	appleID, err := db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+	assert.Nil(t, err)
+	melonID, err := db.InsertProduct(database.Product{Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 1})
+	assert.Nil(t, err)
+
+	// Ids are generated one by one
+	assert.Equal(t, int64(1), appleID)
+	assert.Equal(t, int64(2), melonID)
+
+	_, err = db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 1, Currency: "USD"}, Amount: 1})
+	assert.True(t, errors.Is(err, database.ErrProductExists))
+
+	// Currency column is checked like in postgres
+	_, err = db.InsertProduct(database.Product{Name: "kiwi", Cost: money.Money{Amount: 1, Currency: "usd"}, Amount: 1})
+	assert.EqualError(t, err, `currency of product should be three capital letters, got "usd"`)
+
+	// Tax category is checked too, product without it gets default one
+	_, err = db.InsertProduct(database.Product{Name: "kiwi", Cost: money.Money{Amount: 1, Currency: "USD"}, Amount: 1, TaxCategory: "Food"})
+	assert.EqualError(t, err, `tax category of product should be lowercase word, got "Food"`)
+
+	// Renaming keeps id, name of another product can't be taken
+	assert.Nil(t, db.UpdateProduct("melon", database.Product{Name: "watermelon", Cost: money.Money{Amount: 350, Currency: "USD"}, Amount: 2}))
+	assert.True(t, errors.Is(db.UpdateProduct("watermelon", database.Product{Name: "apple"}), database.ErrProductExists))
+	assert.True(t, errors.Is(db.UpdateProduct("melon", database.Product{Name: "melon"}), database.ErrProductNotFound))
+
+	products, err := db.SelectProducts()
+	assert.Nil(t, err)
+	assert.Equal(t, []database.Product{
+		{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10, TaxCategory: "standard"},
+		{ID: 2, Name: "watermelon", Cost: money.Money{Amount: 350, Currency: "USD"}, Amount: 2, TaxCategory: "standard"},
+	}, products)
+
+	// Unknown names are just absent
+	products, err = db.SelectProductsByNames([]string{"watermelon", "kiwi", "apple"})
+	assert.Nil(t, err)
+	assert.Equal(t, []database.Product{
+		{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10, TaxCategory: "standard"},
+		{ID: 2, Name: "watermelon", Cost: money.Money{Amount: 350, Currency: "USD"}, Amount: 2, TaxCategory: "standard"},
+	}, products)
+
+	// Product from check can't be deleted
+	checkID, err := db.InsertCheck(database.Check{IsConfirmed: true, DateAt: time.Now(), Total: money.Money{Currency: "USD"}})
+	assert.Nil(t, err)
+	assert.Nil(t, db.InsertProductFromCheck(database.Order{CheckID: checkID, ProductID: appleID, ReqAmount: 1}))
+
+	assert.True(t, errors.Is(db.DeleteProduct("apple"), database.ErrProductInUse))
+	assert.Nil(t, db.DeleteProduct("watermelon"))
+	assert.True(t, errors.Is(db.DeleteProduct("watermelon"), database.ErrProductNotFound))
+
+	_, err = db.SelectProductByName("watermelon")
+	assert.EqualError(t, err, "product doesn't exist, name: watermelon")
+}
+
+func TestReserveProducts(t *testing.T) {
+	db := NewStorage()
+	db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+	db.InsertProduct(database.Product{Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 1})
+
+	// Nothing is reserved if one position can't be
+	err := db.ReserveProducts(map[string]int64{"apple": 5, "melon": 2})
+	assert.EqualError(t, err, "insufficient stock, product: melon, requested_amount: 2")
+
+	err = db.ReserveProducts(map[string]int64{"apple": 1, "kiwi": 1})
+	assert.True(t, errors.Is(err, database.ErrInsufficientStock))
+
+	apple, _ := db.SelectProductByName("apple")
+	assert.Equal(t, int64(10), apple.Amount)
+
+	assert.Nil(t, db.ReserveProducts(map[string]int64{"apple": 5, "melon": 1}))
+
+	apple, _ = db.SelectProductByName("apple")
+	assert.Equal(t, int64(5), apple.Amount)
+}
+
+func TestConcurrentReserve(t *testing.T) {
+	// Stock doesn't go below zero however many buyers come at the same time
+	db := NewStorage()
+	db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := database.WithinTransaction(db, func(tx database.ProductDB) error {
+				return tx.ReserveProducts(map[string]int64{"apple": 1})
+			})
+
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	apple, _ := db.SelectProductByName("apple")
+	assert.Equal(t, 50, reserved)
+	assert.Equal(t, int64(0), apple.Amount)
+}
+
+func TestTransactions(t *testing.T) {
+	db := NewStorage()
+	db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+
+	// Changes are visible only inside of transaction until commit
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.ReserveProducts(map[string]int64{"apple": 3}))
+
+	inTx, _ := tx.SelectProductByName("apple")
+	outside, _ := db.SelectProductByName("apple")
+	assert.Equal(t, int64(7), inTx.Amount)
+	assert.Equal(t, int64(10), outside.Amount)
+
+	// Rolled back savepoint doesn't change transaction
+	savepoint, err := tx.Begin()
+	assert.Nil(t, err)
+	_, err = savepoint.InsertCheck(database.Check{IsConfirmed: true, DateAt: time.Now(), Total: money.Money{Currency: "USD"}})
+	assert.Nil(t, err)
+	assert.Nil(t, savepoint.Rollback())
+
+	_, err = tx.SelectCheckByID(1)
+	assert.True(t, errors.Is(err, database.ErrCheckNotFound))
+
+	// Statements change state of transaction in place, failed one leaves nothing in it
+	// and transaction goes on like in postgres after failed statement in savepoint
+	err = tx.ReserveProducts(map[string]int64{"apple": 1, "kiwi": 1})
+	assert.True(t, errors.Is(err, database.ErrInsufficientStock))
+
+	inTx, _ = tx.SelectProductByName("apple")
+	assert.Equal(t, int64(7), inTx.Amount)
+
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, errTxClosed, tx.Commit())
+	assert.Nil(t, tx.Rollback())
+
+	outside, _ = db.SelectProductByName("apple")
+	assert.Equal(t, int64(7), outside.Amount)
+
+	// Failed function leaves nothing
+	err = database.WithinTransaction(db, func(tx database.ProductDB) error {
+		tx.ReserveProducts(map[string]int64{"apple": 7})
+		return errors.New("some mock error")
+	})
+
+	assert.EqualError(t, err, "some mock error")
+
+	outside, _ = db.SelectProductByName("apple")
+	assert.Equal(t, int64(7), outside.Amount)
+}
+
+func TestSelectChecks(t *testing.T) {
+	db := NewStorage()
+	db.InsertProduct(database.Product{Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 10})
+	db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+
+	dateAt := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		checkID, _ := db.InsertCheck(database.Check{
+			IsConfirmed: i%2 == 0,
+			DateAt:      dateAt.Add(time.Duration(i) * time.Hour),
+			Subtotal:    money.Money{Amount: 700, Currency: "USD"},
+			Tax:         money.Money{Amount: 100, Currency: "USD"},
+			Total:       money.Money{Amount: 800, Currency: "USD"},
+		})
+		db.InsertProductFromCheck(database.Order{CheckID: checkID, ProductID: 1, ReqAmount: 1, TaxCategory: "standard", TaxRate: 2000,
+			Tax: money.Money{Amount: 60, Currency: "USD"}, ProductName: "melon", ProductCost: money.Money{Amount: 300, Currency: "USD"},
+			LineTotal: money.Money{Amount: 300, Currency: "USD"}})
+		db.InsertProductFromCheck(database.Order{CheckID: checkID, ProductID: 2, ReqAmount: 2, TaxCategory: "food", TaxRate: 1000,
+			Tax: money.Money{Amount: 40, Currency: "USD"}, ProductName: "apple", ProductCost: money.Money{Amount: 200, Currency: "USD"},
+			LineTotal: money.Money{Amount: 400, Currency: "USD"}, Discount: money.Money{Amount: 100, Currency: "USD"}})
+	}
+
+	// Sums are checked like in postgres
+	_, err := db.InsertCheck(database.Check{Subtotal: money.Money{Amount: 700}, Total: money.Money{Amount: 800, Currency: "USD"}})
+	assert.EqualError(t, err, "total of check should be subtotal plus not negative tax")
+
+	err = db.InsertProductFromCheck(database.Order{CheckID: 1, ProductID: 1, ReqAmount: 2, ProductCost: money.Money{Amount: 300, Currency: "USD"},
+		LineTotal: money.Money{Amount: 300, Currency: "USD"}})
+	assert.EqualError(t, err, "line total of position should be its cost multiplied by amount")
+
+	err = db.InsertProductFromCheck(database.Order{CheckID: 1, ProductID: 1, ReqAmount: 1, ProductCost: money.Money{Amount: 300, Currency: "USD"},
+		LineTotal: money.Money{Amount: 300, Currency: "USD"}, Discount: money.Money{Amount: 301, Currency: "USD"}})
+	assert.EqualError(t, err, "discount of position can't be negative or bigger than its line total")
+
+	// Later changes of product don't reprice written checks
+	assert.Nil(t, db.UpdateProduct("apple", database.Product{Name: "green apple", Cost: money.Money{Amount: 250, Currency: "USD"}, Amount: 10}))
+
+	confirmed := true
+	to := dateAt.Add(4 * time.Hour)
+
+	checks, err := db.SelectChecks(database.CheckFilter{IsConfirmed: &confirmed, To: &to, Limit: 10})
+	assert.Nil(t, err)
+
+	// Newest first, positions are sorted by name and keep product as it was at purchase
+	ids := []string{}
+
+	for _, purchCheck := range checks {
+		ids = append(ids, fmt.Sprint(purchCheck.ID))
+	}
+
+	assert.Equal(t, []string{"3", "1"}, ids)
+	assert.Equal(t, []database.Order{
+		{ID: 6, CheckID: 3, ProductID: 2, ReqAmount: 2, TaxCategory: "food", TaxRate: 1000, Tax: money.Money{Amount: 40, Currency: "USD"},
+			ProductName: "apple", ProductCost: money.Money{Amount: 200, Currency: "USD"}, LineTotal: money.Money{Amount: 400, Currency: "USD"},
+			Discount: money.Money{Amount: 100, Currency: "USD"}},
+		{ID: 5, CheckID: 3, ProductID: 1, ReqAmount: 1, TaxCategory: "standard", TaxRate: 2000, Tax: money.Money{Amount: 60, Currency: "USD"},
+			ProductName: "melon", ProductCost: money.Money{Amount: 300, Currency: "USD"}, LineTotal: money.Money{Amount: 300, Currency: "USD"},
+			Discount: money.Money{Currency: "USD"}},
+	}, checks[0].PurchaseList)
+	assert.Equal(t, money.Money{Amount: 800, Currency: "USD"}, checks[0].Total)
+
+	// Pages
+	checks, _ = db.SelectChecks(database.CheckFilter{Limit: 2, Offset: 4})
+	assert.Len(t, checks, 1)
+	assert.Equal(t, int64(1), checks[0].ID)
+
+	checks, _ = db.SelectChecks(database.CheckFilter{Limit: 2, Offset: 5})
+	assert.Equal(t, []database.Check{}, checks)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	db := NewStorage()
+	now := time.Now()
+	key := database.IdempotencyKey{Key: "key-1", RequestHash: "hash", CreatedAt: now}
+
+	claimed, err := db.ClaimIdempotencyKey(key, now.Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+
+	// Key is busy until it expires
+	claimed, _ = db.ClaimIdempotencyKey(key, now.Add(-time.Hour))
+	assert.False(t, claimed)
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	assert.Nil(t, db.SaveIdempotencyResponse(database.IdempotencyKey{Key: "key-1", StatusCode: 400, Response: []byte("reply"), Headers: headers}))
+	// Saved headers can't be changed through map of caller
+	headers["Retry-After"] = "1"
+	assert.Error(t, db.SaveIdempotencyResponse(database.IdempotencyKey{Key: "key-1", CheckID: 5}))
+
+	saved, err := db.SelectIdempotencyKey("key-1")
+	assert.Nil(t, err)
+	assert.Equal(t, &database.IdempotencyKey{Key: "key-1", RequestHash: "hash", StatusCode: 400, Response: []byte("reply"),
+		Headers: map[string]string{"Content-Type": "application/json"}, CreatedAt: now}, saved)
+
+	// Expired key is taken again and cleared
+	claimed, _ = db.ClaimIdempotencyKey(database.IdempotencyKey{Key: "key-1", RequestHash: "other", CreatedAt: now}, now.Add(time.Second))
+	assert.True(t, claimed)
+
+	saved, _ = db.SelectIdempotencyKey("key-1")
+	assert.Equal(t, 0, saved.StatusCode)
+
+	deleted, err := db.DeleteExpiredIdempotencyKeys(now.Add(time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	_, err = db.SelectIdempotencyKey("key-1")
+	assert.True(t, errors.Is(err, database.ErrIdempotencyKeyNotFound))
+}
+
+func TestDiscounts(t *testing.T) {
+	db := NewStorage()
+
+	_, err := db.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10})
+	assert.Nil(t, err)
+	_, err = db.InsertProduct(database.Product{Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 1})
+	assert.Nil(t, err)
+
+	now := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+
+	appleSale := database.Discount{Kind: database.DiscountPercent, Product: "apple", Percent: 10}
+	cartSale := database.Discount{PromoCode: "SALE", Kind: database.DiscountFixed, Fixed: money.Money{Amount: 100, Currency: "USD"}}
+	melonSale := database.Discount{PromoCode: "MELON", Kind: database.DiscountBuyXGetY, Product: "melon", Buy: 2, Free: 1}
+	expired := database.Discount{PromoCode: "SALE", Kind: database.DiscountPercent, Percent: 50, EndsAt: &yesterday}
+
+	for _, discount := range []database.Discount{appleSale, cartSale, melonSale, expired} {
+		_, err = db.InsertDiscount(discount)
+		assert.Nil(t, err)
+	}
+
+	appleSale.ID, cartSale.ID, melonSale.ID = 1, 2, 3
+
+	// Foreign key and CHECK constraints like in postgres
+	_, err = db.InsertDiscount(database.Discount{Kind: database.DiscountPercent, Product: "kiwi", Percent: 10})
+	assert.True(t, errors.Is(err, database.ErrProductNotFound))
+	_, err = db.InsertDiscount(database.Discount{Kind: database.DiscountPercent, Percent: 150})
+	assert.EqualError(t, err, "percent of discount should be from 1 to 100")
+
+	// Discounts without code always work, with code only if code is sent and time hasn't ended
+	discounts, err := db.SelectActiveDiscounts("", now)
+	assert.Nil(t, err)
+	assert.Equal(t, []database.Discount{appleSale}, discounts)
+
+	discounts, err = db.SelectActiveDiscounts("SALE", now)
+	assert.Nil(t, err)
+	assert.Equal(t, []database.Discount{appleSale, cartSale}, discounts)
+
+	discounts, err = db.SelectActiveDiscounts("SALE", yesterday.Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, discounts, 3)
+
+	// Discount of product is deleted with product
+	assert.Nil(t, db.DeleteProduct("melon"))
+	assert.True(t, errors.Is(db.DeleteDiscount(3), database.ErrDiscountNotFound))
+	assert.Nil(t, db.DeleteDiscount(2))
+
+	discounts, err = db.SelectDiscounts()
+	assert.Nil(t, err)
+	assert.Equal(t, []database.Discount{appleSale, {ID: 4, PromoCode: "SALE", Kind: database.DiscountPercent, Percent: 50, EndsAt: &yesterday}}, discounts)
+}
+
+func TestCustomers(t *testing.T) {
+	db := NewStorage()
+	now := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+
+	customerID, err := db.InsertCustomer(database.Customer{Email: "ann@example.com", PasswordHash: "hash", CreatedAt: now})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), customerID)
+
+	// UNIQUE and CHECK constraints of email like in postgres
+	_, err = db.InsertCustomer(database.Customer{Email: "ann@example.com", PasswordHash: "other"})
+	assert.True(t, errors.Is(err, database.ErrCustomerExists))
+	_, err = db.InsertCustomer(database.Customer{Email: "Bob@example.com"})
+	assert.EqualError(t, err, "email of customer should be in lower case")
+
+	customer, err := db.SelectCustomerByEmail("ann@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, &database.Customer{ID: 1, Email: "ann@example.com", PasswordHash: "hash", CreatedAt: now}, customer)
+
+	_, err = db.SelectCustomerByEmail("bob@example.com")
+	assert.True(t, errors.Is(err, database.ErrCustomerNotFound))
+
+	// Session works until it expires
+	session := database.Session{TokenHash: "token", CustomerID: customerID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	assert.Nil(t, db.InsertSession(session))
+	assert.Error(t, db.InsertSession(database.Session{TokenHash: "other", CustomerID: 9}))
+
+	found, err := db.SelectSession("token", now)
+	assert.Nil(t, err)
+	assert.Equal(t, &session, found)
+
+	_, err = db.SelectSession("token", now.Add(time.Hour))
+	assert.True(t, errors.Is(err, database.ErrSessionNotFound))
+
+	deleted, err := db.DeleteExpiredSessions(now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	// Checks are filtered by customer, anonymous ones don't have it
+	for _, owner := range []int64{customerID, 0, customerID} {
+		_, err = db.InsertCheck(database.Check{IsConfirmed: true, DateAt: now, CustomerID: owner, Total: money.Money{Currency: "USD"}})
+		assert.Nil(t, err)
+	}
+
+	_, err = db.InsertCheck(database.Check{CustomerID: 9, Total: money.Money{Currency: "USD"}})
+	assert.EqualError(t, err, "customer with id 9 doesn't exist")
+
+	checks, err := db.SelectChecks(database.CheckFilter{CustomerID: &customerID, Limit: 10})
+	assert.Nil(t, err)
+	assert.Len(t, checks, 2)
+	assert.Equal(t, int64(3), checks[0].ID)
+	assert.Equal(t, customerID, checks[0].CustomerID)
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := NewStorage()
+	now := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+
+	keyID, err := db.InsertAPIKey(database.APIKey{Name: "back-office", Prefix: "apishop_abc", KeyHash: "hash",
+		Role: database.RoleOperator, CreatedAt: now})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), keyID)
+
+	// UNIQUE and CHECK constraints like in postgres
+	_, err = db.InsertAPIKey(database.APIKey{KeyHash: "hash", Role: database.RoleAdmin})
+	assert.EqualError(t, err, "API key with this hash already exists")
+	_, err = db.InsertAPIKey(database.APIKey{KeyHash: "other", Role: "root"})
+	assert.EqualError(t, err, `unknown role "root", use customer, operator or admin`)
+
+	key, err := db.SelectAPIKey("hash")
+	assert.Nil(t, err)
+	assert.Equal(t, database.RoleOperator, key.Role)
+
+	// Revoked key doesn't work, but stays in list
+	assert.Nil(t, db.RevokeAPIKey(keyID, now.Add(time.Hour)))
+	assert.True(t, errors.Is(db.RevokeAPIKey(keyID, now), database.ErrAPIKeyNotFound))
+	assert.True(t, errors.Is(db.RevokeAPIKey(9, now), database.ErrAPIKeyNotFound))
+
+	_, err = db.SelectAPIKey("hash")
+	assert.True(t, errors.Is(err, database.ErrAPIKeyNotFound))
+
+	keys, err := db.SelectAPIKeys()
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, now.Add(time.Hour), *keys[0].RevokedAt)
+}
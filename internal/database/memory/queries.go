@@ -0,0 +1,558 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+// CHECK constraints of columns product.currency and product.tax_category
+var (
+	currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+	taxCategory  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+func (db *memoryDB) SelectProductByName(productName string) (*database.Product, error) {
+	var product database.Product
+
+	err := db.acc.read(func(st *state) error {
+		found, ok := st.productByName(productName)
+
+		if !ok {
+			return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, productName)
+		}
+
+		product = found
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (db *memoryDB) SelectProductForUpdate(productName string) (*database.Product, error) {
+	// Writers of memory storage go one by one, so row can't be changed by someone else in transaction
+	return db.SelectProductByName(productName)
+}
+
+func (db *memoryDB) SelectProducts() ([]database.Product, error) {
+	products := []database.Product{}
+
+	err := db.acc.read(func(st *state) error {
+		for _, product := range st.products {
+			products = append(products, product)
+		}
+
+		return nil
+	})
+
+	// ORDER BY name
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	return products, err
+}
+
+func (db *memoryDB) SelectProductsByNames(productNames []string) ([]database.Product, error) {
+	wanted := make(map[string]bool, len(productNames))
+
+	for _, name := range productNames {
+		wanted[name] = true
+	}
+
+	products := []database.Product{}
+
+	err := db.acc.read(func(st *state) error {
+		for _, product := range st.products {
+			if wanted[product.Name] {
+				products = append(products, product)
+			}
+		}
+
+		return nil
+	})
+
+	// ORDER BY name
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	return products, err
+}
+
+func (db *memoryDB) SelectStock(productNames []string) (map[string]int64, error) {
+	stock := make(map[string]int64, len(productNames))
+
+	err := db.acc.read(func(st *state) error {
+		for _, name := range productNames {
+			if product, ok := st.productByName(name); ok {
+				stock[name] = product.Amount
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stock, nil
+}
+
+func (db *memoryDB) InsertProduct(product database.Product) (int64, error) {
+	err := db.acc.write(func(st *state) error {
+		if _, ok := st.productByName(product.Name); ok {
+			return fmt.Errorf("%w, name: %s", database.ErrProductExists, product.Name)
+		}
+
+		product = withDefaults(product)
+
+		if err := checkProduct(product); err != nil {
+			return err
+		}
+
+		product.ID = st.nextProductID
+		st.nextProductID++
+		st.products[product.ID] = product
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return product.ID, nil
+}
+
+func (db *memoryDB) UpdateProduct(productName string, product database.Product) error {
+	// Name can be changed too, id stays the same so checks keep their positions
+	return db.acc.write(func(st *state) error {
+		old, ok := st.productByName(productName)
+
+		if !ok {
+			return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, productName)
+		}
+
+		if other, ok := st.productByName(product.Name); ok && other.ID != old.ID {
+			return fmt.Errorf("%w, name: %s", database.ErrProductExists, product.Name)
+		}
+
+		product = withDefaults(product)
+
+		if err := checkProduct(product); err != nil {
+			return err
+		}
+
+		product.ID = old.ID
+		st.products[old.ID] = product
+
+		return nil
+	})
+}
+
+func (db *memoryDB) DeleteProduct(productName string) error {
+	return db.acc.write(func(st *state) error {
+		product, ok := st.productByName(productName)
+
+		if !ok {
+			return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, productName)
+		}
+
+		// Foreign key of table "order"
+		for _, position := range st.orders {
+			if position.ProductID == product.ID {
+				return fmt.Errorf("%w, name: %s", database.ErrProductInUse, productName)
+			}
+		}
+
+		delete(st.products, product.ID)
+
+		// ON DELETE CASCADE of table discount
+		for id, discount := range st.discounts {
+			if discount.productID == product.ID {
+				delete(st.discounts, id)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (db *memoryDB) InsertCheck(purchCheck database.Check) (int64, error) {
+	err := db.acc.write(func(st *state) error {
+		// CHECK constraints of table "check"
+		if purchCheck.Tax.Amount < 0 || purchCheck.Total.Amount != purchCheck.Subtotal.Amount+purchCheck.Tax.Amount {
+			return errors.New("total of check should be subtotal plus not negative tax")
+		}
+
+		if !currencyCode.MatchString(purchCheck.Total.Currency) {
+			return fmt.Errorf("currency of check should be three capital letters, got %q", purchCheck.Total.Currency)
+		}
+
+		// Foreign key of customer_id, 0 means NULL
+		if _, ok := st.customers[purchCheck.CustomerID]; purchCheck.CustomerID != 0 && !ok {
+			return fmt.Errorf("customer with id %d doesn't exist", purchCheck.CustomerID)
+		}
+
+		// Positions are inserted separately
+		purchCheck.ID = st.nextCheckID
+		purchCheck.PurchaseList = nil
+		st.nextCheckID++
+		st.checks[purchCheck.ID] = purchCheck
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return purchCheck.ID, nil
+}
+
+func (db *memoryDB) InsertProductFromCheck(position database.Order) error {
+	return db.acc.write(func(st *state) error {
+		// Foreign keys of table "order"
+		if _, ok := st.products[position.ProductID]; !ok {
+			return fmt.Errorf("product with id %d doesn't exist", position.ProductID)
+		}
+
+		if _, ok := st.checks[position.CheckID]; !ok {
+			return fmt.Errorf("check with id %d doesn't exist", position.CheckID)
+		}
+
+		// CHECK constraints of table "order"
+		if position.TaxRate < 0 || position.Tax.Amount < 0 {
+			return errors.New("tax and its rate can't be negative")
+		}
+
+		if position.ProductCost.Amount < 0 {
+			return errors.New("cost of position can't be negative")
+		}
+
+		if lineTotal, err := position.ProductCost.Mul(position.ReqAmount); err != nil || lineTotal.Amount != position.LineTotal.Amount {
+			return errors.New("line total of position should be its cost multiplied by amount")
+		}
+
+		if position.Discount.Amount < 0 || position.Discount.Amount > position.LineTotal.Amount {
+			return errors.New("discount of position can't be negative or bigger than its line total")
+		}
+
+		position.ID = st.nextOrderID
+		st.nextOrderID++
+		st.orders = append(st.orders, position)
+
+		return nil
+	})
+}
+
+func (db *memoryDB) SelectCheckByID(checkID int64) (*database.Check, error) {
+	var purchCheck database.Check
+
+	err := db.acc.read(func(st *state) error {
+		found, ok := st.checks[checkID]
+
+		if !ok {
+			return fmt.Errorf("%w, id: %d", database.ErrCheckNotFound, checkID)
+		}
+
+		purchCheck = found
+		purchCheck.PurchaseList = st.positions(checkID)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &purchCheck, nil
+}
+
+func (db *memoryDB) SelectChecks(filter database.CheckFilter) ([]database.Check, error) {
+	checks := []database.Check{}
+
+	err := db.acc.read(func(st *state) error {
+		for _, purchCheck := range st.checks {
+			if filter.From != nil && purchCheck.DateAt.Before(*filter.From) {
+				continue
+			}
+
+			if filter.To != nil && !purchCheck.DateAt.Before(*filter.To) {
+				continue
+			}
+
+			if filter.IsConfirmed != nil && purchCheck.IsConfirmed != *filter.IsConfirmed {
+				continue
+			}
+
+			if filter.CustomerID != nil && purchCheck.CustomerID != *filter.CustomerID {
+				continue
+			}
+
+			checks = append(checks, purchCheck)
+		}
+
+		// ORDER BY date DESC, id DESC
+		sort.Slice(checks, func(i, j int) bool {
+			if !checks[i].DateAt.Equal(checks[j].DateAt) {
+				return checks[i].DateAt.After(checks[j].DateAt)
+			}
+
+			return checks[i].ID > checks[j].ID
+		})
+
+		checks = page(checks, filter.Limit, filter.Offset)
+
+		for i := range checks {
+			checks[i].PurchaseList = st.positions(checks[i].ID)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return checks, nil
+}
+
+func (db *memoryDB) ClaimIdempotencyKey(key database.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	claimed := false
+
+	err := db.acc.write(func(st *state) error {
+		// Expired key is taken by new query as if it didn't exist
+		if old, ok := st.idemKeys[key.Key]; ok && !old.CreatedAt.Before(expiredBefore) {
+			return nil
+		}
+
+		st.idemKeys[key.Key] = database.IdempotencyKey{
+			Key:         key.Key,
+			RequestHash: key.RequestHash,
+			CreatedAt:   key.CreatedAt,
+		}
+		claimed = true
+
+		return nil
+	})
+
+	return claimed, err
+}
+
+func (db *memoryDB) SelectIdempotencyKey(key string) (*database.IdempotencyKey, error) {
+	var idemKey database.IdempotencyKey
+
+	err := db.acc.read(func(st *state) error {
+		found, ok := st.idemKeys[key]
+
+		if !ok {
+			return fmt.Errorf("%w, key: %s", database.ErrIdempotencyKeyNotFound, key)
+		}
+
+		idemKey = found
+		idemKey.Response = cloneBytes(found.Response)
+		idemKey.Headers = cloneHeaders(found.Headers)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &idemKey, nil
+}
+
+func (db *memoryDB) SaveIdempotencyResponse(key database.IdempotencyKey) error {
+	return db.acc.write(func(st *state) error {
+		idemKey, ok := st.idemKeys[key.Key]
+
+		if !ok {
+			return fmt.Errorf("%w, key: %s", database.ErrIdempotencyKeyNotFound, key.Key)
+		}
+
+		// Foreign key of check_id, 0 means NULL
+		if _, ok := st.checks[key.CheckID]; key.CheckID != 0 && !ok {
+			return fmt.Errorf("check with id %d doesn't exist", key.CheckID)
+		}
+
+		idemKey.CheckID = key.CheckID
+		idemKey.StatusCode = key.StatusCode
+		idemKey.Response = cloneBytes(key.Response)
+		idemKey.Headers = cloneHeaders(key.Headers)
+		st.idemKeys[key.Key] = idemKey
+
+		return nil
+	})
+}
+
+func (db *memoryDB) DeleteIdempotencyKey(key string) error {
+	return db.acc.write(func(st *state) error {
+		delete(st.idemKeys, key)
+		return nil
+	})
+}
+
+func (db *memoryDB) DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	var deleted int64
+
+	err := db.acc.write(func(st *state) error {
+		for key, idemKey := range st.idemKeys {
+			if idemKey.CreatedAt.Before(expiredBefore) {
+				delete(st.idemKeys, key)
+				deleted++
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+func (db *memoryDB) ReserveProducts(order map[string]int64) error {
+	// The whole reservation is one write, so either all positions are reserved or nothing changes
+	return db.acc.write(func(st *state) error {
+		names := make([]string, 0, len(order))
+
+		for name := range order {
+			names = append(names, name)
+		}
+
+		// The same order as postgres, so error names the same product
+		sort.Strings(names)
+		products := make([]database.Product, 0, len(names))
+
+		// Every position is checked before the first one is changed, state is changed in place
+		for _, name := range names {
+			product, ok := st.productByName(name)
+
+			// Unknown product isn't updated by postgres either
+			if !ok || product.Amount < order[name] {
+				return fmt.Errorf("%w, product: %s, requested_amount: %d", database.ErrInsufficientStock, name, order[name])
+			}
+
+			products = append(products, product)
+		}
+
+		for _, product := range products {
+			product.Amount -= order[product.Name]
+			st.products[product.ID] = product
+		}
+
+		return nil
+	})
+}
+
+func (st *state) productByName(name string) (database.Product, bool) {
+	// Table is small in development, so full scan is fine
+	for _, product := range st.products {
+		if product.Name == name {
+			return product, true
+		}
+	}
+
+	return database.Product{}, false
+}
+
+func (st *state) positions(checkID int64) []database.Order {
+	// Positions keep snapshot of product, only currency is taken from check like in postgres
+	var positions []database.Order
+
+	for _, position := range st.orders {
+		if position.CheckID != checkID {
+			continue
+		}
+
+		currency := st.checks[checkID].Total.Currency
+		position.ProductCost.Currency = currency
+		position.LineTotal.Currency = currency
+		position.Discount.Currency = currency
+		position.Tax.Currency = currency
+		positions = append(positions, position)
+	}
+
+	// ORDER BY o.product_name
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].ProductName < positions[j].ProductName
+	})
+
+	return positions
+}
+
+func checkProduct(product database.Product) error {
+	// CHECK constraints of table product
+	if product.Cost.Amount < 0 || product.Amount < 0 {
+		return errors.New("cost and amount of product can't be negative")
+	}
+
+	if !currencyCode.MatchString(product.Cost.Currency) {
+		return fmt.Errorf("currency of product should be three capital letters, got %q", product.Cost.Currency)
+	}
+
+	if !taxCategory.MatchString(product.TaxCategory) {
+		return fmt.Errorf("tax category of product should be lowercase word, got %q", product.TaxCategory)
+	}
+
+	return nil
+}
+
+func withDefaults(product database.Product) database.Product {
+	// DEFAULT of column tax_category
+	if product.TaxCategory == "" {
+		product.TaxCategory = database.DefaultTaxCategory
+	}
+
+	return product
+}
+
+func page(checks []database.Check, limit, offset int64) []database.Check {
+	// LIMIT and OFFSET
+	if offset >= int64(len(checks)) {
+		return []database.Check{}
+	}
+
+	checks = checks[offset:]
+
+	if limit < int64(len(checks)) {
+		checks = checks[:limit]
+	}
+
+	return checks
+}
+
+func cloneBytes(data []byte) []byte {
+	// Caller can't change saved response through returned slice
+	if data == nil {
+		return nil
+	}
+
+	return append([]byte{}, data...)
+}
+
+func cloneHeaders(headers map[string]string) map[string]string {
+	// Like response, headers are saved as jsonb in postgres
+	cloned := make(map[string]string, len(headers))
+
+	for name, value := range headers {
+		cloned[name] = value
+	}
+
+	return cloned
+}
@@ -6,6 +6,7 @@ package mock_database
 
 import (
 	reflect "reflect"
+	time "time"
 
 	database "github.com/delonce/apishop/internal/database"
 	gomock "github.com/golang/mock/gomock"
@@ -34,6 +35,137 @@ func (m *MockProductDB) EXPECT() *MockProductDBMockRecorder {
 	return m.recorder
 }
 
+// Begin mocks base method.
+func (m *MockProductDB) Begin() (database.ProductTx, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin")
+	ret0, _ := ret[0].(database.ProductTx)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockProductDBMockRecorder) Begin() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockProductDB)(nil).Begin))
+}
+
+// ClaimIdempotencyKey mocks base method.
+func (m *MockProductDB) ClaimIdempotencyKey(key database.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimIdempotencyKey", key, expiredBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimIdempotencyKey indicates an expected call of ClaimIdempotencyKey.
+func (mr *MockProductDBMockRecorder) ClaimIdempotencyKey(key, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimIdempotencyKey", reflect.TypeOf((*MockProductDB)(nil).ClaimIdempotencyKey), key, expiredBefore)
+}
+
+// DeleteDiscount mocks base method.
+func (m *MockProductDB) DeleteDiscount(discountID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDiscount", discountID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDiscount indicates an expected call of DeleteDiscount.
+func (mr *MockProductDBMockRecorder) DeleteDiscount(discountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDiscount", reflect.TypeOf((*MockProductDB)(nil).DeleteDiscount), discountID)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockProductDB) DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", expiredBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockProductDBMockRecorder) DeleteExpiredIdempotencyKeys(expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockProductDB)(nil).DeleteExpiredIdempotencyKeys), expiredBefore)
+}
+
+// DeleteExpiredSessions mocks base method.
+func (m *MockProductDB) DeleteExpiredSessions(at time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredSessions", at)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredSessions indicates an expected call of DeleteExpiredSessions.
+func (mr *MockProductDBMockRecorder) DeleteExpiredSessions(at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredSessions", reflect.TypeOf((*MockProductDB)(nil).DeleteExpiredSessions), at)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockProductDB) DeleteIdempotencyKey(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockProductDBMockRecorder) DeleteIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockProductDB)(nil).DeleteIdempotencyKey), key)
+}
+
+// DeleteProduct mocks base method.
+func (m *MockProductDB) DeleteProduct(productName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteProduct", productName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteProduct indicates an expected call of DeleteProduct.
+func (mr *MockProductDBMockRecorder) DeleteProduct(productName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProduct", reflect.TypeOf((*MockProductDB)(nil).DeleteProduct), productName)
+}
+
+// DeleteSession mocks base method.
+func (m *MockProductDB) DeleteSession(tokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", tokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession.
+func (mr *MockProductDBMockRecorder) DeleteSession(tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockProductDB)(nil).DeleteSession), tokenHash)
+}
+
+// InsertAPIKey mocks base method.
+func (m *MockProductDB) InsertAPIKey(key database.APIKey) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertAPIKey", key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertAPIKey indicates an expected call of InsertAPIKey.
+func (mr *MockProductDBMockRecorder) InsertAPIKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAPIKey", reflect.TypeOf((*MockProductDB)(nil).InsertAPIKey), key)
+}
+
 // InsertCheck mocks base method.
 func (m *MockProductDB) InsertCheck(purchCheck database.Check) (int64, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +181,51 @@ func (mr *MockProductDBMockRecorder) InsertCheck(purchCheck interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertCheck", reflect.TypeOf((*MockProductDB)(nil).InsertCheck), purchCheck)
 }
 
+// InsertCustomer mocks base method.
+func (m *MockProductDB) InsertCustomer(customer database.Customer) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertCustomer", customer)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertCustomer indicates an expected call of InsertCustomer.
+func (mr *MockProductDBMockRecorder) InsertCustomer(customer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertCustomer", reflect.TypeOf((*MockProductDB)(nil).InsertCustomer), customer)
+}
+
+// InsertDiscount mocks base method.
+func (m *MockProductDB) InsertDiscount(discount database.Discount) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertDiscount", discount)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertDiscount indicates an expected call of InsertDiscount.
+func (mr *MockProductDBMockRecorder) InsertDiscount(discount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertDiscount", reflect.TypeOf((*MockProductDB)(nil).InsertDiscount), discount)
+}
+
+// InsertProduct mocks base method.
+func (m *MockProductDB) InsertProduct(product database.Product) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertProduct", product)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertProduct indicates an expected call of InsertProduct.
+func (mr *MockProductDBMockRecorder) InsertProduct(product interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertProduct", reflect.TypeOf((*MockProductDB)(nil).InsertProduct), product)
+}
+
 // InsertProductFromCheck mocks base method.
 func (m *MockProductDB) InsertProductFromCheck(position database.Order) error {
 	m.ctrl.T.Helper()
@@ -63,6 +240,196 @@ func (mr *MockProductDBMockRecorder) InsertProductFromCheck(position interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertProductFromCheck", reflect.TypeOf((*MockProductDB)(nil).InsertProductFromCheck), position)
 }
 
+// InsertSession mocks base method.
+func (m *MockProductDB) InsertSession(session database.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertSession", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertSession indicates an expected call of InsertSession.
+func (mr *MockProductDBMockRecorder) InsertSession(session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertSession", reflect.TypeOf((*MockProductDB)(nil).InsertSession), session)
+}
+
+// Ping mocks base method.
+func (m *MockProductDB) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockProductDBMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockProductDB)(nil).Ping))
+}
+
+// ReserveProducts mocks base method.
+func (m *MockProductDB) ReserveProducts(order map[string]int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveProducts", order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReserveProducts indicates an expected call of ReserveProducts.
+func (mr *MockProductDBMockRecorder) ReserveProducts(order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveProducts", reflect.TypeOf((*MockProductDB)(nil).ReserveProducts), order)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockProductDB) RevokeAPIKey(id int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockProductDBMockRecorder) RevokeAPIKey(id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockProductDB)(nil).RevokeAPIKey), id, at)
+}
+
+// SaveIdempotencyResponse mocks base method.
+func (m *MockProductDB) SaveIdempotencyResponse(key database.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotencyResponse", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotencyResponse indicates an expected call of SaveIdempotencyResponse.
+func (mr *MockProductDBMockRecorder) SaveIdempotencyResponse(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotencyResponse", reflect.TypeOf((*MockProductDB)(nil).SaveIdempotencyResponse), key)
+}
+
+// SelectAPIKey mocks base method.
+func (m *MockProductDB) SelectAPIKey(keyHash string) (*database.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectAPIKey", keyHash)
+	ret0, _ := ret[0].(*database.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectAPIKey indicates an expected call of SelectAPIKey.
+func (mr *MockProductDBMockRecorder) SelectAPIKey(keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAPIKey", reflect.TypeOf((*MockProductDB)(nil).SelectAPIKey), keyHash)
+}
+
+// SelectAPIKeys mocks base method.
+func (m *MockProductDB) SelectAPIKeys() ([]database.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectAPIKeys")
+	ret0, _ := ret[0].([]database.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectAPIKeys indicates an expected call of SelectAPIKeys.
+func (mr *MockProductDBMockRecorder) SelectAPIKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAPIKeys", reflect.TypeOf((*MockProductDB)(nil).SelectAPIKeys))
+}
+
+// SelectActiveDiscounts mocks base method.
+func (m *MockProductDB) SelectActiveDiscounts(promoCode string, at time.Time) ([]database.Discount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectActiveDiscounts", promoCode, at)
+	ret0, _ := ret[0].([]database.Discount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectActiveDiscounts indicates an expected call of SelectActiveDiscounts.
+func (mr *MockProductDBMockRecorder) SelectActiveDiscounts(promoCode, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectActiveDiscounts", reflect.TypeOf((*MockProductDB)(nil).SelectActiveDiscounts), promoCode, at)
+}
+
+// SelectCheckByID mocks base method.
+func (m *MockProductDB) SelectCheckByID(checkID int64) (*database.Check, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectCheckByID", checkID)
+	ret0, _ := ret[0].(*database.Check)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectCheckByID indicates an expected call of SelectCheckByID.
+func (mr *MockProductDBMockRecorder) SelectCheckByID(checkID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectCheckByID", reflect.TypeOf((*MockProductDB)(nil).SelectCheckByID), checkID)
+}
+
+// SelectChecks mocks base method.
+func (m *MockProductDB) SelectChecks(filter database.CheckFilter) ([]database.Check, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectChecks", filter)
+	ret0, _ := ret[0].([]database.Check)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectChecks indicates an expected call of SelectChecks.
+func (mr *MockProductDBMockRecorder) SelectChecks(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectChecks", reflect.TypeOf((*MockProductDB)(nil).SelectChecks), filter)
+}
+
+// SelectCustomerByEmail mocks base method.
+func (m *MockProductDB) SelectCustomerByEmail(email string) (*database.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectCustomerByEmail", email)
+	ret0, _ := ret[0].(*database.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectCustomerByEmail indicates an expected call of SelectCustomerByEmail.
+func (mr *MockProductDBMockRecorder) SelectCustomerByEmail(email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectCustomerByEmail", reflect.TypeOf((*MockProductDB)(nil).SelectCustomerByEmail), email)
+}
+
+// SelectDiscounts mocks base method.
+func (m *MockProductDB) SelectDiscounts() ([]database.Discount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectDiscounts")
+	ret0, _ := ret[0].([]database.Discount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectDiscounts indicates an expected call of SelectDiscounts.
+func (mr *MockProductDBMockRecorder) SelectDiscounts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectDiscounts", reflect.TypeOf((*MockProductDB)(nil).SelectDiscounts))
+}
+
+// SelectIdempotencyKey mocks base method.
+func (m *MockProductDB) SelectIdempotencyKey(key string) (*database.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectIdempotencyKey", key)
+	ret0, _ := ret[0].(*database.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectIdempotencyKey indicates an expected call of SelectIdempotencyKey.
+func (mr *MockProductDBMockRecorder) SelectIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectIdempotencyKey", reflect.TypeOf((*MockProductDB)(nil).SelectIdempotencyKey), key)
+}
+
 // SelectProductByName mocks base method.
 func (m *MockProductDB) SelectProductByName(productName string) (*database.Product, error) {
 	m.ctrl.T.Helper()
@@ -77,3 +444,642 @@ func (mr *MockProductDBMockRecorder) SelectProductByName(productName interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProductByName", reflect.TypeOf((*MockProductDB)(nil).SelectProductByName), productName)
 }
+
+// SelectProductForUpdate mocks base method.
+func (m *MockProductDB) SelectProductForUpdate(productName string) (*database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProductForUpdate", productName)
+	ret0, _ := ret[0].(*database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProductForUpdate indicates an expected call of SelectProductForUpdate.
+func (mr *MockProductDBMockRecorder) SelectProductForUpdate(productName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProductForUpdate", reflect.TypeOf((*MockProductDB)(nil).SelectProductForUpdate), productName)
+}
+
+// SelectProducts mocks base method.
+func (m *MockProductDB) SelectProducts() ([]database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProducts")
+	ret0, _ := ret[0].([]database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProducts indicates an expected call of SelectProducts.
+func (mr *MockProductDBMockRecorder) SelectProducts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProducts", reflect.TypeOf((*MockProductDB)(nil).SelectProducts))
+}
+
+// SelectProductsByNames mocks base method.
+func (m *MockProductDB) SelectProductsByNames(productNames []string) ([]database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProductsByNames", productNames)
+	ret0, _ := ret[0].([]database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProductsByNames indicates an expected call of SelectProductsByNames.
+func (mr *MockProductDBMockRecorder) SelectProductsByNames(productNames interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProductsByNames", reflect.TypeOf((*MockProductDB)(nil).SelectProductsByNames), productNames)
+}
+
+// SelectSession mocks base method.
+func (m *MockProductDB) SelectSession(tokenHash string, at time.Time) (*database.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectSession", tokenHash, at)
+	ret0, _ := ret[0].(*database.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectSession indicates an expected call of SelectSession.
+func (mr *MockProductDBMockRecorder) SelectSession(tokenHash, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectSession", reflect.TypeOf((*MockProductDB)(nil).SelectSession), tokenHash, at)
+}
+
+// SelectStock mocks base method.
+func (m *MockProductDB) SelectStock(productNames []string) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectStock", productNames)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectStock indicates an expected call of SelectStock.
+func (mr *MockProductDBMockRecorder) SelectStock(productNames interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectStock", reflect.TypeOf((*MockProductDB)(nil).SelectStock), productNames)
+}
+
+// UpdateProduct mocks base method.
+func (m *MockProductDB) UpdateProduct(productName string, product database.Product) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProduct", productName, product)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProduct indicates an expected call of UpdateProduct.
+func (mr *MockProductDBMockRecorder) UpdateProduct(productName, product interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockProductDB)(nil).UpdateProduct), productName, product)
+}
+
+// MockProductTx is a mock of ProductTx interface.
+type MockProductTx struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductTxMockRecorder
+}
+
+// MockProductTxMockRecorder is the mock recorder for MockProductTx.
+type MockProductTxMockRecorder struct {
+	mock *MockProductTx
+}
+
+// NewMockProductTx creates a new mock instance.
+func NewMockProductTx(ctrl *gomock.Controller) *MockProductTx {
+	mock := &MockProductTx{ctrl: ctrl}
+	mock.recorder = &MockProductTxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductTx) EXPECT() *MockProductTxMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *MockProductTx) Begin() (database.ProductTx, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin")
+	ret0, _ := ret[0].(database.ProductTx)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockProductTxMockRecorder) Begin() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockProductTx)(nil).Begin))
+}
+
+// ClaimIdempotencyKey mocks base method.
+func (m *MockProductTx) ClaimIdempotencyKey(key database.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimIdempotencyKey", key, expiredBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimIdempotencyKey indicates an expected call of ClaimIdempotencyKey.
+func (mr *MockProductTxMockRecorder) ClaimIdempotencyKey(key, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimIdempotencyKey", reflect.TypeOf((*MockProductTx)(nil).ClaimIdempotencyKey), key, expiredBefore)
+}
+
+// Commit mocks base method.
+func (m *MockProductTx) Commit() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockProductTxMockRecorder) Commit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockProductTx)(nil).Commit))
+}
+
+// DeleteDiscount mocks base method.
+func (m *MockProductTx) DeleteDiscount(discountID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDiscount", discountID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDiscount indicates an expected call of DeleteDiscount.
+func (mr *MockProductTxMockRecorder) DeleteDiscount(discountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDiscount", reflect.TypeOf((*MockProductTx)(nil).DeleteDiscount), discountID)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockProductTx) DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", expiredBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockProductTxMockRecorder) DeleteExpiredIdempotencyKeys(expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockProductTx)(nil).DeleteExpiredIdempotencyKeys), expiredBefore)
+}
+
+// DeleteExpiredSessions mocks base method.
+func (m *MockProductTx) DeleteExpiredSessions(at time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredSessions", at)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredSessions indicates an expected call of DeleteExpiredSessions.
+func (mr *MockProductTxMockRecorder) DeleteExpiredSessions(at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredSessions", reflect.TypeOf((*MockProductTx)(nil).DeleteExpiredSessions), at)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockProductTx) DeleteIdempotencyKey(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockProductTxMockRecorder) DeleteIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockProductTx)(nil).DeleteIdempotencyKey), key)
+}
+
+// DeleteProduct mocks base method.
+func (m *MockProductTx) DeleteProduct(productName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteProduct", productName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteProduct indicates an expected call of DeleteProduct.
+func (mr *MockProductTxMockRecorder) DeleteProduct(productName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProduct", reflect.TypeOf((*MockProductTx)(nil).DeleteProduct), productName)
+}
+
+// DeleteSession mocks base method.
+func (m *MockProductTx) DeleteSession(tokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", tokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession.
+func (mr *MockProductTxMockRecorder) DeleteSession(tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockProductTx)(nil).DeleteSession), tokenHash)
+}
+
+// InsertAPIKey mocks base method.
+func (m *MockProductTx) InsertAPIKey(key database.APIKey) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertAPIKey", key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertAPIKey indicates an expected call of InsertAPIKey.
+func (mr *MockProductTxMockRecorder) InsertAPIKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAPIKey", reflect.TypeOf((*MockProductTx)(nil).InsertAPIKey), key)
+}
+
+// InsertCheck mocks base method.
+func (m *MockProductTx) InsertCheck(purchCheck database.Check) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertCheck", purchCheck)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertCheck indicates an expected call of InsertCheck.
+func (mr *MockProductTxMockRecorder) InsertCheck(purchCheck interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertCheck", reflect.TypeOf((*MockProductTx)(nil).InsertCheck), purchCheck)
+}
+
+// InsertCustomer mocks base method.
+func (m *MockProductTx) InsertCustomer(customer database.Customer) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertCustomer", customer)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertCustomer indicates an expected call of InsertCustomer.
+func (mr *MockProductTxMockRecorder) InsertCustomer(customer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertCustomer", reflect.TypeOf((*MockProductTx)(nil).InsertCustomer), customer)
+}
+
+// InsertDiscount mocks base method.
+func (m *MockProductTx) InsertDiscount(discount database.Discount) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertDiscount", discount)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertDiscount indicates an expected call of InsertDiscount.
+func (mr *MockProductTxMockRecorder) InsertDiscount(discount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertDiscount", reflect.TypeOf((*MockProductTx)(nil).InsertDiscount), discount)
+}
+
+// InsertProduct mocks base method.
+func (m *MockProductTx) InsertProduct(product database.Product) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertProduct", product)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertProduct indicates an expected call of InsertProduct.
+func (mr *MockProductTxMockRecorder) InsertProduct(product interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertProduct", reflect.TypeOf((*MockProductTx)(nil).InsertProduct), product)
+}
+
+// InsertProductFromCheck mocks base method.
+func (m *MockProductTx) InsertProductFromCheck(position database.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertProductFromCheck", position)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertProductFromCheck indicates an expected call of InsertProductFromCheck.
+func (mr *MockProductTxMockRecorder) InsertProductFromCheck(position interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertProductFromCheck", reflect.TypeOf((*MockProductTx)(nil).InsertProductFromCheck), position)
+}
+
+// InsertSession mocks base method.
+func (m *MockProductTx) InsertSession(session database.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertSession", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertSession indicates an expected call of InsertSession.
+func (mr *MockProductTxMockRecorder) InsertSession(session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertSession", reflect.TypeOf((*MockProductTx)(nil).InsertSession), session)
+}
+
+// Ping mocks base method.
+func (m *MockProductTx) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockProductTxMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockProductTx)(nil).Ping))
+}
+
+// ReserveProducts mocks base method.
+func (m *MockProductTx) ReserveProducts(order map[string]int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveProducts", order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReserveProducts indicates an expected call of ReserveProducts.
+func (mr *MockProductTxMockRecorder) ReserveProducts(order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveProducts", reflect.TypeOf((*MockProductTx)(nil).ReserveProducts), order)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockProductTx) RevokeAPIKey(id int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockProductTxMockRecorder) RevokeAPIKey(id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockProductTx)(nil).RevokeAPIKey), id, at)
+}
+
+// Rollback mocks base method.
+func (m *MockProductTx) Rollback() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rollback indicates an expected call of Rollback.
+func (mr *MockProductTxMockRecorder) Rollback() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockProductTx)(nil).Rollback))
+}
+
+// SaveIdempotencyResponse mocks base method.
+func (m *MockProductTx) SaveIdempotencyResponse(key database.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotencyResponse", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotencyResponse indicates an expected call of SaveIdempotencyResponse.
+func (mr *MockProductTxMockRecorder) SaveIdempotencyResponse(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotencyResponse", reflect.TypeOf((*MockProductTx)(nil).SaveIdempotencyResponse), key)
+}
+
+// SelectAPIKey mocks base method.
+func (m *MockProductTx) SelectAPIKey(keyHash string) (*database.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectAPIKey", keyHash)
+	ret0, _ := ret[0].(*database.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectAPIKey indicates an expected call of SelectAPIKey.
+func (mr *MockProductTxMockRecorder) SelectAPIKey(keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAPIKey", reflect.TypeOf((*MockProductTx)(nil).SelectAPIKey), keyHash)
+}
+
+// SelectAPIKeys mocks base method.
+func (m *MockProductTx) SelectAPIKeys() ([]database.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectAPIKeys")
+	ret0, _ := ret[0].([]database.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectAPIKeys indicates an expected call of SelectAPIKeys.
+func (mr *MockProductTxMockRecorder) SelectAPIKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAPIKeys", reflect.TypeOf((*MockProductTx)(nil).SelectAPIKeys))
+}
+
+// SelectActiveDiscounts mocks base method.
+func (m *MockProductTx) SelectActiveDiscounts(promoCode string, at time.Time) ([]database.Discount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectActiveDiscounts", promoCode, at)
+	ret0, _ := ret[0].([]database.Discount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectActiveDiscounts indicates an expected call of SelectActiveDiscounts.
+func (mr *MockProductTxMockRecorder) SelectActiveDiscounts(promoCode, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectActiveDiscounts", reflect.TypeOf((*MockProductTx)(nil).SelectActiveDiscounts), promoCode, at)
+}
+
+// SelectCheckByID mocks base method.
+func (m *MockProductTx) SelectCheckByID(checkID int64) (*database.Check, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectCheckByID", checkID)
+	ret0, _ := ret[0].(*database.Check)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectCheckByID indicates an expected call of SelectCheckByID.
+func (mr *MockProductTxMockRecorder) SelectCheckByID(checkID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectCheckByID", reflect.TypeOf((*MockProductTx)(nil).SelectCheckByID), checkID)
+}
+
+// SelectChecks mocks base method.
+func (m *MockProductTx) SelectChecks(filter database.CheckFilter) ([]database.Check, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectChecks", filter)
+	ret0, _ := ret[0].([]database.Check)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectChecks indicates an expected call of SelectChecks.
+func (mr *MockProductTxMockRecorder) SelectChecks(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectChecks", reflect.TypeOf((*MockProductTx)(nil).SelectChecks), filter)
+}
+
+// SelectCustomerByEmail mocks base method.
+func (m *MockProductTx) SelectCustomerByEmail(email string) (*database.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectCustomerByEmail", email)
+	ret0, _ := ret[0].(*database.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectCustomerByEmail indicates an expected call of SelectCustomerByEmail.
+func (mr *MockProductTxMockRecorder) SelectCustomerByEmail(email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectCustomerByEmail", reflect.TypeOf((*MockProductTx)(nil).SelectCustomerByEmail), email)
+}
+
+// SelectDiscounts mocks base method.
+func (m *MockProductTx) SelectDiscounts() ([]database.Discount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectDiscounts")
+	ret0, _ := ret[0].([]database.Discount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectDiscounts indicates an expected call of SelectDiscounts.
+func (mr *MockProductTxMockRecorder) SelectDiscounts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectDiscounts", reflect.TypeOf((*MockProductTx)(nil).SelectDiscounts))
+}
+
+// SelectIdempotencyKey mocks base method.
+func (m *MockProductTx) SelectIdempotencyKey(key string) (*database.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectIdempotencyKey", key)
+	ret0, _ := ret[0].(*database.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectIdempotencyKey indicates an expected call of SelectIdempotencyKey.
+func (mr *MockProductTxMockRecorder) SelectIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectIdempotencyKey", reflect.TypeOf((*MockProductTx)(nil).SelectIdempotencyKey), key)
+}
+
+// SelectProductByName mocks base method.
+func (m *MockProductTx) SelectProductByName(productName string) (*database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProductByName", productName)
+	ret0, _ := ret[0].(*database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProductByName indicates an expected call of SelectProductByName.
+func (mr *MockProductTxMockRecorder) SelectProductByName(productName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProductByName", reflect.TypeOf((*MockProductTx)(nil).SelectProductByName), productName)
+}
+
+// SelectProductForUpdate mocks base method.
+func (m *MockProductTx) SelectProductForUpdate(productName string) (*database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProductForUpdate", productName)
+	ret0, _ := ret[0].(*database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProductForUpdate indicates an expected call of SelectProductForUpdate.
+func (mr *MockProductTxMockRecorder) SelectProductForUpdate(productName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProductForUpdate", reflect.TypeOf((*MockProductTx)(nil).SelectProductForUpdate), productName)
+}
+
+// SelectProducts mocks base method.
+func (m *MockProductTx) SelectProducts() ([]database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProducts")
+	ret0, _ := ret[0].([]database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProducts indicates an expected call of SelectProducts.
+func (mr *MockProductTxMockRecorder) SelectProducts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProducts", reflect.TypeOf((*MockProductTx)(nil).SelectProducts))
+}
+
+// SelectProductsByNames mocks base method.
+func (m *MockProductTx) SelectProductsByNames(productNames []string) ([]database.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectProductsByNames", productNames)
+	ret0, _ := ret[0].([]database.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectProductsByNames indicates an expected call of SelectProductsByNames.
+func (mr *MockProductTxMockRecorder) SelectProductsByNames(productNames interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectProductsByNames", reflect.TypeOf((*MockProductTx)(nil).SelectProductsByNames), productNames)
+}
+
+// SelectSession mocks base method.
+func (m *MockProductTx) SelectSession(tokenHash string, at time.Time) (*database.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectSession", tokenHash, at)
+	ret0, _ := ret[0].(*database.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectSession indicates an expected call of SelectSession.
+func (mr *MockProductTxMockRecorder) SelectSession(tokenHash, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectSession", reflect.TypeOf((*MockProductTx)(nil).SelectSession), tokenHash, at)
+}
+
+// SelectStock mocks base method.
+func (m *MockProductTx) SelectStock(productNames []string) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectStock", productNames)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectStock indicates an expected call of SelectStock.
+func (mr *MockProductTxMockRecorder) SelectStock(productNames interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectStock", reflect.TypeOf((*MockProductTx)(nil).SelectStock), productNames)
+}
+
+// UpdateProduct mocks base method.
+func (m *MockProductTx) UpdateProduct(productName string, product database.Product) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProduct", productName, product)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProduct indicates an expected call of UpdateProduct.
+func (mr *MockProductTxMockRecorder) UpdateProduct(productName, product interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockProductTx)(nil).UpdateProduct), productName, product)
+}
@@ -1,13 +1,26 @@
 package database
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/delonce/apishop/pkg/money"
+)
+
+// Tax category of product that was created without it
+const DefaultTaxCategory = "standard"
+
+// Name of tax category in config of rates and in products, e.g. standard or reduced_food
+var TaxCategoryName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
 
 // table product
 type Product struct {
-	ID     int64
-	Name   string
-	Cost   int64
-	Amount int64
+	ID          int64
+	Name        string
+	Cost        money.Money // Every product has its own currency
+	Amount      int64
+	TaxCategory string // Rate of category is set in config, storage writes DefaultTaxCategory instead of empty one
 }
 
 // link table between product and check
@@ -16,6 +29,17 @@ type Order struct {
 	CheckID   int64
 	ProductID int64
 	ReqAmount int64
+
+	// Tax of position at the moment of purchase, rate is in basis points (2000 is 20%)
+	TaxCategory string
+	TaxRate     int64
+	Tax         money.Money
+
+	// Snapshot of product at the moment of purchase, later changes of product don't touch it
+	ProductName string
+	ProductCost money.Money // Cost of one unit
+	LineTotal   money.Money // ProductCost * ReqAmount, before discounts
+	Discount    money.Money // Discounts of position and its part of discounts of cart, not more than LineTotal
 }
 
 // table check
@@ -24,4 +48,118 @@ type Check struct {
 	PurchaseList []Order
 	IsConfirmed  bool
 	DateAt       time.Time
+	CustomerID   int64 // 0 for anonymous purchase
+
+	// Written at purchase, Total is Subtotal + Tax
+	Subtotal money.Money
+	Tax      money.Money
+	Total    money.Money
+}
+
+// Conditions of reading list of checks, nil fields don't filter anything
+type CheckFilter struct {
+	From        *time.Time // Including
+	To          *time.Time // Excluding
+	IsConfirmed *bool
+	CustomerID  *int64
+
+	Limit  int64
+	Offset int64
+}
+
+// Kinds of discount rules
+const (
+	DiscountPercent  = "percent"     // Percent of cost
+	DiscountFixed    = "fixed"       // Fixed amount, but not more than cost
+	DiscountBuyXGetY = "buy_x_get_y" // Every Buy+Free units Free of them cost nothing, only for product
+)
+
+// table discount
+type Discount struct {
+	ID        int64
+	PromoCode string // Empty for discount that is applied to every order
+	Kind      string // One of Discount* constants
+	Product   string // Name of product, empty for discount of the whole cart
+
+	// Only fields of Kind are set
+	Percent int64
+	Fixed   money.Money
+	Buy     int64
+	Free    int64
+
+	// Discount works from StartsAt (including) to EndsAt (excluding), nil is no limit
+	StartsAt *time.Time
+	EndsAt   *time.Time
+}
+
+// table customer
+type Customer struct {
+	ID           int64
+	Email        string // Unique, kept in lower case
+	PasswordHash string // bcrypt hash, password itself isn't kept
+	CreatedAt    time.Time
+}
+
+// table session
+// Token is shown to customer only once, storage keeps its hash
+type Session struct {
+	TokenHash  string
+	CustomerID int64
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// Role of API key, every role can do everything that lower roles can
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleCustomer: 1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q, use %s, %s or %s", name, RoleCustomer, RoleOperator, RoleAdmin)
+	}
+
+	return role, nil
+}
+
+// Admin is allowed to use routes of operator, but not vice versa
+func (role Role) Allows(required Role) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// table api_key
+// Key is shown only once when it is created, storage keeps its hash and a few first symbols
+type APIKey struct {
+	ID        int64
+	Name      string // Who uses key, e.g. "back-office"
+	Prefix    string // Start of key, it helps to find key in list
+	KeyHash   string
+	Role      Role
+	CreatedAt time.Time
+	RevokedAt *time.Time // nil while key works
+}
+
+// table idempotency_key
+// Remembers reply to purchase query, so retried query doesn't create second check
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	CheckID     int64  // 0 if query didn't create check
+	StatusCode  int    // 0 while first query is in progress
+	Response    []byte // nil while first query is in progress
+	// Headers of reply that are sent with it again, e.g. Content-Type and Retry-After
+	Headers   map[string]string
+	CreatedAt time.Time
 }
@@ -0,0 +1,121 @@
+package pgmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgx/v4"
+)
+
+func (pgdb *postgresDB) InsertAPIKey(key database.APIKey) (int64, error) {
+	queryString := `
+		INSERT INTO api_key
+			(name, prefix, key_hash, role, created_at)
+		VALUES
+			($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, key.Name, key.Prefix, key.KeyHash, string(key.Role),
+		key.CreatedAt).Scan(&key.ID)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying insert API key, error: %v", err)
+		return 0, err
+	}
+
+	return key.ID, nil
+}
+
+func (pgdb *postgresDB) SelectAPIKey(keyHash string) (*database.APIKey, error) {
+	// Revoked key is the same as unknown one
+	queryString := `
+		SELECT id, name, prefix, key_hash, role, created_at, revoked_at
+		FROM api_key WHERE key_hash = $1 AND revoked_at IS NULL
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+
+	key := database.APIKey{}
+	var role string
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, keyHash).Scan(scanAPIKey(&key, &role)...)
+	key.Role = database.Role(role)
+
+	if err != nil {
+		// Key isn't logged, it is a secret
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrAPIKeyNotFound
+		}
+
+		pgdb.logger.Errorf("error when trying select API key, error: %v", err)
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (pgdb *postgresDB) SelectAPIKeys() ([]database.APIKey, error) {
+	queryString := `
+		SELECT id, name, prefix, key_hash, role, created_at, revoked_at
+		FROM api_key ORDER BY id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select API keys, error: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []database.APIKey{}
+
+	for rows.Next() {
+		key := database.APIKey{}
+		var role string
+
+		if err := rows.Scan(scanAPIKey(&key, &role)...); err != nil {
+			pgdb.logger.Errorf("error when trying scan API key, error: %v", err)
+			return nil, err
+		}
+
+		key.Role = database.Role(role)
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select API keys, error: %v", err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (pgdb *postgresDB) RevokeAPIKey(id int64, at time.Time) error {
+	queryString := `
+		UPDATE api_key SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, id, at)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying revoke API key, error: %v", err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w, id: %d", database.ErrAPIKeyNotFound, id)
+	}
+
+	return nil
+}
+
+func scanAPIKey(key *database.APIKey, role *string) []interface{} {
+	return []interface{}{&key.ID, &key.Name, &key.Prefix, &key.KeyHash, role, &key.CreatedAt, &key.RevokedAt}
+}
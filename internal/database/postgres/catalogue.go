@@ -0,0 +1,211 @@
+package pgmanager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgconn"
+)
+
+// Codes of postgres errors that are expected by catalogue management
+const (
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+)
+
+func (pgdb *postgresDB) SelectProducts() ([]database.Product, error) {
+	queryString := `
+		SELECT id, name, cost, currency, amount, tax_category FROM product ORDER BY name
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select products, error: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	products := []database.Product{}
+
+	for rows.Next() {
+		product := database.Product{}
+
+		if err = rows.Scan(&product.ID, &product.Name, &product.Cost.Amount, &product.Cost.Currency, &product.Amount,
+			&product.TaxCategory); err != nil {
+			pgdb.logger.Errorf("error when trying scan product, error: %v", err)
+			return nil, err
+		}
+
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select products, error: %v", err)
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (pgdb *postgresDB) SelectProductsByNames(productNames []string) ([]database.Product, error) {
+	queryString := `
+		SELECT id, name, cost, currency, amount, tax_category FROM product WHERE name = ANY($1) ORDER BY name
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString, productNames)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select products by names, error: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	products := []database.Product{}
+
+	for rows.Next() {
+		product := database.Product{}
+
+		if err = rows.Scan(&product.ID, &product.Name, &product.Cost.Amount, &product.Cost.Currency, &product.Amount,
+			&product.TaxCategory); err != nil {
+			pgdb.logger.Errorf("error when trying scan product, error: %v", err)
+			return nil, err
+		}
+
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select products by names, error: %v", err)
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (pgdb *postgresDB) SelectStock(productNames []string) (map[string]int64, error) {
+	queryString := `
+		SELECT name, amount FROM product WHERE name = ANY($1)
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString, productNames)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select stock, error: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	stock := make(map[string]int64, len(productNames))
+
+	for rows.Next() {
+		var name string
+		var amount int64
+
+		if err = rows.Scan(&name, &amount); err != nil {
+			pgdb.logger.Errorf("error when trying scan stock, error: %v", err)
+			return nil, err
+		}
+
+		stock[name] = amount
+	}
+
+	if err = rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select stock, error: %v", err)
+		return nil, err
+	}
+
+	return stock, nil
+}
+
+func (pgdb *postgresDB) InsertProduct(product database.Product) (int64, error) {
+	// Empty tax category is default one
+	queryString := `
+		INSERT INTO product
+			(name, cost, currency, amount, tax_category)
+		VALUES
+			($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'standard'))
+		RETURNING id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, product.Name, product.Cost.Amount, product.Cost.Currency, product.Amount,
+		product.TaxCategory).Scan(&product.ID)
+
+	if err != nil {
+		if isPgError(err, uniqueViolationCode) {
+			return 0, fmt.Errorf("%w, name: %s", database.ErrProductExists, product.Name)
+		}
+
+		pgdb.logger.Errorf("error when trying insert product %s, error: %v", product.Name, err)
+		return 0, err
+	}
+
+	return product.ID, nil
+}
+
+func (pgdb *postgresDB) UpdateProduct(productName string, product database.Product) error {
+	// Name can be changed too, id stays the same so checks keep their positions
+	queryString := `
+		UPDATE product
+		SET name = $2, cost = $3, currency = $4, amount = $5, tax_category = COALESCE(NULLIF($6, ''), 'standard')
+		WHERE name = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, productName, product.Name, product.Cost.Amount, product.Cost.Currency, product.Amount,
+		product.TaxCategory)
+
+	if err != nil {
+		if isPgError(err, uniqueViolationCode) {
+			return fmt.Errorf("%w, name: %s", database.ErrProductExists, product.Name)
+		}
+
+		pgdb.logger.Errorf("error when trying update product %s, error: %v", productName, err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, productName)
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) DeleteProduct(productName string) error {
+	queryString := `
+		DELETE FROM product WHERE name = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, productName)
+
+	if err != nil {
+		if isPgError(err, foreignKeyViolationCode) {
+			return fmt.Errorf("%w, name: %s", database.ErrProductInUse, productName)
+		}
+
+		pgdb.logger.Errorf("error when trying delete product %s, error: %v", productName, err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, productName)
+	}
+
+	return nil
+}
+
+func isPgError(err error, code string) bool {
+	// Checks that postgres rejected query with some specific error code
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
@@ -0,0 +1,153 @@
+package pgmanager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgx/v4"
+)
+
+// Columns of check in order of scanCheck, all sums are in currency of check
+const checkColumns = `id, is_confirmed, date, COALESCE(customer_id, 0), subtotal, currency, tax, currency, total, currency`
+
+func scanCheck(purchCheck *database.Check) []interface{} {
+	return []interface{}{&purchCheck.ID, &purchCheck.IsConfirmed, &purchCheck.DateAt, &purchCheck.CustomerID,
+		&purchCheck.Subtotal.Amount, &purchCheck.Subtotal.Currency, &purchCheck.Tax.Amount, &purchCheck.Tax.Currency,
+		&purchCheck.Total.Amount, &purchCheck.Total.Currency}
+}
+
+func (pgdb *postgresDB) SelectCheckByID(checkID int64) (*database.Check, error) {
+	queryString := `
+		SELECT ` + checkColumns + ` FROM "check" WHERE id = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+
+	purchCheck := database.Check{}
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, checkID).Scan(scanCheck(&purchCheck)...)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w, id: %d", database.ErrCheckNotFound, checkID)
+		}
+
+		pgdb.logger.Errorf("error when trying select check %d, error: %v", checkID, err)
+		return nil, err
+	}
+
+	positions, err := pgdb.selectPositions([]int64{checkID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	purchCheck.PurchaseList = positions[checkID]
+
+	return &purchCheck, nil
+}
+
+func (pgdb *postgresDB) SelectChecks(filter database.CheckFilter) ([]database.Check, error) {
+	// NULL parameter switches off its condition
+	queryString := `
+		SELECT ` + checkColumns + ` FROM "check"
+		WHERE ($1::timestamptz IS NULL OR date >= $1)
+			AND ($2::timestamptz IS NULL OR date < $2)
+			AND ($3::boolean IS NULL OR is_confirmed = $3)
+			AND ($4::bigint IS NULL OR customer_id = $4)
+		ORDER BY date DESC, id DESC
+		LIMIT $5 OFFSET $6
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString, filter.From, filter.To, filter.IsConfirmed, filter.CustomerID,
+		filter.Limit, filter.Offset)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select checks, error: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	checks := []database.Check{}
+	checkIDs := []int64{}
+
+	for rows.Next() {
+		purchCheck := database.Check{}
+
+		if err = rows.Scan(scanCheck(&purchCheck)...); err != nil {
+			pgdb.logger.Errorf("error when trying scan check, error: %v", err)
+			return nil, err
+		}
+
+		checks = append(checks, purchCheck)
+		checkIDs = append(checkIDs, purchCheck.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select checks, error: %v", err)
+		return nil, err
+	}
+
+	// One query for positions of the whole page instead of query per check
+	positions, err := pgdb.selectPositions(checkIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range checks {
+		checks[i].PurchaseList = positions[checks[i].ID]
+	}
+
+	return checks, nil
+}
+
+func (pgdb *postgresDB) selectPositions(checkIDs []int64) (map[int64][]database.Order, error) {
+	// Returns positions grouped by check id
+	// Product isn't joined, position keeps its name and cost from the moment of purchase
+	queryString := `
+		SELECT o.id, o.check_id, o.product_id, o.req_amount, o.tax_category, o.tax_rate, o.tax, c.currency,
+			o.product_name, o.unit_cost, c.currency, o.line_total, c.currency, o.discount, c.currency
+		FROM "order" o
+		JOIN "check" c ON c.id = o.check_id
+		WHERE o.check_id = ANY($1)
+		ORDER BY o.check_id, o.product_name
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString, checkIDs)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select positions, error: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	positions := map[int64][]database.Order{}
+
+	for rows.Next() {
+		position := database.Order{}
+
+		err = rows.Scan(&position.ID, &position.CheckID, &position.ProductID, &position.ReqAmount,
+			&position.TaxCategory, &position.TaxRate, &position.Tax.Amount, &position.Tax.Currency,
+			&position.ProductName, &position.ProductCost.Amount, &position.ProductCost.Currency, &position.LineTotal.Amount, &position.LineTotal.Currency,
+			&position.Discount.Amount, &position.Discount.Currency)
+
+		if err != nil {
+			pgdb.logger.Errorf("error when trying scan position, error: %v", err)
+			return nil, err
+		}
+
+		positions[position.CheckID] = append(positions[position.CheckID], position)
+	}
+
+	if err = rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select positions, error: %v", err)
+		return nil, err
+	}
+
+	return positions, nil
+}
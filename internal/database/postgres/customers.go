@@ -0,0 +1,134 @@
+package pgmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgx/v4"
+)
+
+func (pgdb *postgresDB) InsertCustomer(customer database.Customer) (int64, error) {
+	queryString := `
+		INSERT INTO customer
+			(email, password_hash, created_at)
+		VALUES
+			($1, $2, $3)
+		RETURNING id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, customer.Email, customer.PasswordHash, customer.CreatedAt).Scan(&customer.ID)
+
+	if err != nil {
+		if isPgError(err, uniqueViolationCode) {
+			return 0, fmt.Errorf("%w, email: %s", database.ErrCustomerExists, customer.Email)
+		}
+
+		pgdb.logger.Errorf("error when trying insert customer, error: %v", err)
+		return 0, err
+	}
+
+	return customer.ID, nil
+}
+
+func (pgdb *postgresDB) SelectCustomerByEmail(email string) (*database.Customer, error) {
+	queryString := `
+		SELECT id, email, password_hash, created_at FROM customer WHERE email = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+
+	customer := database.Customer{}
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, email).Scan(&customer.ID, &customer.Email,
+		&customer.PasswordHash, &customer.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w, email: %s", database.ErrCustomerNotFound, email)
+		}
+
+		pgdb.logger.Errorf("error when trying select customer, error: %v", err)
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+func (pgdb *postgresDB) InsertSession(session database.Session) error {
+	queryString := `
+		INSERT INTO session
+			(token_hash, customer_id, created_at, expires_at)
+		VALUES
+			($1, $2, $3, $4)
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	_, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, session.TokenHash, session.CustomerID, session.CreatedAt, session.ExpiresAt)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying insert session, error: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) SelectSession(tokenHash string, at time.Time) (*database.Session, error) {
+	// Expired session is the same as unknown one
+	queryString := `
+		SELECT token_hash, customer_id, created_at, expires_at
+		FROM session WHERE token_hash = $1 AND expires_at > $2
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+
+	session := database.Session{}
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, tokenHash, at).Scan(&session.TokenHash, &session.CustomerID,
+		&session.CreatedAt, &session.ExpiresAt)
+
+	if err != nil {
+		// Token isn't logged, it is a secret of customer
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, database.ErrSessionNotFound
+		}
+
+		pgdb.logger.Errorf("error when trying select session, error: %v", err)
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (pgdb *postgresDB) DeleteSession(tokenHash string) error {
+	queryString := `
+		DELETE FROM session WHERE token_hash = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	_, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, tokenHash)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying delete session, error: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) DeleteExpiredSessions(at time.Time) (int64, error) {
+	queryString := `
+		DELETE FROM session WHERE expires_at <= $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, at)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying delete expired sessions, error: %v", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,133 @@
+package pgmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgx/v4"
+)
+
+// Columns of discount joined with name of its product
+const discountColumns = `
+	d.id, d.promo_code, d.kind, COALESCE(p.name, ''), d.percent, d.fixed_amount, d.fixed_currency,
+	d.buy_amount, d.free_amount, d.starts_at, d.ends_at
+`
+
+func (pgdb *postgresDB) SelectActiveDiscounts(promoCode string, at time.Time) ([]database.Discount, error) {
+	queryString := `
+		SELECT ` + discountColumns + `
+		FROM discount d
+		LEFT JOIN product p ON p.id = d.product_id
+		WHERE (d.promo_code = '' OR d.promo_code = $1)
+			AND (d.starts_at IS NULL OR d.starts_at <= $2)
+			AND (d.ends_at IS NULL OR d.ends_at > $2)
+		ORDER BY d.id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString, promoCode, at)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select active discounts, error: %v", err)
+		return nil, err
+	}
+
+	return pgdb.scanDiscounts(rows)
+}
+
+func (pgdb *postgresDB) SelectDiscounts() ([]database.Discount, error) {
+	queryString := `
+		SELECT ` + discountColumns + `
+		FROM discount d
+		LEFT JOIN product p ON p.id = d.product_id
+		ORDER BY d.id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	rows, err := pgdb.dbmanager.Query(pgdb.ctx, queryString)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying select discounts, error: %v", err)
+		return nil, err
+	}
+
+	return pgdb.scanDiscounts(rows)
+}
+
+func (pgdb *postgresDB) InsertDiscount(discount database.Discount) (int64, error) {
+	// Nothing is inserted if product is sent, but it doesn't exist
+	queryString := `
+		INSERT INTO discount
+			(promo_code, kind, product_id, percent, fixed_amount, fixed_currency, buy_amount, free_amount, starts_at, ends_at)
+		SELECT $1::text, $2::text, p.id, $4::bigint, $5::bigint, $6::text, $7::bigint, $8::bigint, $9::timestamptz, $10::timestamptz
+		FROM (SELECT 1) AS one
+		LEFT JOIN product p ON p.name = $3::text
+		WHERE $3::text = '' OR p.id IS NOT NULL
+		RETURNING id
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, discount.PromoCode, discount.Kind, discount.Product,
+		discount.Percent, discount.Fixed.Amount, discount.Fixed.Currency, discount.Buy, discount.Free,
+		discount.StartsAt, discount.EndsAt).Scan(&discount.ID)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w, name: %s", database.ErrProductNotFound, discount.Product)
+		}
+
+		pgdb.logger.Errorf("error when trying insert discount, error: %v", err)
+		return 0, err
+	}
+
+	return discount.ID, nil
+}
+
+func (pgdb *postgresDB) DeleteDiscount(discountID int64) error {
+	queryString := `
+		DELETE FROM discount WHERE id = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, discountID)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying delete discount %d, error: %v", discountID, err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w, id: %d", database.ErrDiscountNotFound, discountID)
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) scanDiscounts(rows pgx.Rows) ([]database.Discount, error) {
+	defer rows.Close()
+
+	discounts := []database.Discount{}
+
+	for rows.Next() {
+		discount := database.Discount{}
+
+		err := rows.Scan(&discount.ID, &discount.PromoCode, &discount.Kind, &discount.Product, &discount.Percent,
+			&discount.Fixed.Amount, &discount.Fixed.Currency, &discount.Buy, &discount.Free, &discount.StartsAt, &discount.EndsAt)
+
+		if err != nil {
+			pgdb.logger.Errorf("error when trying scan discount, error: %v", err)
+			return nil, err
+		}
+
+		discounts = append(discounts, discount)
+	}
+
+	if err := rows.Err(); err != nil {
+		pgdb.logger.Errorf("error when trying select discounts, error: %v", err)
+		return nil, err
+	}
+
+	return discounts, nil
+}
@@ -0,0 +1,110 @@
+package pgmanager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Error of connection to database, not of query itself
+// It is database.ErrStorageUnavailable for callers, original error is kept for logs and errors.As
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return database.ErrStorageUnavailable.Error() + ": " + e.err.Error()
+}
+
+func (e *unavailableError) Is(target error) bool {
+	return target == database.ErrStorageUnavailable
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, database.ErrStorageUnavailable) || !isUnavailable(err) {
+		return err
+	}
+
+	return &unavailableError{err: err}
+}
+
+func isUnavailable(err error) bool {
+	var netErr net.Error
+
+	switch {
+	case pgconn.SafeToRetry(err), errors.As(err, &netErr), pgconn.Timeout(err):
+		// Query wasn't sent (e.g. connection refused), was lost on network or timed out
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, context.Canceled):
+		// Connection was closed in the middle of query or application is stopping
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection exception, others are restarts and overload of server
+		switch {
+		case len(pgErr.Code) == 5 && pgErr.Code[:2] == "08":
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03", pgErr.Code == "53300":
+			return true
+		}
+	}
+
+	return false
+}
+
+// Querier marking connection errors, so storage methods don't have to check them one by one
+type classifyingQuerier struct {
+	querier pgxQuerier
+}
+
+func (q classifyingQuerier) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := q.querier.Begin(ctx)
+	return tx, classifyError(err)
+}
+
+func (q classifyingQuerier) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := q.querier.Exec(ctx, sql, arguments...)
+	return tag, classifyError(err)
+}
+
+func (q classifyingQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := q.querier.Query(ctx, sql, args...)
+
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	return classifyingRows{Rows: rows}, nil
+}
+
+func (q classifyingQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return classifyingRow{row: q.querier.QueryRow(ctx, sql, args...)}
+}
+
+type classifyingRows struct {
+	pgx.Rows
+}
+
+func (rows classifyingRows) Err() error {
+	return classifyError(rows.Rows.Err())
+}
+
+type classifyingRow struct {
+	row pgx.Row
+}
+
+func (row classifyingRow) Scan(dest ...interface{}) error {
+	return classifyError(row.row.Scan(dest...))
+}
@@ -0,0 +1,77 @@
+package pgmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	testTable := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{
+			name:        "Connection refused",
+			err:         &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			unavailable: true,
+		},
+
+		{
+			name:        "Connection lost",
+			err:         fmt.Errorf("read: %w", io.ErrUnexpectedEOF),
+			unavailable: true,
+		},
+
+		{
+			name:        "Timeout",
+			err:         context.DeadlineExceeded,
+			unavailable: true,
+		},
+
+		{
+			name:        "Server is restarting",
+			err:         &pgconn.PgError{Code: "57P03"},
+			unavailable: true,
+		},
+
+		{
+			name:        "Connection exception",
+			err:         &pgconn.PgError{Code: "08006"},
+			unavailable: true,
+		},
+
+		{
+			name:        "Constraint of table",
+			err:         &pgconn.PgError{Code: "23505"},
+			unavailable: false,
+		},
+
+		{
+			name:        "No rows",
+			err:         pgx.ErrNoRows,
+			unavailable: false,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := classifyError(testCase.err)
+
+			assert.Equal(t, testCase.unavailable, errors.Is(err, database.ErrStorageUnavailable))
+			// Original error is still available for logs and checks
+			assert.True(t, errors.Is(err, testCase.err))
+		})
+	}
+
+	assert.Nil(t, classifyError(nil))
+}
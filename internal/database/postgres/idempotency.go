@@ -0,0 +1,113 @@
+package pgmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgx/v4"
+)
+
+func (pgdb *postgresDB) ClaimIdempotencyKey(key database.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	// Expired key is taken by new query as if it didn't exist
+	queryString := `
+		INSERT INTO idempotency_key
+			(key, request_hash, created_at)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, created_at = EXCLUDED.created_at,
+			check_id = NULL, status_code = NULL, response = NULL
+		WHERE idempotency_key.created_at < $4
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, key.Key, key.RequestHash, key.CreatedAt, expiredBefore)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying claim idempotency key, error: %v", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (pgdb *postgresDB) SelectIdempotencyKey(key string) (*database.IdempotencyKey, error) {
+	queryString := `
+		SELECT key, request_hash, COALESCE(check_id, 0), COALESCE(status_code, 0), response,
+			COALESCE(response_headers, '{}'), created_at
+		FROM idempotency_key WHERE key = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+
+	idemKey := database.IdempotencyKey{}
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, key).Scan(&idemKey.Key, &idemKey.RequestHash,
+		&idemKey.CheckID, &idemKey.StatusCode, &idemKey.Response, &idemKey.Headers, &idemKey.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w, key: %s", database.ErrIdempotencyKeyNotFound, key)
+		}
+
+		pgdb.logger.Errorf("error when trying select idempotency key, error: %v", err)
+		return nil, err
+	}
+
+	return &idemKey, nil
+}
+
+func (pgdb *postgresDB) SaveIdempotencyResponse(key database.IdempotencyKey) error {
+	queryString := `
+		UPDATE idempotency_key
+		SET check_id = NULLIF($2, 0), status_code = $3, response = $4, response_headers = $5
+		WHERE key = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, key.Key, key.CheckID, key.StatusCode, key.Response, key.Headers)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying save idempotent response, error: %v", err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w, key: %s", database.ErrIdempotencyKeyNotFound, key.Key)
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) DeleteIdempotencyKey(key string) error {
+	queryString := `
+		DELETE FROM idempotency_key WHERE key = $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	_, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, key)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying delete idempotency key, error: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	queryString := `
+		DELETE FROM idempotency_key WHERE created_at < $1
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+	tag, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, expiredBefore)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying delete expired idempotency keys, error: %v", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
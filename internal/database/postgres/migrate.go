@@ -0,0 +1,325 @@
+package pgmanager
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/delonce/apishop/pkg/logging"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Key of advisory lock, all instances of application use the same one
+// So only one of them migrates schema, others wait and find nothing to do
+const migrationLockID int64 = 7_015_870_345_017
+
+// File name is version, name and direction: 0001_create_purchase_tables.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	logger     *logging.Logger
+	migrations []Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool, logger *logging.Logger) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		pool:       pool,
+		logger:     logger,
+		migrations: migrations,
+	}, nil
+}
+
+func (migrator *Migrator) Up(ctx context.Context) error {
+	// Applies every migration that isn't applied yet
+	return migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		pending := planUp(migrator.migrations, applied)
+
+		if len(pending) == 0 {
+			migrator.logger.Info("Database schema is up to date")
+			return nil
+		}
+
+		for _, migration := range pending {
+			migrator.logger.Infof("Applying migration %04d_%s", migration.Version, migration.Name)
+
+			err := applyMigration(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (migrator *Migrator) Down(ctx context.Context, steps int) error {
+	// Reverts last applied migrations, newest first
+	return migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		reverted, err := planDown(migrator.migrations, applied, steps)
+
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range reverted {
+			migrator.logger.Infof("Reverting migration %04d_%s", migration.Version, migration.Name)
+
+			err := applyMigration(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (migrator *Migrator) Version(ctx context.Context) (int64, error) {
+	// Newest applied migration, 0 if there isn't any
+	var version int64
+
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+
+		for v := range applied {
+			if v > version {
+				version = v
+			}
+		}
+
+		return err
+	})
+
+	return version, err
+}
+
+func (migrator *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	// Advisory lock belongs to session, so the whole run uses one connection
+	conn, err := migrator.pool.Acquire(ctx)
+
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("take migration lock: %w", err)
+	}
+
+	defer func() {
+		// Lock has to be released even if ctx is already cancelled
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			migrator.logger.Errorf("error when trying release migration lock, error: %v", err)
+		}
+	}()
+
+	queryString := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT now()
+		)
+	`
+
+	migrator.logger.Trace("SQL Query: ", queryString)
+
+	if _, err := conn.Exec(ctx, queryString); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]bool, error) {
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int64]bool{}
+
+	for rows.Next() {
+		var version int64
+
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, script string, bookkeeping string, args ...interface{}) error {
+	// Schema change and its record in schema_migrations are done together or not done at all
+	tx, err := conn.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// Query without arguments is sent by simple protocol, so script can have several statements
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+
+		if match == nil {
+			return nil, fmt.Errorf("wrong name of migration file %s", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		// Every migration can be reverted
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s should have both up and down files", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func planUp(migrations []Migration, applied map[int64]bool) []Migration {
+	pending := []Migration{}
+
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending
+}
+
+func planDown(migrations []Migration, applied map[int64]bool, steps int) ([]Migration, error) {
+	known := make(map[int64]Migration, len(migrations))
+
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	versions := make([]int64, 0, len(applied))
+
+	for version := range applied {
+		versions = append(versions, version)
+	}
+
+	// Newest migration is reverted first
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+
+	if steps > len(versions) {
+		steps = len(versions)
+	}
+
+	reverted := make([]Migration, 0, steps)
+
+	for _, version := range versions[:steps] {
+		migration, ok := known[version]
+
+		// Database was migrated by newer version of application
+		if !ok {
+			return nil, fmt.Errorf("migration %d is applied, but this build doesn't know it", version)
+		}
+
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
@@ -0,0 +1,104 @@
+package pgmanager
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	// Migrations shipped with application are valid and go one by one
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+
+	assert.Nil(t, err)
+
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	testTable := []struct {
+		name          string
+		files         fstest.MapFS
+		expected      []Migration
+		expectedError error
+	}{
+		{
+			name: "OK",
+			files: fstest.MapFS{
+				"m/0002_second.up.sql":   {Data: []byte("up 2")},
+				"m/0002_second.down.sql": {Data: []byte("down 2")},
+				"m/0001_first.up.sql":    {Data: []byte("up 1")},
+				"m/0001_first.down.sql":  {Data: []byte("down 1")},
+			},
+			expected: []Migration{
+				{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+				{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+			},
+		},
+
+		{
+			name: "Without down",
+			files: fstest.MapFS{
+				"m/0001_first.up.sql": {Data: []byte("up 1")},
+			},
+			expectedError: errors.New("migration 0001_first should have both up and down files"),
+		},
+
+		{
+			name: "Two names",
+			files: fstest.MapFS{
+				"m/0001_first.up.sql":   {Data: []byte("up 1")},
+				"m/0001_other.down.sql": {Data: []byte("down 1")},
+			},
+			expectedError: errors.New("migration 1 has two names: first and other"),
+		},
+
+		{
+			name: "Wrong file name",
+			files: fstest.MapFS{
+				"m/first.sql": {Data: []byte("up 1")},
+			},
+			expectedError: errors.New("wrong name of migration file first.sql"),
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			migrations, err := loadMigrations(testCase.files, "m")
+
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expected, migrations)
+		})
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "first"},
+		{Version: 2, Name: "second"},
+		{Version: 3, Name: "third"},
+	}
+
+	// Only not applied ones, in order of versions
+	assert.Equal(t, []Migration{migrations[1], migrations[2]}, planUp(migrations, map[int64]bool{1: true}))
+	assert.Equal(t, []Migration{}, planUp(migrations, map[int64]bool{1: true, 2: true, 3: true}))
+
+	// Newest first, no more than applied
+	reverted, err := planDown(migrations, map[int64]bool{1: true, 2: true}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []Migration{migrations[1]}, reverted)
+
+	reverted, err = planDown(migrations, map[int64]bool{1: true, 2: true}, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []Migration{migrations[1], migrations[0]}, reverted)
+
+	// Database is newer than application
+	reverted, err = planDown(migrations, map[int64]bool{1: true, 4: true}, 1)
+	assert.Equal(t, errors.New("migration 4 is applied, but this build doesn't know it"), err)
+	assert.Nil(t, reverted)
+}
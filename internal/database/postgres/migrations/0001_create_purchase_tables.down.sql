@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "order";
+DROP TABLE IF EXISTS "check";
+DROP TABLE IF EXISTS product;
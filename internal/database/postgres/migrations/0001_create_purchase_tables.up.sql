@@ -0,0 +1,26 @@
+-- IF NOT EXISTS lets databases created by hand adopt migrations
+CREATE TABLE IF NOT EXISTS product (
+    id     BIGSERIAL PRIMARY KEY,
+    name   TEXT   NOT NULL UNIQUE,
+    cost   BIGINT NOT NULL CHECK (cost >= 0),
+    amount BIGINT NOT NULL CHECK (amount >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS "check" (
+    id           BIGSERIAL PRIMARY KEY,
+    is_confirmed BOOLEAN   NOT NULL,
+    date         TIMESTAMP NOT NULL
+);
+
+-- List of checks is read from the newest one
+CREATE INDEX IF NOT EXISTS check_date_idx ON "check" (date DESC, id DESC);
+
+-- Product can't be deleted while some check refers to it
+CREATE TABLE IF NOT EXISTS "order" (
+    id         BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES product (id),
+    check_id   BIGINT NOT NULL REFERENCES "check" (id) ON DELETE CASCADE,
+    req_amount BIGINT NOT NULL CHECK (req_amount >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS order_check_id_idx ON "order" (check_id);
@@ -0,0 +1,12 @@
+-- Status, response and check are NULL while the first query is in progress
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    key          VARCHAR(255) PRIMARY KEY,
+    request_hash TEXT      NOT NULL,
+    check_id     BIGINT    REFERENCES "check" (id) ON DELETE SET NULL,
+    status_code  INTEGER,
+    response     BYTEA,
+    created_at   TIMESTAMP NOT NULL
+);
+
+-- Expired keys are deleted by created_at
+CREATE INDEX IF NOT EXISTS idempotency_key_created_at_idx ON idempotency_key (created_at);
@@ -0,0 +1,2 @@
+-- Costs written before currencies appeared are in dollars
+ALTER TABLE product ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,23 @@
+-- Rules of pricing, fields that don't belong to kind are zero
+CREATE TABLE IF NOT EXISTS discount (
+    id             BIGSERIAL PRIMARY KEY,
+    promo_code     TEXT   NOT NULL DEFAULT '',
+    kind           TEXT   NOT NULL CHECK (kind IN ('percent', 'fixed', 'buy_x_get_y')),
+    -- NULL for discount of the whole cart, discount is useless without its product
+    product_id     BIGINT REFERENCES product (id) ON DELETE CASCADE,
+    percent        BIGINT NOT NULL DEFAULT 0,
+    fixed_amount   BIGINT NOT NULL DEFAULT 0,
+    fixed_currency TEXT   NOT NULL DEFAULT '',
+    buy_amount     BIGINT NOT NULL DEFAULT 0,
+    free_amount    BIGINT NOT NULL DEFAULT 0,
+    starts_at      TIMESTAMP,
+    ends_at        TIMESTAMP,
+
+    CHECK (kind <> 'percent' OR percent BETWEEN 1 AND 100),
+    CHECK (kind <> 'fixed' OR (fixed_amount > 0 AND fixed_currency ~ '^[A-Z]{3}$')),
+    CHECK (kind <> 'buy_x_get_y' OR (buy_amount > 0 AND free_amount > 0 AND product_id IS NOT NULL)),
+    CHECK (starts_at < ends_at)
+);
+
+-- Every purchase reads discounts without promo code and ones of its promo code
+CREATE INDEX IF NOT EXISTS discount_promo_code_idx ON discount (promo_code);
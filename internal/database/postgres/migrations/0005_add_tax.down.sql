@@ -0,0 +1,3 @@
+ALTER TABLE "order" DROP COLUMN IF EXISTS tax_category, DROP COLUMN IF EXISTS tax_rate, DROP COLUMN IF EXISTS tax;
+ALTER TABLE "check" DROP COLUMN IF EXISTS currency, DROP COLUMN IF EXISTS subtotal, DROP COLUMN IF EXISTS tax, DROP COLUMN IF EXISTS total;
+ALTER TABLE product DROP COLUMN IF EXISTS tax_category;
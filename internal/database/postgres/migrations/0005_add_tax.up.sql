@@ -0,0 +1,28 @@
+-- Rates of categories are set in config of application
+ALTER TABLE product ADD COLUMN IF NOT EXISTS tax_category TEXT NOT NULL DEFAULT 'standard' CHECK (tax_category ~ '^[a-z][a-z0-9_]*$');
+
+-- Sums of check are written at purchase, so they don't depend on later prices and rates
+ALTER TABLE "check"
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$'),
+    ADD COLUMN IF NOT EXISTS subtotal BIGINT  NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax      BIGINT  NOT NULL DEFAULT 0 CHECK (tax >= 0),
+    ADD COLUMN IF NOT EXISTS total    BIGINT  NOT NULL DEFAULT 0;
+
+ALTER TABLE "check" ADD CONSTRAINT check_total_check CHECK (total = subtotal + tax);
+
+-- Rate is in basis points, 2000 is 20%
+ALTER TABLE "order"
+    ADD COLUMN IF NOT EXISTS tax_category TEXT   NOT NULL DEFAULT 'standard',
+    ADD COLUMN IF NOT EXISTS tax_rate     BIGINT NOT NULL DEFAULT 0 CHECK (tax_rate >= 0),
+    ADD COLUMN IF NOT EXISTS tax          BIGINT NOT NULL DEFAULT 0 CHECK (tax >= 0);
+
+-- Checks written before taxes are without tax, their sums are counted from current prices
+UPDATE "check" c
+SET currency = sums.currency, subtotal = sums.total, total = sums.total
+FROM (
+    SELECT o.check_id, MIN(p.currency) AS currency, SUM(o.req_amount * p.cost) AS total
+    FROM "order" o
+    JOIN product p ON p.id = o.product_id
+    GROUP BY o.check_id
+) AS sums
+WHERE sums.check_id = c.id;
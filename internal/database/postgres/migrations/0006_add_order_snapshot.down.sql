@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS order_snapshot_immutable ON "order";
+DROP TRIGGER IF EXISTS check_sums_immutable ON "check";
+DROP FUNCTION IF EXISTS forbid_receipt_change();
+ALTER TABLE "order" DROP COLUMN IF EXISTS product_name, DROP COLUMN IF EXISTS unit_cost, DROP COLUMN IF EXISTS line_total;
@@ -0,0 +1,35 @@
+-- Product is copied into position at purchase, so later changes of product don't reprice old checks
+-- Costs are in currency of check, line_total is before discounts
+ALTER TABLE "order"
+    ADD COLUMN IF NOT EXISTS product_name TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS unit_cost    BIGINT NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    ADD COLUMN IF NOT EXISTS line_total   BIGINT NOT NULL DEFAULT 0;
+
+-- Positions written before have nothing better than current products
+UPDATE "order" o
+SET product_name = p.name, unit_cost = p.cost, line_total = o.req_amount * p.cost
+FROM product p
+WHERE p.id = o.product_id;
+
+ALTER TABLE "order" ADD CONSTRAINT order_line_total_check CHECK (line_total = unit_cost * req_amount);
+
+-- Written check is a receipt: its sums and positions can't be changed, only deleted with check
+CREATE OR REPLACE FUNCTION forbid_receipt_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'sums of written check can''t be changed, table: %', TG_TABLE_NAME
+        USING ERRCODE = 'restrict_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS check_sums_immutable ON "check";
+CREATE TRIGGER check_sums_immutable
+    BEFORE UPDATE ON "check"
+    FOR EACH ROW
+    WHEN ((OLD.currency, OLD.subtotal, OLD.tax, OLD.total) IS DISTINCT FROM (NEW.currency, NEW.subtotal, NEW.tax, NEW.total))
+    EXECUTE FUNCTION forbid_receipt_change();
+
+DROP TRIGGER IF EXISTS order_snapshot_immutable ON "order";
+CREATE TRIGGER order_snapshot_immutable
+    BEFORE UPDATE ON "order"
+    FOR EACH ROW
+    EXECUTE FUNCTION forbid_receipt_change();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS check_customer_id_idx;
+ALTER TABLE "check" DROP COLUMN IF EXISTS customer_id;
+DROP TABLE IF EXISTS session;
+DROP TABLE IF EXISTS customer;
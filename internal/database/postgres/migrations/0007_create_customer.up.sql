@@ -0,0 +1,24 @@
+-- Email is kept in lower case, so uniqueness doesn't depend on case
+CREATE TABLE IF NOT EXISTS customer (
+    id            BIGSERIAL PRIMARY KEY,
+    email         TEXT      NOT NULL UNIQUE CHECK (email = LOWER(email)),
+    password_hash TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL
+);
+
+-- Only hash of token is kept, leaked table doesn't give access
+CREATE TABLE IF NOT EXISTS session (
+    token_hash  TEXT      PRIMARY KEY,
+    customer_id BIGINT    NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    created_at  TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMP NOT NULL
+);
+
+-- Expired sessions are deleted by expires_at
+CREATE INDEX IF NOT EXISTS session_expires_at_idx ON session (expires_at);
+
+-- Anonymous purchases don't have customer
+ALTER TABLE "check" ADD COLUMN IF NOT EXISTS customer_id BIGINT REFERENCES customer (id);
+
+-- Checks of customer are read from the newest one
+CREATE INDEX IF NOT EXISTS check_customer_id_idx ON "check" (customer_id, date DESC, id DESC);
@@ -0,0 +1,11 @@
+-- Only hash of key is kept, prefix is shown in list of keys
+CREATE TABLE IF NOT EXISTS api_key (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    prefix     TEXT      NOT NULL,
+    key_hash   TEXT      NOT NULL UNIQUE,
+    role       TEXT      NOT NULL CHECK (role IN ('customer', 'operator', 'admin')),
+    created_at TIMESTAMP NOT NULL,
+    -- Revoked key is kept, so it is seen who had access
+    revoked_at TIMESTAMP
+);
@@ -0,0 +1,8 @@
+-- Discounts of position and its part of discounts of cart, line_total - discount is what was paid without tax
+-- Positions written before keep 0, their discounts weren't saved
+ALTER TABLE "order"
+    ADD COLUMN IF NOT EXISTS discount BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE "order" ADD CONSTRAINT order_discount_check CHECK (discount >= 0 AND discount <= line_total);
+
+-- Trigger order_snapshot_immutable forbids update of any column of position, so discount can't be changed too
@@ -0,0 +1,17 @@
+ALTER TABLE "check" ALTER COLUMN date TYPE TIMESTAMP USING date AT TIME ZONE 'UTC';
+
+ALTER TABLE idempotency_key ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE discount
+    ALTER COLUMN starts_at TYPE TIMESTAMP USING starts_at AT TIME ZONE 'UTC',
+    ALTER COLUMN ends_at   TYPE TIMESTAMP USING ends_at AT TIME ZONE 'UTC';
+
+ALTER TABLE customer ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE session
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMP USING expires_at AT TIME ZONE 'UTC';
+
+ALTER TABLE api_key
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMP USING revoked_at AT TIME ZONE 'UTC';
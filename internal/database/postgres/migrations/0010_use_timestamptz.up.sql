@@ -0,0 +1,19 @@
+-- TIMESTAMP drops offset of time, so 2022-12-31T20:00:00+02:00 was kept as 20:00 and worked two hours later
+-- TIMESTAMPTZ keeps the moment itself, values written before are taken as UTC
+ALTER TABLE "check" ALTER COLUMN date TYPE TIMESTAMPTZ USING date AT TIME ZONE 'UTC';
+
+ALTER TABLE idempotency_key ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE discount
+    ALTER COLUMN starts_at TYPE TIMESTAMPTZ USING starts_at AT TIME ZONE 'UTC',
+    ALTER COLUMN ends_at   TYPE TIMESTAMPTZ USING ends_at AT TIME ZONE 'UTC';
+
+ALTER TABLE customer ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE session
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC';
+
+ALTER TABLE api_key
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ USING revoked_at AT TIME ZONE 'UTC';
@@ -0,0 +1,2 @@
+-- Headers of saved reply (Content-Type, Retry-After, Location), NULL while the first query is in progress
+ALTER TABLE idempotency_key ADD COLUMN IF NOT EXISTS response_headers JSONB;
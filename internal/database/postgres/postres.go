@@ -2,31 +2,49 @@ package pgmanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/delonce/apishop/internal/database"
 	"github.com/delonce/apishop/pkg/logging"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// Readiness probe shouldn't hang on dead database
+const pingTimeout = 2 * time.Second
+
 type postgresDB struct {
 	ctx       context.Context
-	dbmanager *pgxpool.Pool
+	dbmanager pgxQuerier
 	logger    *logging.Logger
 }
 
 func NewStorage(ctx context.Context, pool *pgxpool.Pool, logger *logging.Logger) database.ProductDB {
+	// Connection errors are database.ErrStorageUnavailable for all queries
 	return &postgresDB{
 		ctx:       ctx,
-		dbmanager: pool,
+		dbmanager: classifyingQuerier{querier: pool},
 		logger:    logger,
 	}
 }
 
 func (pgdb *postgresDB) SelectProductByName(productName string) (*database.Product, error) {
-	queryString := `
-		SELECT id, name, cost, amount FROM product WHERE name=$1
-	`
+	return pgdb.selectProduct(`
+		SELECT id, name, cost, currency, amount, tax_category FROM product WHERE name=$1
+	`, productName)
+}
+
+func (pgdb *postgresDB) SelectProductForUpdate(productName string) (*database.Product, error) {
+	// Outside of transaction lock is released at once, so it is used only in transaction
+	return pgdb.selectProduct(`
+		SELECT id, name, cost, currency, amount, tax_category FROM product WHERE name=$1 FOR UPDATE
+	`, productName)
+}
+
+func (pgdb *postgresDB) selectProduct(queryString, productName string) (*database.Product, error) {
 
 	// Trace every query in logs to handy processing
 	pgdb.logger.Trace("SQL Query: ", queryString)
@@ -34,14 +52,15 @@ func (pgdb *postgresDB) SelectProductByName(productName string) (*database.Produ
 	// Get necessary model
 	product := database.Product{}
 
-	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, productName).Scan(&product.ID, &product.Name, &product.Cost, &product.Amount)
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, productName).Scan(&product.ID, &product.Name, &product.Cost.Amount, &product.Cost.Currency,
+		&product.Amount, &product.TaxCategory)
 
 	if err != nil {
 		// Process DB errors in this part of code
 		// Because of using goroutines in service we cannot handle error and describe it there as well as we do here
 		// Attemts of catch most common errors
-		if err.Error() == "no rows in result set" {
-			return nil, fmt.Errorf("product with name %s doesn't exist", productName)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w, name: %s", database.ErrProductNotFound, productName)
 		} else {
 			pgdb.logger.Errorf("error when trying buy %s, error: %v", productName, err)
 			return nil, err
@@ -56,14 +75,16 @@ func (pgdb *postgresDB) InsertCheck(purchCheck database.Check) (int64, error) {
 	// Generates errors if it occurs
 	queryString := `
 		INSERT INTO "check"
-			(is_confirmed, date)
+			(is_confirmed, date, currency, subtotal, tax, total, customer_id)
 		VALUES 
-			($1, $2)
+			($1, $2, $3, $4, $5, $6, NULLIF($7, 0))
 		RETURNING id
 	`
 
 	pgdb.logger.Trace("SQL Query: ", queryString)
-	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, purchCheck.IsConfirmed, purchCheck.DateAt).Scan(&purchCheck.ID)
+	err := pgdb.dbmanager.QueryRow(pgdb.ctx, queryString, purchCheck.IsConfirmed, purchCheck.DateAt,
+		purchCheck.Total.Currency, purchCheck.Subtotal.Amount, purchCheck.Tax.Amount, purchCheck.Total.Amount,
+		purchCheck.CustomerID).Scan(&purchCheck.ID)
 
 	if err != nil {
 		pgdb.logger.Errorf("error when trying insert check, error: %v", err)
@@ -78,14 +99,16 @@ func (pgdb *postgresDB) InsertProductFromCheck(position database.Order) error {
 	// Generates errors if it occurs
 	queryString := `
 		INSERT INTO "order"
-			(product_id, check_id, req_amount)
+			(product_id, check_id, req_amount, tax_category, tax_rate, tax, product_name, unit_cost, line_total, discount)
 		VALUES 
-			($1, $2, $3)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id
 	`
 
 	pgdb.logger.Trace("SQL Query: ", queryString)
-	_, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, position.ProductID, position.CheckID, position.ReqAmount)
+	_, err := pgdb.dbmanager.Exec(pgdb.ctx, queryString, position.ProductID, position.CheckID, position.ReqAmount,
+		position.TaxCategory, position.TaxRate, position.Tax.Amount, position.ProductName, position.ProductCost.Amount, position.LineTotal.Amount,
+		position.Discount.Amount)
 
 	if err != nil {
 		pgdb.logger.Errorf("error when trying insert position, error: %v", err)
@@ -94,3 +117,65 @@ func (pgdb *postgresDB) InsertProductFromCheck(position database.Order) error {
 
 	return nil
 }
+
+func (pgdb *postgresDB) ReserveProducts(order map[string]int64) error {
+	// Conditional update doesn't let amount go below zero even if two buyers came at the same time
+	// The row stays locked until the end of transaction, so the second buyer sees already decremented amount
+	queryString := `
+		UPDATE product
+		SET amount = amount - $2
+		WHERE name = $1 AND amount >= $2
+	`
+
+	pgdb.logger.Trace("SQL Query: ", queryString)
+
+	tx, err := pgdb.dbmanager.Begin(pgdb.ctx)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying begin reservation, error: %v", err)
+		return err
+	}
+
+	// Rollback is no-op after successful commit
+	defer tx.Rollback(pgdb.ctx)
+
+	// Lock rows always in the same order, otherwise two orders [apple, melon] and [melon, apple] can deadlock
+	names := make([]string, 0, len(order))
+
+	for name := range order {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		tag, err := tx.Exec(pgdb.ctx, queryString, name, order[name])
+
+		if err != nil {
+			pgdb.logger.Errorf("error when trying reserve %s, error: %v", name, err)
+			return classifyError(err)
+		}
+
+		// Nothing was updated, so somebody has already bought this product
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("%w, product: %s, requested_amount: %d", database.ErrInsufficientStock, name, order[name])
+		}
+	}
+
+	if err = tx.Commit(pgdb.ctx); err != nil {
+		pgdb.logger.Errorf("error when trying commit reservation, error: %v", err)
+		return classifyError(err)
+	}
+
+	return nil
+}
+
+func (pgdb *postgresDB) Ping() error {
+	ctx, cancel := context.WithTimeout(pgdb.ctx, pingTimeout)
+	defer cancel()
+
+	// Works both on pool and in transaction, pool takes connection to execute it
+	_, err := pgdb.dbmanager.Exec(ctx, "SELECT 1")
+
+	return err
+}
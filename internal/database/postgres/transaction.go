@@ -0,0 +1,66 @@
+package pgmanager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Common part of pgxpool.Pool and pgx.Tx
+// Storage methods don't care whether they are executed on pool or in transaction
+type pgxQuerier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type postgresTx struct {
+	*postgresDB
+	tx pgx.Tx
+}
+
+func (pgdb *postgresDB) Begin() (database.ProductTx, error) {
+	// On pool it is BEGIN, in transaction pgx makes SAVEPOINT
+	tx, err := pgdb.dbmanager.Begin(pgdb.ctx)
+
+	if err != nil {
+		pgdb.logger.Errorf("error when trying begin transaction, error: %v", err)
+		return nil, err
+	}
+
+	return &postgresTx{
+		postgresDB: &postgresDB{
+			ctx:       pgdb.ctx,
+			dbmanager: classifyingQuerier{querier: tx},
+			logger:    pgdb.logger,
+		},
+		tx: tx,
+	}, nil
+}
+
+func (pgtx *postgresTx) Commit() error {
+	err := pgtx.tx.Commit(pgtx.ctx)
+
+	if err != nil {
+		pgtx.logger.Errorf("error when trying commit transaction, error: %v", err)
+		return classifyError(err)
+	}
+
+	return nil
+}
+
+func (pgtx *postgresTx) Rollback() error {
+	err := pgtx.tx.Rollback(pgtx.ctx)
+
+	// Rollback after commit is allowed, so it can be deferred
+	if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		pgtx.logger.Errorf("error when trying rollback transaction, error: %v", err)
+		return classifyError(err)
+	}
+
+	return nil
+}
@@ -1,13 +1,30 @@
 package delivery
 
 import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delonce/apishop/internal/config"
+	"github.com/delonce/apishop/internal/database"
 	"github.com/delonce/apishop/internal/delivery/handlers"
+	"github.com/delonce/apishop/internal/delivery/openapi"
 	"github.com/delonce/apishop/internal/service/subject"
 	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/metrics"
+	"github.com/delonce/apishop/pkg/ratelimit"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// Limit of every counted route that isn't named in config of limits
+const anyRoute = "*"
+
+var limitedRoute = regexp.MustCompile(`^[A-Z]+ /\S*$`)
+
 type Delivery interface {
 	Register()
 	GetRouter() *httprouter.Router
@@ -15,15 +32,44 @@ type Delivery interface {
 
 type deliveryHandler struct {
 	*handlers.NetworkHandler
+
+	// Everything registered in router, all of them have to be described in OpenAPI document
+	routes []openapi.Operation
+
+	// Keys are method and pattern of route, e.g. "POST /" or anyRoute
+	rateLimits map[string]ratelimit.Limit
+	// Routes of rateLimits that were registered, the rest are typos in config
+	limitedRoutes map[string]bool
+
+	registry        *metrics.Registry
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
 }
 
-func NewDeliveryManager(logger *logging.Logger, purchService subject.Subject) Delivery {
+func NewDeliveryManager(logger *logging.Logger, cfg *config.Config, purchService, quoteService subject.Subject,
+	prodDB database.ProductDB, registry *metrics.Registry, rateLimits map[string]ratelimit.Limit, taxRates map[string]int64) Delivery {
 	return &deliveryHandler{
-		&handlers.NetworkHandler{
+		NetworkHandler: &handlers.NetworkHandler{
 			PurchaseService: purchService,
+			QuoteService:    quoteService,
+			Storage:         prodDB,
 			Router:          httprouter.New(),
 			HandlerLogger:   logger,
+			IdempotencyTTL:  cfg.IdempotencyTTL,
+			Spec:            openapi.MustLoad(),
+			TaxRates:        taxRates,
+			SessionTTL:      cfg.SessionTTL,
+			PasswordCost:    cfg.PasswordCost,
+			// Without proxy header can be sent by client to get new bucket on every query
+			TrustForwardedFor: cfg.TrustForwardedFor,
 		},
+		rateLimits:    rateLimits,
+		limitedRoutes: make(map[string]bool),
+		registry:      registry,
+		requests: registry.Counter("apishop_http_requests_total",
+			"Handled http requests.", "method", "route", "code"),
+		requestDuration: registry.Histogram("apishop_http_request_duration_seconds",
+			"Duration of http requests.", metrics.DefBuckets, "method", "route"),
 	}
 }
 
@@ -32,8 +78,48 @@ func (devHandler *deliveryHandler) Register() {
 	// Use NetworkHandler struct
 	devHandler.HandlerLogger.Info("Starting register handlers")
 
-	devHandler.Router.GET("/", devHandler.GetHelloPage)
-	devHandler.Router.POST("/", devHandler.BuyOnePosition)
+	devHandler.handle(http.MethodGet, "/", devHandler.GetHelloPage)
+	devHandler.handle(http.MethodPost, "/", devHandler.Authenticate(devHandler.BuyOnePosition))
+	devHandler.handle(http.MethodPost, "/quote", devHandler.Quote)
+
+	// Customer accounts, token of session is sent as 'Authorization: Bearer <token>'
+	devHandler.handle(http.MethodPost, "/customers", devHandler.CreateCustomer)
+	devHandler.handle(http.MethodPost, "/sessions", devHandler.CreateSession)
+	devHandler.handle(http.MethodDelete, "/sessions/current", devHandler.RequireCustomer(devHandler.DeleteSession))
+	devHandler.handle(http.MethodGet, "/me/checks", devHandler.RequireCustomer(devHandler.GetMyChecks))
+
+	// Catalogue is public for storefronts, its management for back-office needs API key in header 'X-API-Key'
+	devHandler.handle(http.MethodGet, "/products", devHandler.GetProducts)
+	devHandler.handle(http.MethodPost, "/products", devHandler.RequireRole(database.RoleOperator, devHandler.CreateProduct))
+	devHandler.handle(http.MethodGet, "/products/:name", devHandler.GetProduct)
+	devHandler.handle(http.MethodPut, "/products/:name", devHandler.RequireRole(database.RoleOperator, devHandler.ReplaceProduct))
+	devHandler.handle(http.MethodPatch, "/products/:name", devHandler.RequireRole(database.RoleOperator, devHandler.PatchProduct))
+	devHandler.handle(http.MethodDelete, "/products/:name", devHandler.RequireRole(database.RoleAdmin, devHandler.DeleteProduct))
+
+	// Discount rules and promo codes, they change prices, so only admin creates them
+	devHandler.handle(http.MethodGet, "/discounts", devHandler.RequireRole(database.RoleOperator, devHandler.GetDiscounts))
+	devHandler.handle(http.MethodPost, "/discounts", devHandler.RequireRole(database.RoleAdmin, devHandler.CreateDiscount))
+	devHandler.handle(http.MethodDelete, "/discounts/:id", devHandler.RequireRole(database.RoleAdmin, devHandler.DeleteDiscount))
+
+	// Saved checks of all buyers, customer sees own ones in /me/checks
+	devHandler.handle(http.MethodGet, "/checks", devHandler.RequireRole(database.RoleOperator, devHandler.GetChecks))
+	devHandler.handle(http.MethodGet, "/checks/:id", devHandler.RequireRole(database.RoleOperator, devHandler.GetCheck))
+
+	// Contract of all routes above
+	devHandler.handle(http.MethodGet, "/openapi.json", devHandler.GetOpenAPI)
+
+	// Probes of orchestrator and Prometheus scrapes aren't counted
+	devHandler.uncounted(http.MethodGet, "/healthz", devHandler.GetHealth)
+	devHandler.uncounted(http.MethodGet, "/readyz", devHandler.GetReady)
+	devHandler.uncounted(http.MethodGet, "/metrics", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		devHandler.registry.ServeHTTP(w, r)
+	})
+
+	for route := range devHandler.rateLimits {
+		if route != anyRoute && !devHandler.limitedRoutes[route] {
+			devHandler.HandlerLogger.Warnf("Rate limit of route %s isn't used, there is no such route", route)
+		}
+	}
 
 	devHandler.HandlerLogger.Info("Router had registered all handlers")
 }
@@ -41,3 +127,100 @@ func (devHandler *deliveryHandler) Register() {
 func (devHandler *deliveryHandler) GetRouter() *httprouter.Router {
 	return devHandler.Router
 }
+
+func (devHandler *deliveryHandler) handle(method, route string, handle httprouter.Handle) {
+	devHandler.routes = append(devHandler.routes, openapi.Operation{Method: method, Route: route})
+
+	// Invalid body is rejected before handler, but it is still counted
+	handle = devHandler.ValidateBody(method, route, handle)
+
+	// Limit is checked before everything else, rejected query costs nothing
+	if limit, ok := devHandler.limitOf(method, route); ok {
+		handle = devHandler.RateLimit(ratelimit.NewLimiter(limit), handle)
+	}
+
+	// Every route is counted by its pattern, not by real path, so /products/:name is one series
+	devHandler.Router.Handle(method, route, func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+		handle(recorder, r, params)
+
+		devHandler.requestDuration.Observe(time.Since(start).Seconds(), method, route)
+		devHandler.requests.Inc(method, route, strconv.Itoa(recorder.statusCode))
+	})
+}
+
+func (devHandler *deliveryHandler) limitOf(method, route string) (ratelimit.Limit, bool) {
+	key := method + " " + route
+
+	if limit, ok := devHandler.rateLimits[key]; ok {
+		devHandler.limitedRoutes[key] = true
+		return limit, true
+	}
+
+	limit, ok := devHandler.rateLimits[anyRoute]
+
+	return limit, ok
+}
+
+// Limits look like "POST /=10/1s,GET /products/:name=100/1m,*=50/1s", empty string turns them off
+// Every route has own buckets, anyRoute gives the same limit to each of them separately
+func ParseRateLimits(raw string) (map[string]ratelimit.Limit, error) {
+	limits := map[string]ratelimit.Limit{}
+
+	if strings.TrimSpace(raw) == "" {
+		return limits, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		route, rawLimit, found := strings.Cut(strings.TrimSpace(pair), "=")
+
+		if !found || (route != anyRoute && !limitedRoute.MatchString(route)) {
+			return nil, fmt.Errorf("rate limit should look like 'METHOD /route=requests/period' or '%s=requests/period', got %q",
+				anyRoute, pair)
+		}
+
+		limit, err := ratelimit.ParseLimit(rawLimit)
+
+		if err != nil {
+			return nil, fmt.Errorf("rate limit of %s: %w", route, err)
+		}
+
+		if _, ok := limits[route]; ok {
+			return nil, fmt.Errorf("rate limit of %s is set twice", route)
+		}
+
+		limits[route] = limit
+	}
+
+	return limits, nil
+}
+
+func (devHandler *deliveryHandler) uncounted(method, route string, handle httprouter.Handle) {
+	devHandler.routes = append(devHandler.routes, openapi.Operation{Method: method, Route: route})
+	devHandler.Router.Handle(method, route, handle)
+}
+
+// Remembers status code written by handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	if !recorder.wroteHeader {
+		recorder.statusCode = statusCode
+		recorder.wroteHeader = true
+	}
+
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *statusRecorder) Write(data []byte) (int, error) {
+	// Write without WriteHeader means 200
+	recorder.wroteHeader = true
+
+	return recorder.ResponseWriter.Write(data)
+}
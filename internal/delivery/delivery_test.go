@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/config"
+	"github.com/delonce/apishop/internal/delivery/handlers"
+	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/metrics"
+	"github.com/delonce/apishop/pkg/ratelimit"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	// Requests are counted by route pattern and status code
+	registry := metrics.NewRegistry()
+
+	devHandler := &deliveryHandler{
+		NetworkHandler:  &handlers.NetworkHandler{Router: httprouter.New()},
+		registry:        registry,
+		requests:        registry.Counter("apishop_http_requests_total", "Handled http requests.", "method", "route", "code"),
+		requestDuration: registry.Histogram("apishop_http_request_duration_seconds", "Duration of http requests.", metrics.DefBuckets, "method", "route"),
+	}
+
+	devHandler.handle(http.MethodGet, "/products/:name", func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if params.ByName("name") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	})
+
+	for _, path := range []string{"/products/apple", "/products/melon", "/products/missing"} {
+		devHandler.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	var buf bytes.Buffer
+	registry.WriteTo(&buf)
+	exposition := buf.String()
+
+	assert.True(t, strings.Contains(exposition, `apishop_http_requests_total{method="GET",route="/products/:name",code="200"} 2`))
+	assert.True(t, strings.Contains(exposition, `apishop_http_requests_total{method="GET",route="/products/:name",code="404"} 1`))
+	assert.True(t, strings.Contains(exposition, `apishop_http_request_duration_seconds_count{method="GET",route="/products/:name"} 3`))
+}
+
+func TestRoutesAreDescribed(t *testing.T) {
+	// Contract and router can't drift: every route is in OpenAPI document and every operation is routed
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	manager := NewDeliveryManager(&logging.Logger{Entry: logrus.NewEntry(logger)}, &config.Config{}, nil, nil, nil, nil, nil, nil)
+	manager.Register()
+
+	devHandler := manager.(*deliveryHandler)
+
+	for _, route := range devHandler.routes {
+		assert.True(t, devHandler.Spec.Describes(route.Method, route.Route), "%s %s isn't described", route.Method, route.Route)
+	}
+
+	assert.ElementsMatch(t, devHandler.Spec.Operations(), devHandler.routes)
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits("POST /=10/1s, GET /products/:name=100/m,*=50/1s")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]ratelimit.Limit{
+		"POST /":              {Requests: 10, Period: time.Second},
+		"GET /products/:name": {Requests: 100, Period: time.Minute},
+		"*":                   {Requests: 50, Period: time.Second},
+	}, limits)
+
+	// Limits are off
+	limits, err = ParseRateLimits(" ")
+	assert.Nil(t, err)
+	assert.Empty(t, limits)
+
+	_, err = ParseRateLimits("/products=10/1s")
+	assert.EqualError(t, err, `rate limit should look like 'METHOD /route=requests/period' or '*=requests/period', got "/products=10/1s"`)
+
+	_, err = ParseRateLimits("POST /=10")
+	assert.EqualError(t, err, `rate limit of POST /: limit should look like requests/period (e.g. 10/1s), got "10"`)
+
+	_, err = ParseRateLimits("POST /=10/1s,POST /=20/1s")
+	assert.EqualError(t, err, "rate limit of POST / is set twice")
+}
+
+func TestRateLimitedRoutes(t *testing.T) {
+	// Every route has own buckets, route without own limit gets limit of any route
+	registry := metrics.NewRegistry()
+
+	devHandler := &deliveryHandler{
+		NetworkHandler: &handlers.NetworkHandler{Router: httprouter.New()},
+		rateLimits: map[string]ratelimit.Limit{
+			"POST /": {Requests: 1, Period: time.Minute},
+			anyRoute: {Requests: 2, Period: time.Minute},
+		},
+		limitedRoutes:   make(map[string]bool),
+		registry:        registry,
+		requests:        registry.Counter("apishop_http_requests_total", "Handled http requests.", "method", "route", "code"),
+		requestDuration: registry.Histogram("apishop_http_request_duration_seconds", "Duration of http requests.", metrics.DefBuckets, "method", "route"),
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Write([]byte("ok"))
+	}
+
+	devHandler.handle(http.MethodPost, "/", ok)
+	devHandler.handle(http.MethodGet, "/products", ok)
+	devHandler.uncounted(http.MethodGet, "/healthz", ok)
+
+	serve := func(method, path, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+
+		devHandler.Router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := serve("POST", "/", "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+
+	// The same client from other port
+	w = serve("POST", "/", "10.0.0.1:5001")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, `{"critical_error":"too many requests, try again in 60 seconds","code":"rate_limited"}`, w.Body.String())
+
+	// Other client and other route aren't limited yet
+	assert.Equal(t, http.StatusOK, serve("POST", "/", "10.0.0.2:5000").Code)
+
+	w = serve("GET", "/products", "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+
+	// Probes aren't limited
+	for i := 0; i < 5; i++ {
+		w = serve("GET", "/healthz", "10.0.0.1:5000")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	// Rejected queries are counted
+	var buf bytes.Buffer
+	registry.WriteTo(&buf)
+	assert.Contains(t, buf.String(), `apishop_http_requests_total{method="POST",route="/",code="429"} 1`)
+	assert.True(t, devHandler.limitedRoutes["POST /"])
+}
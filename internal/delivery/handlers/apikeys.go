@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Header with API key of service or staff, customers use 'Authorization: Bearer <token>'
+const apiKeyHeader = "X-API-Key"
+
+// Key of API key in context of query, it is put there by RateLimit
+type apiKeyKey struct{}
+
+// Lets query in only with API key that has role or higher one
+// Key is checked on every query, so revoked key stops working at once
+func (handler *NetworkHandler) RequireRole(role database.Role, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		rawKey := r.Header.Get(apiKeyHeader)
+
+		if rawKey == "" {
+			handler.writeKeyUnauthorized(w, fmt.Sprintf("send API key with role %s in header '%s'", role, apiKeyHeader))
+			return
+		}
+
+		key, err := handler.lookupAPIKey(r, rawKey)
+
+		if errors.Is(err, database.ErrAPIKeyNotFound) {
+			handler.writeKeyUnauthorized(w, "API key is unknown or has been revoked")
+			return
+		}
+
+		if err != nil {
+			handler.writeStorageError(w, err)
+			return
+		}
+
+		// Key is valid, but its owner isn't allowed here
+		if !key.Role.Allows(role) {
+			handler.writeError(w, http.StatusForbidden, CodeForbidden,
+				fmt.Sprintf("API key with role %s can't do it, role %s is needed", key.Role, role))
+			return
+		}
+
+		handle(w, r, params)
+	}
+}
+
+func (handler *NetworkHandler) lookupAPIKey(r *http.Request, rawKey string) (*database.APIKey, error) {
+	// Rate limiter has already found key of this query
+	if key, ok := r.Context().Value(apiKeyKey{}).(database.APIKey); ok {
+		return &key, nil
+	}
+
+	return handler.Storage.SelectAPIKey(secret.Hash(rawKey))
+}
+
+func (handler *NetworkHandler) writeKeyUnauthorized(w http.ResponseWriter, errorString string) {
+	w.Header().Set("WWW-Authenticate", "ApiKey header=\""+apiKeyHeader+"\"")
+	handler.writeError(w, http.StatusUnauthorized, CodeUnauthorized, errorString)
+}
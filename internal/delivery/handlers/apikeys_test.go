@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	type mockBehavior func(s *mock_db.MockProductDB)
+
+	testRequestTable := []struct {
+		name               string
+		apiKey             string
+		expectedStatusCode int
+		expectedReqBody    string
+		mockBehavior       mockBehavior
+	}{
+		{
+			name:               "OK",
+			apiKey:             "apishop_operator",
+			expectedStatusCode: 200,
+			expectedReqBody:    "ok",
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_operator")).Return(&database.APIKey{ID: 1, Role: database.RoleOperator}, nil)
+			},
+		},
+
+		{
+			name:               "Higher role",
+			apiKey:             "apishop_admin",
+			expectedStatusCode: 200,
+			expectedReqBody:    "ok",
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_admin")).Return(&database.APIKey{ID: 2, Role: database.RoleAdmin}, nil)
+			},
+		},
+
+		{
+			name:               "Lower role",
+			apiKey:             "apishop_customer",
+			expectedStatusCode: 403,
+			expectedReqBody:    `{"critical_error":"API key with role customer can't do it, role operator is needed","code":"forbidden"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_customer")).Return(&database.APIKey{ID: 3, Role: database.RoleCustomer}, nil)
+			},
+		},
+
+		{
+			name:               "Without key",
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"send API key with role operator in header 'X-API-Key'","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Revoked key",
+			apiKey:             "apishop_revoked",
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"API key is unknown or has been revoked","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_revoked")).Return(nil, database.ErrAPIKeyNotFound)
+			},
+		},
+
+		{
+			name:               "Storage is unavailable",
+			apiKey:             "apishop_operator",
+			expectedStatusCode: 503,
+			expectedReqBody:    `{"critical_error":"storage is unavailable, try again later","code":"storage_unavailable"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_operator")).Return(nil, fmt.Errorf("%w: timeout", database.ErrStorageUnavailable))
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(prodDB)
+
+			router := httprouter.New()
+
+			transport := &NetworkHandler{
+				Storage:       prodDB,
+				HandlerLogger: nil,
+				Router:        router,
+			}
+
+			router.GET("/checks", transport.RequireRole(database.RoleOperator, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				w.Write([]byte("ok"))
+			}))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/checks", nil)
+
+			if testCase.apiKey != "" {
+				req.Header.Set("X-API-Key", testCase.apiKey)
+			}
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+
+			if w.Code == 401 {
+				assert.Equal(t, `ApiKey header="X-API-Key"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
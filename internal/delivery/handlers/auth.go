@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Token of session is opaque random string, storage keeps only its hash
+const sessionTokenBytes = 32
+
+// Key of session in context of authenticated query
+type sessionKey struct{}
+
+// Reads bearer token of customer and puts his session in context of query
+// Query without header 'Authorization' stays anonymous, wrong or expired token is rejected
+func (handler *NetworkHandler) Authenticate(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		header := r.Header.Get("Authorization")
+
+		if header == "" {
+			handle(w, r, params)
+			return
+		}
+
+		scheme, token, found := strings.Cut(header, " ")
+
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			handler.writeUnauthorized(w, "header 'Authorization' should be 'Bearer <token>'")
+			return
+		}
+
+		session, err := handler.Storage.SelectSession(secret.Hash(token), time.Now())
+
+		if errors.Is(err, database.ErrSessionNotFound) {
+			handler.writeUnauthorized(w, "token is unknown or has expired, log in again")
+			return
+		}
+
+		if err != nil {
+			handler.writeStorageError(w, err)
+			return
+		}
+
+		handle(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, *session)), params)
+	}
+}
+
+// Like Authenticate, but anonymous query is rejected too
+func (handler *NetworkHandler) RequireCustomer(handle httprouter.Handle) httprouter.Handle {
+	return handler.Authenticate(func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if _, ok := sessionFrom(r); !ok {
+			handler.writeUnauthorized(w, "log in and send token in header 'Authorization'")
+			return
+		}
+
+		handle(w, r, params)
+	})
+}
+
+func (handler *NetworkHandler) writeUnauthorized(w http.ResponseWriter, errorString string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	handler.writeError(w, http.StatusUnauthorized, CodeUnauthorized, errorString)
+}
+
+func sessionFrom(r *http.Request) (database.Session, bool) {
+	session, ok := r.Context().Value(sessionKey{}).(database.Session)
+	return session, ok
+}
+
+func customerOf(r *http.Request) int64 {
+	// 0 for anonymous query
+	session, _ := sessionFrom(r)
+	return session.CustomerID
+}
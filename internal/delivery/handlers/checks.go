@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/service/consumer"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Pagination of GET /checks
+const (
+	defaultChecksPerPage = 20
+	maxChecksPerPage     = 100
+)
+
+// FOR REPLY WITH SAVED CHECKS
+type CheckReply struct {
+	ID        int64                      `json:"check_id"`
+	DateAt    time.Time                  `json:"date"`
+	IsConf    bool                       `json:"is_confirmed"`
+	Customer  int64                      `json:"customer_id,omitempty"` // Absent for anonymous purchase
+	Subtotal  money.Money                `json:"subtotal"`
+	Tax       money.Money                `json:"tax"`
+	TotalSum  money.Money                `json:"total_cost"`
+	Positions []consumer.ProductPosition `json:"positions"`
+}
+
+type CheckListReply struct {
+	Checks  []CheckReply `json:"checks"`
+	Page    int64        `json:"page"`
+	PerPage int64        `json:"per_page"`
+	HasMore bool         `json:"has_more"`
+}
+
+func (handler *NetworkHandler) GetCheck(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	checkID, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+
+	if err != nil || checkID <= 0 {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, "id of check should be positive integer")
+		return
+	}
+
+	purchCheck, err := handler.Storage.SelectCheckByID(checkID)
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusOK, createCheckReply(*purchCheck))
+}
+
+func (handler *NetworkHandler) GetChecks(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Example: GET /checks?from=2022-12-01&to=2022-12-31T18:00:00Z&confirmed=true&page=2&per_page=50
+	handler.listChecks(w, r, nil)
+}
+
+func (handler *NetworkHandler) listChecks(w http.ResponseWriter, r *http.Request, customerID *int64) {
+	// nil customer is checks of everybody
+	filter, page, perPage, err := parseCheckFilter(r.URL.Query())
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	filter.CustomerID = customerID
+
+	checks, err := handler.Storage.SelectChecks(filter)
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	reply := CheckListReply{
+		Checks:  []CheckReply{},
+		Page:    page,
+		PerPage: perPage,
+	}
+
+	// One more check was requested to know whether next page exists
+	if int64(len(checks)) > perPage {
+		reply.HasMore = true
+		checks = checks[:perPage]
+	}
+
+	for _, purchCheck := range checks {
+		reply.Checks = append(reply.Checks, createCheckReply(purchCheck))
+	}
+
+	handler.writeJson(w, http.StatusOK, reply)
+}
+
+func parseCheckFilter(query url.Values) (database.CheckFilter, int64, int64, error) {
+	filter := database.CheckFilter{}
+
+	// Dates can be sent as full RFC 3339 time or as day
+	for _, field := range []struct {
+		key   string
+		value **time.Time
+	}{
+		{key: "from", value: &filter.From},
+		{key: "to", value: &filter.To},
+	} {
+		rawValue := query.Get(field.key)
+
+		if rawValue == "" {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, rawValue)
+
+		if err != nil {
+			date, err = time.Parse("2006-01-02", rawValue)
+		}
+
+		if err != nil {
+			return filter, 0, 0, fmt.Errorf("parameter '%s' should be date like 2022-12-31 or 2022-12-31T18:00:00Z", field.key)
+		}
+
+		// Day without offset is parsed as UTC day, time with offset is moved to UTC
+		date = date.UTC()
+		*field.value = &date
+	}
+
+	if rawValue := query.Get("confirmed"); rawValue != "" {
+		isConfirmed, err := strconv.ParseBool(rawValue)
+
+		if err != nil {
+			return filter, 0, 0, errors.New("parameter 'confirmed' should be true or false")
+		}
+
+		filter.IsConfirmed = &isConfirmed
+	}
+
+	page, err := parsePositiveParam(query, "page", 1)
+
+	if err != nil {
+		return filter, 0, 0, err
+	}
+
+	perPage, err := parsePositiveParam(query, "per_page", defaultChecksPerPage)
+
+	if err != nil {
+		return filter, 0, 0, err
+	}
+
+	if perPage > maxChecksPerPage {
+		return filter, 0, 0, fmt.Errorf("parameter 'per_page' can't be more than %d", maxChecksPerPage)
+	}
+
+	filter.Limit = perPage + 1
+	filter.Offset = (page - 1) * perPage
+
+	return filter, page, perPage, nil
+}
+
+func parsePositiveParam(query url.Values, key string, defaultValue int64) (int64, error) {
+	rawValue := query.Get(key)
+
+	if rawValue == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseInt(rawValue, 10, 64)
+
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("parameter '%s' should be positive integer", key)
+	}
+
+	return value, nil
+}
+
+func createCheckReply(purchCheck database.Check) CheckReply {
+	// Sums and positions are written at purchase, so later prices and rates don't change them
+	reply := CheckReply{
+		ID:        purchCheck.ID,
+		DateAt:    purchCheck.DateAt,
+		IsConf:    purchCheck.IsConfirmed,
+		Customer:  purchCheck.CustomerID,
+		Subtotal:  purchCheck.Subtotal,
+		Tax:       purchCheck.Tax,
+		TotalSum:  purchCheck.Total,
+		Positions: []consumer.ProductPosition{},
+	}
+
+	for _, position := range purchCheck.PurchaseList {
+		reply.Positions = append(reply.Positions, consumer.ProductPosition{
+			Product:     position.ProductName,
+			PosCost:     position.LineTotal,
+			ReqAmount:   position.ReqAmount,
+			TaxCategory: position.TaxCategory,
+			TaxRate:     position.TaxRate,
+			Tax:         position.Tax,
+			Discount:    position.Discount,
+		})
+	}
+
+	return reply
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHandlers(t *testing.T) {
+	type mockBehavior func(s *mock_db.MockProductDB)
+
+	dateAt := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+	isConfirmed := true
+
+	purchCheck := database.Check{
+		ID:          7,
+		IsConfirmed: true,
+		DateAt:      dateAt,
+		Subtotal:    money.Money{Amount: 2100, Currency: "USD"},
+		Tax:         money.Money{Amount: 240, Currency: "USD"},
+		Total:       money.Money{Amount: 2340, Currency: "USD"},
+		PurchaseList: []database.Order{
+			{ID: 1, CheckID: 7, ProductID: 1, ReqAmount: 10, TaxCategory: "food", TaxRate: 1000, Tax: money.Money{Amount: 180, Currency: "USD"},
+				ProductName: "apple", ProductCost: money.Money{Amount: 200, Currency: "USD"}, LineTotal: money.Money{Amount: 2000, Currency: "USD"},
+				Discount: money.Money{Amount: 200, Currency: "USD"}},
+			{ID: 2, CheckID: 7, ProductID: 2, ReqAmount: 1, TaxCategory: "standard", TaxRate: 2000, Tax: money.Money{Amount: 60, Currency: "USD"},
+				ProductName: "melon", ProductCost: money.Money{Amount: 300, Currency: "USD"}, LineTotal: money.Money{Amount: 300, Currency: "USD"},
+				Discount: money.Money{Currency: "USD"}},
+		},
+	}
+
+	// Discount of position is written at purchase, so check shows what was paid for it
+	checkJson := `{"check_id":7,"date":"2022-12-20T10:30:00Z","is_confirmed":true,"subtotal":{"amount":2100,"currency":"USD"},"tax":{"amount":240,"currency":"USD"},"total_cost":{"amount":2340,"currency":"USD"},` +
+		`"positions":[{"product":"apple","pos_cost":{"amount":2000,"currency":"USD"},"req_amount":10,"tax_category":"food","tax_rate":1000,"tax":{"amount":180,"currency":"USD"},"discount":{"amount":200,"currency":"USD"}},` +
+		`{"product":"melon","pos_cost":{"amount":300,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":2000,"tax":{"amount":60,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}]}`
+
+	testRequestTable := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedReqBody    string
+		mockBehavior       mockBehavior
+	}{
+		{
+			name:               "Get check",
+			path:               "/checks/7",
+			expectedStatusCode: 200,
+			expectedReqBody:    checkJson,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectCheckByID(int64(7)).Return(&purchCheck, nil)
+			},
+		},
+
+		{
+			name:               "Get not existing check",
+			path:               "/checks/8",
+			expectedStatusCode: 404,
+			expectedReqBody:    `{"critical_error":"check doesn't exist, id: 8","code":"check_not_found"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectCheckByID(int64(8)).Return(nil, fmt.Errorf("%w, id: 8", database.ErrCheckNotFound))
+			},
+		},
+
+		{
+			name:               "Wrong id",
+			path:               "/checks/apple",
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"id of check should be positive integer","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "List with default page",
+			path:               "/checks",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"checks":[` + checkJson + `],"page":1,"per_page":20,"has_more":false}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectChecks(database.CheckFilter{Limit: 21, Offset: 0}).Return([]database.Check{purchCheck}, nil)
+			},
+		},
+
+		{
+			// Time with offset is the same moment in UTC
+			name:               "List with filters and next page",
+			path:               "/checks?from=2022-12-01&to=2022-12-31T02:00:00%2B02:00&confirmed=true&page=3&per_page=1",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"checks":[` + checkJson + `],"page":3,"per_page":1,"has_more":true}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				from := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+				to := time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
+
+				s.EXPECT().SelectChecks(database.CheckFilter{
+					From:        &from,
+					To:          &to,
+					IsConfirmed: &isConfirmed,
+					Limit:       2,
+					Offset:      2,
+				}).Return([]database.Check{purchCheck, purchCheck}, nil)
+			},
+		},
+
+		{
+			name:               "Empty list",
+			path:               "/checks?confirmed=false",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"checks":[],"page":1,"per_page":20,"has_more":false}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectChecks(gomock.Any()).Return([]database.Check{}, nil)
+			},
+		},
+
+		{
+			name:               "Wrong date",
+			path:               "/checks?from=yesterday",
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"parameter 'from' should be date like 2022-12-31 or 2022-12-31T18:00:00Z","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Too big page",
+			path:               "/checks?per_page=1000",
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"parameter 'per_page' can't be more than 100","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Wrong confirmed filter",
+			path:               "/checks?confirmed=maybe",
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"parameter 'confirmed' should be true or false","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(prodDB)
+
+			router := httprouter.New()
+
+			// Handler with Storage (mock)
+			transport := &NetworkHandler{
+				Storage:       prodDB,
+				HandlerLogger: nil,
+				Router:        router,
+			}
+
+			// Check paths
+			router.GET("/checks", transport.GetChecks)
+			router.GET("/checks/:id", transport.GetCheck)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", testCase.path, nil)
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt uses only first 72 bytes, longer password would be cut silently
+	maxPasswordLength = 72
+)
+
+var emailAddress = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// Hash that is compared when email is unknown, so login takes the same time for existing and unknown customers
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// FOR REPLY TO CUSTOMERS
+type CustomerReply struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+type SessionReply struct {
+	Token     string    `json:"token"` // Sent as 'Authorization: Bearer <token>'
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (handler *NetworkHandler) CreateCustomer(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Example: {"email": "ann@example.com", "password": "correct horse"}
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	email, password, err := parseCredentials(bodyBytes)
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest,
+			fmt.Sprintf("field 'password' should be from %d to %d bytes long", minPasswordLength, maxPasswordLength))
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), handler.passwordCost())
+
+	if err != nil {
+		handler.writeInternalError(w, fmt.Errorf("hashing of password: %w", err))
+		return
+	}
+
+	customer := database.Customer{Email: email, PasswordHash: string(passwordHash), CreatedAt: time.Now()}
+
+	// Taken email is ErrCustomerExists
+	customer.ID, err = handler.Storage.InsertCustomer(customer)
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusCreated, CustomerReply{ID: customer.ID, Email: customer.Email})
+}
+
+func (handler *NetworkHandler) CreateSession(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Log in, the same body as registration
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	email, password, err := parseCredentials(bodyBytes)
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	customer, err := handler.Storage.SelectCustomerByEmail(email)
+
+	if err != nil && !errors.Is(err, database.ErrCustomerNotFound) {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	// Client doesn't learn whether email is registered
+	if customer == nil {
+		bcrypt.CompareHashAndPassword(handler.dummyHash(), []byte(password))
+		handler.writeUnauthorized(w, "email or password is wrong")
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(customer.PasswordHash), []byte(password)) != nil {
+		handler.writeUnauthorized(w, "email or password is wrong")
+		return
+	}
+
+	token, err := secret.New(sessionTokenBytes)
+
+	if err != nil {
+		handler.writeInternalError(w, fmt.Errorf("generating of session token: %w", err))
+		return
+	}
+
+	now := time.Now()
+	session := database.Session{
+		TokenHash:  secret.Hash(token),
+		CustomerID: customer.ID,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(handler.SessionTTL),
+	}
+
+	if err = handler.Storage.InsertSession(session); err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusCreated, SessionReply{Token: token, ExpiresAt: session.ExpiresAt})
+}
+
+func (handler *NetworkHandler) DeleteSession(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Log out, token of this query stops working
+	session, _ := sessionFrom(r)
+
+	if err := handler.Storage.DeleteSession(session.TokenHash); err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *NetworkHandler) GetMyChecks(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// The same filter and pages as GET /checks, but only checks of authenticated customer
+	customerID := customerOf(r)
+
+	handler.listChecks(w, r, &customerID)
+}
+
+func (handler *NetworkHandler) writeInternalError(w http.ResponseWriter, err error) {
+	if handler.HandlerLogger != nil {
+		handler.HandlerLogger.Errorf("internal error: %v", err)
+	}
+
+	handler.writeError(w, http.StatusInternalServerError, CodeInternal, "internal error, try again later")
+}
+
+func (handler *NetworkHandler) passwordCost() int {
+	if handler.PasswordCost == 0 {
+		return bcrypt.DefaultCost
+	}
+
+	return handler.PasswordCost
+}
+
+func (handler *NetworkHandler) dummyHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password of anybody"), handler.passwordCost())
+	})
+
+	return dummyHash
+}
+
+func parseCredentials(bodyBytes []byte) (string, string, error) {
+	email, err := jsonparser.GetString(bodyBytes, "email")
+
+	if err != nil {
+		return "", "", errors.New("you need to send string value with key 'email'")
+	}
+
+	// Email is compared in lower case, so Ann@Example.com and ann@example.com are one customer
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if !emailAddress.MatchString(email) {
+		return "", "", fmt.Errorf("field 'email' should be address like ann@example.com, got %q", email)
+	}
+
+	password, err := jsonparser.GetString(bodyBytes, "password")
+
+	if err != nil {
+		return "", "", errors.New("you need to send string value with key 'password'")
+	}
+
+	return email, password, nil
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/internal/service/consumer"
+	mock_subject "github.com/delonce/apishop/internal/service/subject/mocks"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token of new session is random
+var sessionToken = regexp.MustCompile(`"token":"[^"]+","expires_at":"[^"]+"`)
+
+func TestCustomerHandlers(t *testing.T) {
+	type mockBehavior func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject)
+
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	ann := &database.Customer{ID: 5, Email: "ann@example.com", PasswordHash: string(passwordHash)}
+	session := &database.Session{TokenHash: secret.Hash("token-1"), CustomerID: 5}
+	customerID := int64(5)
+
+	testRequestTable := []struct {
+		name               string
+		method             string
+		path               string
+		authorization      string
+		inputBody          string
+		expectedStatusCode int
+		expectedReqBody    string
+		mockBehavior       mockBehavior
+	}{
+		{
+			name:               "Register",
+			method:             "POST",
+			path:               "/customers",
+			inputBody:          `{"email":" Ann@Example.com","password":"correct horse"}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":5,"email":"ann@example.com"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				// Only hash of password is written
+				s.EXPECT().InsertCustomer(gomock.Any()).DoAndReturn(func(customer database.Customer) (int64, error) {
+					assert.Equal(t, "ann@example.com", customer.Email)
+					assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(customer.PasswordHash), []byte("correct horse")))
+					return int64(5), nil
+				})
+			},
+		},
+
+		{
+			name:               "Email is taken",
+			method:             "POST",
+			path:               "/customers",
+			inputBody:          `{"email":"ann@example.com","password":"correct horse"}`,
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"customer already exists, email: ann@example.com","code":"customer_exists"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().InsertCustomer(gomock.Any()).Return(int64(0), fmt.Errorf("%w, email: ann@example.com", database.ErrCustomerExists))
+			},
+		},
+
+		{
+			name:               "Short password",
+			method:             "POST",
+			path:               "/customers",
+			inputBody:          `{"email":"ann@example.com","password":"horse"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'password' should be from 8 to 72 bytes long","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Wrong email",
+			method:             "POST",
+			path:               "/customers",
+			inputBody:          `{"email":"ann","password":"correct horse"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'email' should be address like ann@example.com, got \"ann\"","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Log in",
+			method:             "POST",
+			path:               "/sessions",
+			inputBody:          `{"email":"ann@example.com","password":"correct horse"}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"token":"TOKEN","expires_at":"TIME"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectCustomerByEmail("ann@example.com").Return(ann, nil)
+				s.EXPECT().InsertSession(gomock.Any()).DoAndReturn(func(session database.Session) error {
+					assert.Equal(t, int64(5), session.CustomerID)
+					assert.Equal(t, time.Hour, session.ExpiresAt.Sub(session.CreatedAt))
+					return nil
+				})
+			},
+		},
+
+		{
+			name:               "Wrong password",
+			method:             "POST",
+			path:               "/sessions",
+			inputBody:          `{"email":"ann@example.com","password":"wrong horse"}`,
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"email or password is wrong","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectCustomerByEmail("ann@example.com").Return(ann, nil)
+			},
+		},
+
+		{
+			name:               "Unknown email looks like wrong password",
+			method:             "POST",
+			path:               "/sessions",
+			inputBody:          `{"email":"bob@example.com","password":"correct horse"}`,
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"email or password is wrong","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectCustomerByEmail("bob@example.com").Return(nil, fmt.Errorf("%w, email: bob@example.com", database.ErrCustomerNotFound))
+			},
+		},
+
+		{
+			name:               "Log out",
+			method:             "DELETE",
+			path:               "/sessions/current",
+			authorization:      "Bearer token-1",
+			expectedStatusCode: 204,
+			expectedReqBody:    "",
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectSession(secret.Hash("token-1"), gomock.Any()).Return(session, nil)
+				s.EXPECT().DeleteSession(secret.Hash("token-1")).Return(nil)
+			},
+		},
+
+		{
+			name:               "Checks of customer",
+			method:             "GET",
+			path:               "/me/checks?per_page=1",
+			authorization:      "bearer token-1",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"checks":[{"check_id":7,"date":"0001-01-01T00:00:00Z","is_confirmed":true,"customer_id":5,"subtotal":{"amount":0,"currency":""},"tax":{"amount":0,"currency":""},"total_cost":{"amount":0,"currency":""},"positions":[]}],"page":1,"per_page":1,"has_more":false}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectSession(secret.Hash("token-1"), gomock.Any()).Return(session, nil)
+				s.EXPECT().SelectChecks(database.CheckFilter{CustomerID: &customerID, Limit: 2}).
+					Return([]database.Check{{ID: 7, IsConfirmed: true, CustomerID: 5}}, nil)
+			},
+		},
+
+		{
+			name:               "Checks without token",
+			method:             "GET",
+			path:               "/me/checks",
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"log in and send token in header 'Authorization'","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Expired token",
+			method:             "GET",
+			path:               "/me/checks",
+			authorization:      "Bearer token-2",
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"token is unknown or has expired, log in again","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectSession(secret.Hash("token-2"), gomock.Any()).Return(nil, database.ErrSessionNotFound)
+			},
+		},
+
+		{
+			name:               "Not bearer authorization",
+			method:             "GET",
+			path:               "/me/checks",
+			authorization:      "Basic YW5uOmhvcnNl",
+			expectedStatusCode: 401,
+			expectedReqBody:    `{"critical_error":"header 'Authorization' should be 'Bearer \u003ctoken\u003e'","code":"unauthorized"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Storage is unavailable for token",
+			method:             "GET",
+			path:               "/me/checks",
+			authorization:      "Bearer token-1",
+			expectedStatusCode: 503,
+			expectedReqBody:    `{"critical_error":"storage is unavailable, try again later","code":"storage_unavailable"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectSession(secret.Hash("token-1"), gomock.Any()).Return(nil, fmt.Errorf("%w: timeout", database.ErrStorageUnavailable))
+			},
+		},
+
+		{
+			name:               "Purchase of customer",
+			method:             "POST",
+			path:               "/",
+			authorization:      "Bearer token-1",
+			inputBody:          `{"order":[{"product":"apple","amount":1}]}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    "mock message for success notify",
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				s.EXPECT().SelectSession(secret.Hash("token-1"), gomock.Any()).Return(session, nil)
+				purchase.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 1}, CustomerID: 5}).
+					Return([]byte("mock message for success notify"), nil)
+			},
+		},
+
+		{
+			name:               "Anonymous purchase",
+			method:             "POST",
+			path:               "/",
+			inputBody:          `{"order":[{"product":"apple","amount":1}]}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    "mock message for success notify",
+			mockBehavior: func(s *mock_db.MockProductDB, purchase *mock_subject.MockSubject) {
+				purchase.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 1}}).
+					Return([]byte("mock message for success notify"), nil)
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+			purchase := mock_subject.NewMockSubject(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(prodDB, purchase)
+
+			router := httprouter.New()
+
+			transport := &NetworkHandler{
+				PurchaseService: purchase,
+				Storage:         prodDB,
+				HandlerLogger:   nil,
+				Router:          router,
+				SessionTTL:      time.Hour,
+				PasswordCost:    bcrypt.MinCost,
+			}
+
+			// Customer paths
+			router.POST("/", transport.Authenticate(transport.BuyOnePosition))
+			router.POST("/customers", transport.CreateCustomer)
+			router.POST("/sessions", transport.CreateSession)
+			router.DELETE("/sessions/current", transport.RequireCustomer(transport.DeleteSession))
+			router.GET("/me/checks", transport.RequireCustomer(transport.GetMyChecks))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.method, testCase.path, bytes.NewBufferString(testCase.inputBody))
+
+			if testCase.authorization != "" {
+				req.Header.Set("Authorization", testCase.authorization)
+			}
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, sessionToken.ReplaceAllString(w.Body.String(), `"token":"TOKEN","expires_at":"TIME"`))
+
+			if w.Code == 401 {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
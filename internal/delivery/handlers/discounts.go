@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/julienschmidt/httprouter"
+)
+
+// FOR REPLY TO BACK-OFFICE
+// Only fields of kind are sent
+type DiscountReply struct {
+	ID        int64        `json:"id"`
+	PromoCode string       `json:"promo_code,omitempty"`
+	Kind      string       `json:"kind"`
+	Product   string       `json:"product,omitempty"`
+	Percent   int64        `json:"percent,omitempty"`
+	Fixed     *money.Money `json:"fixed,omitempty"`
+	Buy       int64        `json:"buy,omitempty"`
+	Free      int64        `json:"free,omitempty"`
+	StartsAt  *time.Time   `json:"starts_at,omitempty"`
+	EndsAt    *time.Time   `json:"ends_at,omitempty"`
+}
+
+func (handler *NetworkHandler) GetDiscounts(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	discounts, err := handler.Storage.SelectDiscounts()
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	reply := make([]DiscountReply, 0, len(discounts))
+
+	for _, discount := range discounts {
+		reply = append(reply, createDiscountReply(discount))
+	}
+
+	handler.writeJson(w, http.StatusOK, reply)
+}
+
+func (handler *NetworkHandler) CreateDiscount(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	discount, err := parseDiscountForm(bodyBytes)
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Unknown product is ErrProductNotFound
+	discount.ID, err = handler.Storage.InsertDiscount(discount)
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusCreated, createDiscountReply(discount))
+}
+
+func (handler *NetworkHandler) DeleteDiscount(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	discountID, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+
+	if err != nil || discountID <= 0 {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, "id of discount should be positive integer")
+		return
+	}
+
+	if err = handler.Storage.DeleteDiscount(discountID); err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseDiscountForm(bodyBytes []byte) (database.Discount, error) {
+	// Example: {"promo_code": "SALE", "kind": "percent", "product": "apple", "percent": 10, "ends_at": "2022-12-31T00:00:00Z"}
+	discount := database.Discount{}
+
+	var err error
+
+	// Discount without promo code is applied to every order
+	if discount.PromoCode, err = getOptionalString(bodyBytes, "promo_code"); err != nil {
+		return discount, err
+	}
+
+	// Discount without product is applied to the whole cart
+	if discount.Product, err = getOptionalString(bodyBytes, "product"); err != nil {
+		return discount, err
+	}
+
+	discount.Kind, err = jsonparser.GetString(bodyBytes, "kind")
+
+	if err != nil {
+		return discount, errors.New("you need to send string value with key 'kind'")
+	}
+
+	switch discount.Kind {
+	case database.DiscountPercent:
+		discount.Percent, err = jsonparser.GetInt(bodyBytes, "percent")
+
+		if err != nil || discount.Percent < 1 || discount.Percent > 100 {
+			return discount, errors.New("you need to send integer value from 1 to 100 with key 'percent'")
+		}
+	case database.DiscountFixed:
+		fixedBytes, dataType, _, err := jsonparser.Get(bodyBytes, "fixed")
+
+		if err != nil || dataType != jsonparser.Object {
+			return discount, errors.New("you need to send object with keys 'amount' and 'currency' with key 'fixed'")
+		}
+
+		if discount.Fixed, err = parseMoney(fixedBytes, "fixed"); err != nil {
+			return discount, err
+		}
+
+		if discount.Fixed.Amount == 0 {
+			return discount, errors.New("field 'fixed.amount' should be more than 0")
+		}
+	case database.DiscountBuyXGetY:
+		if discount.Product == "" {
+			return discount, errors.New("you need to send product with key 'product' for discount buy_x_get_y")
+		}
+
+		discount.Buy, err = jsonparser.GetInt(bodyBytes, "buy")
+
+		if err != nil || discount.Buy < 1 {
+			return discount, errors.New("you need to send positive integer value with key 'buy'")
+		}
+
+		discount.Free, err = jsonparser.GetInt(bodyBytes, "free")
+
+		if err != nil || discount.Free < 1 {
+			return discount, errors.New("you need to send positive integer value with key 'free'")
+		}
+	default:
+		return discount, fmt.Errorf("field 'kind' should be one of percent, fixed, buy_x_get_y, got %q", discount.Kind)
+	}
+
+	if discount.StartsAt, err = getOptionalTime(bodyBytes, "starts_at"); err != nil {
+		return discount, err
+	}
+
+	if discount.EndsAt, err = getOptionalTime(bodyBytes, "ends_at"); err != nil {
+		return discount, err
+	}
+
+	if discount.StartsAt != nil && discount.EndsAt != nil && !discount.StartsAt.Before(*discount.EndsAt) {
+		return discount, errors.New("field 'starts_at' should be before 'ends_at'")
+	}
+
+	return discount, nil
+}
+
+func getOptionalString(bodyBytes []byte, key string) (string, error) {
+	// Missing key is empty string, but sent value can't be empty
+	value, err := jsonparser.GetString(bodyBytes, key)
+
+	if errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("you need to send string value with key '%s'", key)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("field '%s' can't be empty", key)
+	}
+
+	return value, nil
+}
+
+func getOptionalTime(bodyBytes []byte, key string) (*time.Time, error) {
+	rawValue, err := jsonparser.GetString(bodyBytes, key)
+
+	if errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("you need to send string value with key '%s'", key)
+	}
+
+	value, err := time.Parse(time.RFC3339, rawValue)
+
+	if err != nil {
+		return nil, fmt.Errorf("field '%s' should be time like 2022-12-31T18:00:00Z", key)
+	}
+
+	// Offset is only the way client wrote the moment, storage and replies work in UTC
+	value = value.UTC()
+
+	return &value, nil
+}
+
+func createDiscountReply(discount database.Discount) DiscountReply {
+	reply := DiscountReply{
+		ID:        discount.ID,
+		PromoCode: discount.PromoCode,
+		Kind:      discount.Kind,
+		Product:   discount.Product,
+		Percent:   discount.Percent,
+		Buy:       discount.Buy,
+		Free:      discount.Free,
+		StartsAt:  discount.StartsAt,
+		EndsAt:    discount.EndsAt,
+	}
+
+	if discount.Kind == database.DiscountFixed {
+		reply.Fixed = &discount.Fixed
+	}
+
+	return reply
+}
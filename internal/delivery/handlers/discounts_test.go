@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscountHandlers(t *testing.T) {
+	type mockBehavior func(s *mock_db.MockProductDB)
+
+	endsAt := time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	appleSale := database.Discount{ID: 1, PromoCode: "SALE", Kind: database.DiscountPercent, Product: "apple", Percent: 10, EndsAt: &endsAt}
+	cartSale := database.Discount{ID: 2, Kind: database.DiscountFixed, Fixed: money.Money{Amount: 100, Currency: "USD"}}
+
+	testRequestTable := []struct {
+		name               string
+		method             string
+		path               string
+		inputBody          string
+		expectedStatusCode int
+		expectedReqBody    string
+		mockBehavior       mockBehavior
+	}{
+		{
+			name:               "List discounts",
+			method:             "GET",
+			path:               "/discounts",
+			expectedStatusCode: 200,
+			expectedReqBody: `[{"id":1,"promo_code":"SALE","kind":"percent","product":"apple","percent":10,"ends_at":"2022-12-31T00:00:00Z"},` +
+				`{"id":2,"kind":"fixed","fixed":{"amount":100,"currency":"USD"}}]`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectDiscounts().Return([]database.Discount{appleSale, cartSale}, nil)
+			},
+		},
+
+		{
+			name:               "Create percent discount",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"promo_code":"SALE","kind":"percent","product":"apple","percent":10,"ends_at":"2022-12-31T00:00:00Z"}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":1,"promo_code":"SALE","kind":"percent","product":"apple","percent":10,"ends_at":"2022-12-31T00:00:00Z"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				discount := appleSale
+				discount.ID = 0
+
+				s.EXPECT().InsertDiscount(discount).Return(int64(1), nil)
+			},
+		},
+
+		{
+			name:               "Time with offset is kept in UTC",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"promo_code":"SALE","kind":"percent","product":"apple","percent":10,"ends_at":"2022-12-30T19:00:00-05:00"}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":1,"promo_code":"SALE","kind":"percent","product":"apple","percent":10,"ends_at":"2022-12-31T00:00:00Z"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				discount := appleSale
+				discount.ID = 0
+
+				s.EXPECT().InsertDiscount(discount).Return(int64(1), nil)
+			},
+		},
+
+		{
+			name:               "Create buy x get y discount",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"buy_x_get_y","product":"melon","buy":2,"free":1}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":3,"kind":"buy_x_get_y","product":"melon","buy":2,"free":1}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().InsertDiscount(database.Discount{Kind: database.DiscountBuyXGetY, Product: "melon", Buy: 2, Free: 1}).Return(int64(3), nil)
+			},
+		},
+
+		{
+			name:               "Discount of unknown product",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"fixed","product":"kiwi","fixed":{"amount":100,"currency":"USD"}}`,
+			expectedStatusCode: 404,
+			expectedReqBody:    `{"critical_error":"product doesn't exist, name: kiwi","code":"product_not_found"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().InsertDiscount(gomock.Any()).Return(int64(0), fmt.Errorf("%w, name: kiwi", database.ErrProductNotFound))
+			},
+		},
+
+		{
+			name:               "Unknown kind",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"gift"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'kind' should be one of percent, fixed, buy_x_get_y, got \"gift\"","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Too big percent",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"percent","percent":150}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send integer value from 1 to 100 with key 'percent'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Zero fixed discount",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"fixed","fixed":{"amount":0,"currency":"USD"}}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'fixed.amount' should be more than 0","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Buy x get y for cart",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"buy_x_get_y","buy":2,"free":1}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send product with key 'product' for discount buy_x_get_y","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Ends before starts",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"kind":"percent","percent":10,"starts_at":"2022-12-31T00:00:00Z","ends_at":"2022-12-01T00:00:00Z"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'starts_at' should be before 'ends_at'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Empty promo code",
+			method:             "POST",
+			path:               "/discounts",
+			inputBody:          `{"promo_code":"","kind":"percent","percent":10}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'promo_code' can't be empty","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Delete discount",
+			method:             "DELETE",
+			path:               "/discounts/2",
+			expectedStatusCode: 204,
+			expectedReqBody:    "",
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().DeleteDiscount(int64(2)).Return(nil)
+			},
+		},
+
+		{
+			name:               "Delete not existing discount",
+			method:             "DELETE",
+			path:               "/discounts/9",
+			expectedStatusCode: 404,
+			expectedReqBody:    `{"critical_error":"discount doesn't exist, id: 9","code":"discount_not_found"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().DeleteDiscount(int64(9)).Return(fmt.Errorf("%w, id: 9", database.ErrDiscountNotFound))
+			},
+		},
+
+		{
+			name:               "Wrong id",
+			method:             "DELETE",
+			path:               "/discounts/sale",
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"id of discount should be positive integer","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(prodDB)
+
+			router := httprouter.New()
+
+			// Handler with Storage (mock)
+			transport := &NetworkHandler{
+				Storage:       prodDB,
+				HandlerLogger: nil,
+				Router:        router,
+			}
+
+			// Discount paths
+			router.GET("/discounts", transport.GetDiscounts)
+			router.POST("/discounts", transport.CreateDiscount)
+			router.DELETE("/discounts/:id", transport.DeleteDiscount)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.method, testCase.path, bytes.NewBufferString(testCase.inputBody))
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/service/consumer"
+	"github.com/delonce/apishop/internal/service/subject"
+)
+
+// Machine-readable codes of JsonErrorReply, clients should check them instead of text
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeProductNotFound    = "product_not_found"
+	CodeCheckNotFound      = "check_not_found"
+	CodeCustomerExists     = "customer_exists"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeRateLimited        = "rate_limited"
+	CodeDiscountNotFound   = "discount_not_found"
+	CodeProductExists      = "product_exists"
+	CodeProductInUse       = "product_in_use"
+	CodeInsufficientStock  = "insufficient_stock"
+	CodeIdempotencyReused  = "idempotency_key_reused"
+	CodeIdempotencyPending = "idempotency_key_in_progress"
+	CodeStorageUnavailable = "storage_unavailable"
+	CodeShuttingDown       = "shutting_down"
+	CodeInternal           = "internal_error"
+)
+
+const problemContentType = "application/problem+json"
+
+// Known errors of database layer, the first match wins
+var storageErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{err: database.ErrProductNotFound, status: http.StatusNotFound, code: CodeProductNotFound},
+	{err: database.ErrCheckNotFound, status: http.StatusNotFound, code: CodeCheckNotFound},
+	{err: database.ErrDiscountNotFound, status: http.StatusNotFound, code: CodeDiscountNotFound},
+	{err: database.ErrProductExists, status: http.StatusConflict, code: CodeProductExists},
+	{err: database.ErrCustomerExists, status: http.StatusConflict, code: CodeCustomerExists},
+	{err: database.ErrProductInUse, status: http.StatusConflict, code: CodeProductInUse},
+	{err: database.ErrInsufficientStock, status: http.StatusConflict, code: CodeInsufficientStock},
+}
+
+func (handler *NetworkHandler) writeError(w http.ResponseWriter, status int, code, errorString string) {
+	// Headers can't be changed after WriteHeader
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(createJsonErrorReply(handler.HandlerLogger, code, errorString))
+}
+
+// Error document of RFC 7807, used for invalid orders
+type ProblemReply struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	// Extensions: one of Code* constants and every problem found in query
+	Code   string             `json:"code"`
+	Errors []consumer.Problem `json:"errors"`
+}
+
+func (handler *NetworkHandler) writeProblem(w http.ResponseWriter, status int, code, detail string, problems []consumer.Problem) {
+	// Type isn't documented page, so it is "about:blank" and title is just text of status
+	reply := ProblemReply{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: problems,
+	}
+
+	rawBytes, err := json.Marshal(reply)
+
+	if err != nil {
+		handler.HandlerLogger.Panicf("Error Marshall, error: %v", err)
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	w.Write(rawBytes)
+}
+
+func (handler *NetworkHandler) writeServiceError(w http.ResponseWriter, err error) {
+	// Chooses status code by error of purchase service, errors of storage are passed through it
+	var validationErr *consumer.ValidationError
+
+	switch {
+	case errors.As(err, &validationErr):
+		handler.writeProblem(w, http.StatusUnprocessableEntity, CodeValidationFailed, "order has problems, see errors",
+			validationErr.Problems)
+	case errors.Is(err, subject.ErrSubjectClosed):
+		// Application is stopping, client can retry on another instance
+		handler.writeError(w, http.StatusServiceUnavailable, CodeShuttingDown, "service is shutting down, try again later")
+	default:
+		handler.writeStorageError(w, err)
+	}
+}
+
+func (handler *NetworkHandler) writeStorageError(w http.ResponseWriter, err error) {
+	// Chooses status code by error of database layer
+	for _, known := range storageErrors {
+		if errors.Is(err, known.err) {
+			handler.writeError(w, known.status, known.code, err.Error())
+			return
+		}
+	}
+
+	// Don't show internal details to client, they are in logs
+	if handler.HandlerLogger != nil {
+		handler.HandlerLogger.Errorf("storage error: %v", err)
+	}
+
+	if errors.Is(err, database.ErrStorageUnavailable) {
+		handler.writeError(w, http.StatusServiceUnavailable, CodeStorageUnavailable, "storage is unavailable, try again later")
+		return
+	}
+
+	handler.writeError(w, http.StatusInternalServerError, CodeInternal, "internal storage error, try again later")
+}
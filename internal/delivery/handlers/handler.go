@@ -3,12 +3,14 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
-	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/delivery/openapi"
+	"github.com/delonce/apishop/internal/service/consumer"
 	"github.com/delonce/apishop/internal/service/subject"
 	"github.com/delonce/apishop/pkg/logging"
 	"github.com/julienschmidt/httprouter"
@@ -16,12 +18,31 @@ import (
 
 type NetworkHandler struct {
 	PurchaseService subject.Subject
+	QuoteService    subject.Subject // Prices order without writing check, see Quote
+	Storage         database.ProductDB
 	Router          *httprouter.Router
 	HandlerLogger   *logging.Logger
+	IdempotencyTTL  time.Duration
+	// OpenAPI document, request bodies are validated by it
+	Spec *openapi.Spec
+	// Rates of tax categories from config, product can get only category that has rate
+	TaxRates map[string]int64
+
+	// How long token of customer works after login
+	SessionTTL time.Duration
+	// Cost of bcrypt for passwords of customers, 0 is bcrypt.DefaultCost
+	PasswordCost int
+
+	// Client address for rate limits is taken from X-Forwarded-For set by proxy in front of application
+	TrustForwardedFor bool
+	// Hashes of API keys found by rate limiter and when they have to be looked up again, see RateLimit
+	knownKeys sync.Map
 }
 
 type JsonErrorReply struct {
 	Error string `json:"critical_error"`
+	// One of Code* constants, text of error can change
+	Code string `json:"code"`
 }
 
 func (handler *NetworkHandler) GetHelloPage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
@@ -31,98 +52,62 @@ func (handler *NetworkHandler) GetHelloPage(w http.ResponseWriter, r *http.Reque
 
 func (handler *NetworkHandler) BuyOnePosition(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	// Get body of query
-	start := time.Now()
 	bodyBytes, _ := io.ReadAll(r.Body)
 
-	order, err := createOrderMap(handler.HandlerLogger, w, bodyBytes)
+	// Check of authenticated customer is linked to him, anonymous purchase has no owner
+	customerID := customerOf(r)
 
-	if err != nil {
-		return
+	// Retried query with the same key gets reply of the first one instead of second check
+	if idemKey := r.Header.Get(IdempotencyKeyHeader); idemKey != "" {
+		handler.buyIdempotent(w, idemKey, customerID, bodyBytes)
+	} else {
+		handler.buy(w, customerID, bodyBytes)
 	}
+}
 
-	// Catches empty order
-	if len(order) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(createJsonErrorReply(handler.HandlerLogger, "your order is empty, try to add something in POST query"))
+func (handler *NetworkHandler) Quote(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Totals and availability before buying, repeating it is safe, so Idempotency-Key isn't needed
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	handler.notify(w, handler.QuoteService, 0, bodyBytes)
+}
+
+func (handler *NetworkHandler) buy(w http.ResponseWriter, customerID int64, bodyBytes []byte) {
+	handler.notify(w, handler.PurchaseService, customerID, bodyBytes)
+}
+
+func (handler *NetworkHandler) notify(w http.ResponseWriter, service subject.Subject, customerID int64, bodyBytes []byte) {
+	// All problems of order are returned in one reply
+	request, err := parseOrder(bodyBytes)
+
+	// Order that can't be read or summed is bad query, not order with problems
+	if errors.Is(err, errMalformedOrder) || errors.Is(err, errAmountOverflow) {
+		handler.writeProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error(), []consumer.Problem{})
 		return
 	}
 
-	// Starts main service (Subject interface, see service/subject)
-	reply, err := handler.PurchaseService.Notify(order)
-
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(createJsonErrorReply(handler.HandlerLogger, err.Error()))
-		return
-	} else {
-		w.Write(reply)
-		fmt.Println("DURATION ", time.Since(start).Seconds())
+		handler.writeServiceError(w, err)
 		return
 	}
-}
 
-func createOrderMap(logger *logging.Logger, w http.ResponseWriter, bodyBytes []byte) (map[string]int64, error) {
-	// If some error happens while parsing POST query - remembers it and blocks handler
-	var validateRequestError error
-
-	// Consider POST query like map with string keys (product) and int64 values (amount)
-	order := map[string]int64{}
-
-	// Use jsonparser to parse recieved query
-	jsonparser.ArrayEach(bodyBytes, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-		if err != nil {
-			validateRequestError = err
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write(createJsonErrorReply(logger, "you need to send list of products with key 'order'"))
-			return
-		}
-
-		// Get some name of product
-		productName, err := jsonparser.GetString(value, "product")
-
-		if err != nil {
-			validateRequestError = err
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write(createJsonErrorReply(logger, "you need to send string value with key 'product'"))
-			return
-		}
-
-		// Get nessesary amount of founded product
-		amountProduct, err := jsonparser.GetInt(value, "amount")
-
-		if err != nil {
-			validateRequestError = err
-			w.WriteHeader(http.StatusBadRequest)
-			errString := fmt.Sprintf("error value in key 'amount', product name: %s", productName)
-			w.Write(createJsonErrorReply(logger, errString))
-			return
-		}
-
-		// Amount can't be less than 0
-		if amountProduct < 0 {
-			validateRequestError = errors.New("minus value in field 'amount'")
-			w.WriteHeader(http.StatusBadRequest)
-			errString := fmt.Sprintf("field 'amount' in product %s should be more than 0", productName)
-			w.Write(createJsonErrorReply(logger, errString))
-			return
-		}
-
-		// Add right product and amount to our map
-		// Protect from POST query like [{"product":"apple","amount":2},{"product":"apple","amount":2}]
-		order[productName] = order[productName] + amountProduct
-
-	}, "order")
-
-	if validateRequestError != nil {
-		return nil, validateRequestError
+	request.CustomerID = customerID
+
+	// Starts service (Subject interface, see service/subject)
+	reply, err := service.Notify(request)
+
+	if err != nil {
+		handler.writeServiceError(w, err)
+		return
 	}
 
-	return order, nil
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply)
 }
 
-func createJsonErrorReply(logger *logging.Logger, errorString string) []byte {
+func createJsonErrorReply(logger *logging.Logger, code, errorString string) []byte {
 	// Just creates json with error if this exists
-	jsonRep := JsonErrorReply{Error: errorString}
+	jsonRep := JsonErrorReply{Error: errorString, Code: code}
 
 	// jsonparser doesn't provide func of marshalling json so I prefer to use default encoding/json
 	rawBytes, err := json.Marshal(jsonRep)
@@ -133,3 +118,16 @@ func createJsonErrorReply(logger *logging.Logger, errorString string) []byte {
 
 	return rawBytes
 }
+
+func (handler *NetworkHandler) writeJson(w http.ResponseWriter, status int, reply interface{}) {
+	// Writes any reply structure as json with status code
+	rawBytes, err := json.Marshal(reply)
+
+	if err != nil {
+		handler.HandlerLogger.Panicf("Error Marshall, error: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(rawBytes)
+}
@@ -7,6 +7,9 @@ import (
 	"testing"
 
 	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/service/consumer"
+	"github.com/delonce/apishop/internal/service/subject"
 	mock_subject "github.com/delonce/apishop/internal/service/subject/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/julienschmidt/httprouter"
@@ -66,7 +69,7 @@ func TestGetRequest(t *testing.T) {
 }
 
 func TestBuyOnePosition(t *testing.T) {
-	type mockBehavior func(s *mock_subject.MockSubject, order map[string]int64)
+	type mockBehavior func(s *mock_subject.MockSubject, request consumer.Request)
 
 	testRequestTable := []struct {
 		name               string
@@ -80,8 +83,8 @@ func TestBuyOnePosition(t *testing.T) {
 			inputBody:          `{"order":[{"product":"apple","amount":45},{"product":"melon","amount":11}]}`,
 			expectedStatusCode: 200,
 			expectedReqBody:    "mock message for success notify",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
-				s.EXPECT().Notify(order).Return([]byte("mock message for success notify"), nil)
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return([]byte("mock message for success notify"), nil)
 			},
 		},
 
@@ -90,27 +93,97 @@ func TestBuyOnePosition(t *testing.T) {
 			inputBody:          `{"order":[{"product":"apple","amount":45},{"product":"apple","amount":45}]}`,
 			expectedStatusCode: 200,
 			expectedReqBody:    "mock message for success notify",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
-				s.EXPECT().Notify(order).Return([]byte("mock message for success notify"), nil)
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return([]byte("mock message for success notify"), nil)
 			},
 		},
 
 		{
 			name:               "Not found product id DB",
 			inputBody:          `{"order":[{"product":"notexistingproduct","amount":60}]}`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"product with name notexistingproduct doesn't exist\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
-				s.EXPECT().Notify(order).Return([]byte(""), fmt.Errorf("product with name notexistingproduct doesn't exist"))
+			expectedStatusCode: 404,
+			expectedReqBody:    "{\"critical_error\":\"product doesn't exist, name: notexistingproduct\",\"code\":\"product_not_found\"}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return([]byte(""), fmt.Errorf("%w, name: notexistingproduct", database.ErrProductNotFound))
+			},
+		},
+
+		{
+			name:               "Storage is unavailable",
+			inputBody:          `{"order":[{"product":"apple","amount":1}]}`,
+			expectedStatusCode: 503,
+			expectedReqBody:    "{\"critical_error\":\"storage is unavailable, try again later\",\"code\":\"storage_unavailable\"}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return(nil, fmt.Errorf("%w: connection refused", database.ErrStorageUnavailable))
+			},
+		},
+
+		{
+			name:               "Stock was bought by somebody else",
+			inputBody:          `{"order":[{"product":"apple","amount":1}]}`,
+			expectedStatusCode: 409,
+			expectedReqBody:    "{\"critical_error\":\"insufficient stock, product: apple, requested_amount: 1\",\"code\":\"insufficient_stock\"}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return(nil, fmt.Errorf("%w, product: apple, requested_amount: 1", database.ErrInsufficientStock))
+			},
+		},
+
+		{
+			name:               "Service is shutting down",
+			inputBody:          `{"order":[{"product":"apple","amount":1}]}`,
+			expectedStatusCode: 503,
+			expectedReqBody:    "{\"critical_error\":\"service is shutting down, try again later\",\"code\":\"shutting_down\"}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return(nil, subject.ErrSubjectClosed)
+			},
+		},
+
+		{
+			name:               "With promo code",
+			inputBody:          `{"order":[{"product":"apple","amount":45}],"promo_code":"SUMMER"}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    "mock message for success notify",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT().Notify(request).Return([]byte("mock message for success notify"), nil)
+			},
+		},
+
+		{
+			name:               "Promo code isn't string",
+			inputBody:          `{"order":[{"product":"apple","amount":45}],"promo_code":5}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"field\":\"promo_code\",\"reason\":\"you need to send string value with key 'promo_code'\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT()
 			},
 		},
 
 		{
 			name:               "All fields string JSON",
 			inputBody:          `{"order":[{"product":"apple","amount":"45"},{"product":"melon","amount":"11"}]}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"index\":0,\"field\":\"amount\",\"reason\":\"you need to send integer value with key 'amount'\"},{\"index\":1,\"field\":\"amount\",\"reason\":\"you need to send integer value with key 'amount'\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Order isn't list",
+			inputBody:          `{"order":"apple"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Bad Request\",\"status\":400,\"detail\":\"you need to send list of products with key 'order'\",\"code\":\"invalid_request\",\"errors\":[]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Sum of one product overflows",
+			inputBody:          `{"order":[{"product":"apple","amount":9223372036854775807},{"product":"apple","amount":1}]}`,
 			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"error value in key 'amount', product name: apple\"}{\"critical_error\":\"error value in key 'amount', product name: melon\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Bad Request\",\"status\":400,\"detail\":\"sum of amounts of one product is too big, product: apple\",\"code\":\"invalid_request\",\"errors\":[]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -118,9 +191,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "Empty Body",
 			inputBody:          ``,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"your order is empty, try to add something in POST query\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"field\":\"order\",\"reason\":\"your order is empty, try to add something in POST query\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -128,9 +201,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "Empty Body With Key Order",
 			inputBody:          `{"order":[]}`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"your order is empty, try to add something in POST query\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"field\":\"order\",\"reason\":\"your order is empty, try to add something in POST query\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -138,9 +211,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "First wrong Second Right",
 			inputBody:          `{"order":[{"name":"apple","amount":45},{"product":"melon","amount":11}]}`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"you need to send string value with key 'product'\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"index\":0,\"field\":\"product\",\"reason\":\"you need to send string value with key 'product'\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -148,9 +221,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "Some wrong fields",
 			inputBody:          `{"order":[{"x":"1","y":-6,"z":"test"}]`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"you need to send string value with key 'product'\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"index\":0,\"field\":\"product\",\"reason\":\"you need to send string value with key 'product'\"},{\"index\":0,\"field\":\"amount\",\"reason\":\"you need to send integer value with key 'amount'\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -158,9 +231,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "Minus value",
 			inputBody:          `{"order":[{"product":"apple","amount":10},{"product":"someMockValue","amount":-5}]}`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"field 'amount' in product someMockValue should be more than 0\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"index\":1,\"field\":\"amount\",\"reason\":\"field 'amount' can't be less than 0\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -168,9 +241,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "Many minus value",
 			inputBody:          `{"order":[{"product":"apple","amount":-10},{"product":"someMockValue","amount":-5}]}`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"field 'amount' in product apple should be more than 0\"}{\"critical_error\":\"field 'amount' in product someMockValue should be more than 0\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"index\":0,\"field\":\"amount\",\"reason\":\"field 'amount' can't be less than 0\"},{\"index\":1,\"field\":\"amount\",\"reason\":\"field 'amount' can't be less than 0\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -178,9 +251,9 @@ func TestBuyOnePosition(t *testing.T) {
 		{
 			name:               "Many wrong fields",
 			inputBody:          `{"order":[{"product":"apple","amount":-10},{"product":"someMockValue","amount":"5"},{"errorkey":"melon","amount":"5"}]}`,
-			expectedStatusCode: 400,
-			expectedReqBody:    "{\"critical_error\":\"field 'amount' in product apple should be more than 0\"}{\"critical_error\":\"error value in key 'amount', product name: someMockValue\"}{\"critical_error\":\"you need to send string value with key 'product'\"}",
-			mockBehavior: func(s *mock_subject.MockSubject, order map[string]int64) {
+			expectedStatusCode: 422,
+			expectedReqBody:    "{\"type\":\"about:blank\",\"title\":\"Unprocessable Entity\",\"status\":422,\"detail\":\"order has problems, see errors\",\"code\":\"validation_failed\",\"errors\":[{\"index\":0,\"field\":\"amount\",\"reason\":\"field 'amount' can't be less than 0\"},{\"index\":1,\"field\":\"amount\",\"reason\":\"you need to send integer value with key 'amount'\"},{\"index\":2,\"field\":\"product\",\"reason\":\"you need to send string value with key 'product'\"},{\"index\":2,\"field\":\"amount\",\"reason\":\"you need to send integer value with key 'amount'\"}]}",
+			mockBehavior: func(s *mock_subject.MockSubject, request consumer.Request) {
 				s.EXPECT()
 			},
 		},
@@ -206,8 +279,10 @@ func TestBuyOnePosition(t *testing.T) {
 
 			}, "order")
 
+			promoCode, _ := jsonparser.GetString(bodyBytes, "promo_code")
+
 			// Starts nessesary behavior
-			testCase.mockBehavior(sub, order)
+			testCase.mockBehavior(sub, consumer.Request{Order: order, PromoCode: promoCode})
 
 			router := httprouter.New()
 
@@ -230,6 +305,14 @@ func TestBuyOnePosition(t *testing.T) {
 
 			assert.Equal(t, testCase.expectedStatusCode, w.Code)
 			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+
+			// Invalid order is described by one problem document
+			// Checks and other errors are plain json
+			if testCase.expectedStatusCode == 400 || testCase.expectedStatusCode == 422 {
+				assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			} else {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			}
 		})
 	}
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type HealthReply struct {
+	Status string `json:"status"`
+	// Status of every check that has failed, details of errors are only in log
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Statuses of failed checks, probe is public, so it doesn't show addresses and texts of errors
+const (
+	statusUnreachable = "unreachable"
+	statusNotReady    = "not ready"
+)
+
+func (handler *NetworkHandler) GetHealth(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Liveness probe, process answers so it is alive
+	handler.writeJson(w, http.StatusOK, HealthReply{Status: "ok"})
+}
+
+func (handler *NetworkHandler) GetReady(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Readiness probe, pod gets queries only if database and purchase service work
+	reply := HealthReply{Status: "ready", Errors: map[string]string{}}
+	details := map[string]error{}
+
+	if err := handler.Storage.Ping(); err != nil {
+		reply.Errors["database"] = statusUnreachable
+		details["database"] = err
+	}
+
+	if err := handler.PurchaseService.Ready(); err != nil {
+		reply.Errors["purchase_service"] = statusNotReady
+		details["purchase_service"] = err
+	}
+
+	if handler.QuoteService != nil {
+		if err := handler.QuoteService.Ready(); err != nil {
+			reply.Errors["quote_service"] = statusNotReady
+			details["quote_service"] = err
+		}
+	}
+
+	if len(reply.Errors) != 0 {
+		if handler.HandlerLogger != nil {
+			handler.HandlerLogger.Warnf("application isn't ready: %v", details)
+		}
+
+		reply.Status = statusNotReady
+		handler.writeJson(w, http.StatusServiceUnavailable, reply)
+		return
+	}
+
+	handler.writeJson(w, http.StatusOK, reply)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/internal/service/subject"
+	mock_subject "github.com/delonce/apishop/internal/service/subject/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	type mockBehavior func(s *mock_subject.MockSubject, db *mock_db.MockProductDB)
+
+	testRequestTable := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedReqBody    string
+		mockBehavior       mockBehavior
+	}{
+		{
+			name:               "Alive",
+			path:               "/healthz",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"status":"ok"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Ready",
+			path:               "/readyz",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"status":"ready"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB) {
+				db.EXPECT().Ping().Return(nil)
+				s.EXPECT().Ready().Return(nil)
+			},
+		},
+
+		{
+			name:               "Database is down",
+			path:               "/readyz",
+			expectedStatusCode: 503,
+			// Text of error can have address of database, it is only logged
+			expectedReqBody: `{"status":"not ready","errors":{"database":"unreachable"}}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB) {
+				db.EXPECT().Ping().Return(errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+				s.EXPECT().Ready().Return(nil)
+			},
+		},
+
+		{
+			name:               "Subject is draining",
+			path:               "/readyz",
+			expectedStatusCode: 503,
+			expectedReqBody:    `{"status":"not ready","errors":{"purchase_service":"not ready"}}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB) {
+				db.EXPECT().Ping().Return(nil)
+				s.EXPECT().Ready().Return(subject.ErrSubjectClosed)
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			sub := mock_subject.NewMockSubject(c)
+			prodDB := mock_db.NewMockProductDB(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(sub, prodDB)
+
+			router := httprouter.New()
+
+			transport := &NetworkHandler{
+				PurchaseService: sub,
+				Storage:         prodDB,
+				HandlerLogger:   nil,
+				Router:          router,
+			}
+
+			router.GET("/healthz", transport.GetHealth)
+			router.GET("/readyz", transport.GetReady)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", testCase.path, nil)
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+		})
+	}
+}
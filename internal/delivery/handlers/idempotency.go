@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/database"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// Set in reply that was saved by previous query with the same key
+	IdempotentReplayHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Headers that are part of reply, they are saved and replayed with it
+var replayedHeaders = []string{"Content-Type", "Retry-After", "Location"}
+
+// Keeps reply of handler to save it before sending to client
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (handler *NetworkHandler) buyIdempotent(w http.ResponseWriter, idemKey string, customerID int64, bodyBytes []byte) {
+	if len(idemKey) > maxIdempotencyKeyLength {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, "header 'Idempotency-Key' is too long")
+		return
+	}
+
+	now := time.Now()
+	requestHash := sha256.Sum256(bodyBytes)
+
+	// The same key and body of another customer is another query, so his reply isn't shown to anybody else
+	if customerID != 0 {
+		requestHash = sha256.Sum256(append([]byte(fmt.Sprintf("customer %d\n", customerID)), bodyBytes...))
+	}
+
+	key := database.IdempotencyKey{
+		Key:         idemKey,
+		RequestHash: hex.EncodeToString(requestHash[:]),
+		CreatedAt:   now,
+	}
+
+	claimed, err := handler.Storage.ClaimIdempotencyKey(key, now.Add(-handler.IdempotencyTTL))
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	if !claimed {
+		handler.replayIdempotent(w, key)
+		return
+	}
+
+	// Key is ours, do purchase and remember its reply
+	// If reply isn't saved (purchase panicked or failed), key is released, so retry isn't "in progress" till TTL ends
+	saved := false
+
+	defer func() {
+		if !saved {
+			handler.releaseIdempotencyKey(key.Key)
+		}
+	}()
+
+	recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	handler.buy(recorder, customerID, bodyBytes)
+
+	// Query wasn't done because of our problems, client can retry with the same key
+	if recorder.statusCode < http.StatusInternalServerError {
+		// Reply has check_id only if check was written
+		key.CheckID, _ = jsonparser.GetInt(recorder.body.Bytes(), "check_id")
+		key.StatusCode = recorder.statusCode
+		key.Response = recorder.body.Bytes()
+		key.Headers = map[string]string{}
+
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				key.Headers[name] = value
+			}
+		}
+
+		err = handler.Storage.SaveIdempotencyResponse(key)
+
+		if err != nil && handler.HandlerLogger != nil {
+			handler.HandlerLogger.Errorf("error when trying save idempotent response %s, error: %v", key.Key, err)
+		}
+
+		// Released key of written check would let retry buy the second time, so it waits for TTL
+		saved = err == nil || key.CheckID != 0
+	}
+
+	w.WriteHeader(recorder.statusCode)
+	w.Write(recorder.body.Bytes())
+}
+
+func (handler *NetworkHandler) releaseIdempotencyKey(key string) {
+	if err := handler.Storage.DeleteIdempotencyKey(key); err != nil && handler.HandlerLogger != nil {
+		handler.HandlerLogger.Errorf("error when trying release idempotency key %s, error: %v", key, err)
+	}
+}
+
+func (handler *NetworkHandler) replayIdempotent(w http.ResponseWriter, key database.IdempotencyKey) {
+	// Key was used before, reply can be sent only for the same query
+	saved, err := handler.Storage.SelectIdempotencyKey(key.Key)
+
+	if err != nil && !errors.Is(err, database.ErrIdempotencyKeyNotFound) {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	if saved != nil && saved.RequestHash != key.RequestHash {
+		handler.writeError(w, http.StatusUnprocessableEntity, CodeIdempotencyReused, "'Idempotency-Key' was already used with another body")
+		return
+	}
+
+	// First query hasn't finished yet or its key was released right now, client should retry
+	if saved == nil || saved.Response == nil {
+		handler.writeError(w, http.StatusConflict, CodeIdempotencyPending, "query with this 'Idempotency-Key' is in progress, try again later")
+		return
+	}
+
+	for name, value := range saved.Headers {
+		w.Header().Set(name, value)
+	}
+
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(saved.StatusCode)
+	w.Write(saved.Response)
+}
+
+func (recorder *responseRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	return recorder.body.Write(data)
+}
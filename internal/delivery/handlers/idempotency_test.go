@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/internal/service/consumer"
+	mock_subject "github.com/delonce/apishop/internal/service/subject/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentPurchase(t *testing.T) {
+	type mockBehavior func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string)
+
+	inputBody := `{"order":[{"product":"apple","amount":45}]}`
+	otherHash := sha256.Sum256([]byte(`{"order":[{"product":"apple","amount":1}]}`))
+
+	testRequestTable := []struct {
+		name               string
+		idemKey            string
+		expectedStatusCode int
+		expectedReqBody    string
+		expectedReplayed   string
+		// Content-Type is saved with reply and replayed too
+		expectedContentType string
+		mockBehavior        mockBehavior
+	}{
+		{
+			name:                "First query saves reply",
+			idemKey:             "key-1",
+			expectedStatusCode:  200,
+			expectedReqBody:     `{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`,
+			expectedContentType: "application/json",
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 45}}).Return([]byte(`{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`), nil)
+				db.EXPECT().SaveIdempotencyResponse(gomock.Any()).DoAndReturn(func(key database.IdempotencyKey) error {
+					assert.Equal(t, "key-1", key.Key)
+					assert.Equal(t, requestHash, key.RequestHash)
+					assert.Equal(t, int64(7), key.CheckID)
+					assert.Equal(t, 200, key.StatusCode)
+					assert.Equal(t, []byte(`{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`), key.Response)
+					assert.Equal(t, map[string]string{"Content-Type": "application/json"}, key.Headers)
+					return nil
+				})
+			},
+		},
+
+		{
+			name:                "Retry gets saved reply",
+			idemKey:             "key-1",
+			expectedStatusCode:  200,
+			expectedReqBody:     `{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`,
+			expectedReplayed:    "true",
+			expectedContentType: "application/json",
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, nil)
+				db.EXPECT().SelectIdempotencyKey("key-1").Return(&database.IdempotencyKey{
+					Key:         "key-1",
+					RequestHash: requestHash,
+					CheckID:     7,
+					StatusCode:  200,
+					Response:    []byte(`{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`),
+					Headers:     map[string]string{"Content-Type": "application/json"},
+				}, nil)
+			},
+		},
+
+		{
+			name:               "Key reused with another body",
+			idemKey:            "key-1",
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"critical_error":"'Idempotency-Key' was already used with another body","code":"idempotency_key_reused"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, nil)
+				db.EXPECT().SelectIdempotencyKey("key-1").Return(&database.IdempotencyKey{
+					Key:         "key-1",
+					RequestHash: hex.EncodeToString(otherHash[:]),
+					StatusCode:  200,
+					Response:    []byte(`{"check_id":7,"total_cost":{"amount":200,"currency":"USD"}}`),
+				}, nil)
+			},
+		},
+
+		{
+			name:               "First query is in progress",
+			idemKey:            "key-1",
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"query with this 'Idempotency-Key' is in progress, try again later","code":"idempotency_key_in_progress"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, nil)
+				db.EXPECT().SelectIdempotencyKey("key-1").Return(&database.IdempotencyKey{
+					Key:         "key-1",
+					RequestHash: requestHash,
+				}, nil)
+			},
+		},
+
+		{
+			name:               "Rejected query is saved too",
+			idemKey:            "key-2",
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"insufficient stock","code":"insufficient_stock"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 45}}).Return(nil, database.ErrInsufficientStock)
+				db.EXPECT().SaveIdempotencyResponse(gomock.Any()).DoAndReturn(func(key database.IdempotencyKey) error {
+					assert.Equal(t, int64(0), key.CheckID)
+					assert.Equal(t, 409, key.StatusCode)
+					assert.Equal(t, []byte(`{"critical_error":"insufficient stock","code":"insufficient_stock"}`), key.Response)
+					return nil
+				})
+			},
+		},
+
+		{
+			name:               "Failed query releases key",
+			idemKey:            "key-2",
+			expectedStatusCode: 500,
+			expectedReqBody:    `{"critical_error":"internal storage error, try again later","code":"internal_error"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 45}}).Return(nil, errors.New("connection refused"))
+				db.EXPECT().DeleteIdempotencyKey("key-2").Return(nil)
+			},
+		},
+
+		{
+			name:               "Key of reply that wasn't saved is released",
+			idemKey:            "key-2",
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"insufficient stock","code":"insufficient_stock"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 45}}).Return(nil, database.ErrInsufficientStock)
+				db.EXPECT().SaveIdempotencyResponse(gomock.Any()).Return(errors.New("connection refused"))
+				db.EXPECT().DeleteIdempotencyKey("key-2").Return(nil)
+			},
+		},
+
+		{
+			name:                "Key of written check isn't released",
+			idemKey:             "key-1",
+			expectedStatusCode:  200,
+			expectedReqBody:     `{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`,
+			expectedContentType: "application/json",
+			// Retry waits for TTL instead of buying the second time
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().Notify(consumer.Request{Order: map[string]int64{"apple": 45}}).Return([]byte(`{"check_id":7,"total_cost":{"amount":9000,"currency":"USD"}}`), nil)
+				db.EXPECT().SaveIdempotencyResponse(gomock.Any()).Return(errors.New("connection refused"))
+			},
+		},
+
+		{
+			name:               "Storage failure",
+			idemKey:            "key-3",
+			expectedStatusCode: 500,
+			expectedReqBody:    `{"critical_error":"internal storage error, try again later","code":"internal_error"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, errors.New("connection refused"))
+			},
+		},
+
+		{
+			name:               "Too long key",
+			idemKey:            strings.Repeat("k", 256),
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"header 'Idempotency-Key' is too long","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_subject.MockSubject, db *mock_db.MockProductDB, requestHash string) {
+				s.EXPECT()
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			sub := mock_subject.NewMockSubject(c)
+			prodDB := mock_db.NewMockProductDB(c)
+
+			requestHash := sha256.Sum256([]byte(inputBody))
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(sub, prodDB, hex.EncodeToString(requestHash[:]))
+
+			router := httprouter.New()
+
+			// Handler with PurchaseServer and Storage (mocks)
+			transport := &NetworkHandler{
+				PurchaseService: sub,
+				Storage:         prodDB,
+				HandlerLogger:   nil,
+				Router:          router,
+				IdempotencyTTL:  time.Hour,
+			}
+
+			// POST path
+			router.POST("/", transport.BuyOnePosition)
+
+			// Send input body with key
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/", bytes.NewBufferString(inputBody))
+			req.Header.Set(IdempotencyKeyHeader, testCase.idemKey)
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+			assert.Equal(t, testCase.expectedReplayed, w.Header().Get(IdempotentReplayHeader))
+
+			if testCase.expectedContentType != "" {
+				assert.Equal(t, testCase.expectedContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyIsReleasedOnPanic(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	sub := mock_subject.NewMockSubject(c)
+	prodDB := mock_db.NewMockProductDB(c)
+
+	prodDB.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+	sub.EXPECT().Notify(gomock.Any()).DoAndReturn(func(request consumer.Request) ([]byte, error) {
+		panic("pipeline is broken")
+	})
+	// Otherwise retry would be "in progress" till key expires
+	prodDB.EXPECT().DeleteIdempotencyKey("key-1").Return(nil)
+
+	router := httprouter.New()
+	transport := &NetworkHandler{PurchaseService: sub, Storage: prodDB, Router: router, IdempotencyTTL: time.Hour}
+	router.POST("/", transport.BuyOnePosition)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"order":[{"product":"apple","amount":45}]}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+	// Panic goes further to server, key is released on the way
+	assert.Panics(t, func() { router.ServeHTTP(w, req) })
+}
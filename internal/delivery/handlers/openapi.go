@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/delonce/apishop/internal/delivery/openapi"
+	"github.com/delonce/apishop/internal/service/consumer"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (handler *NetworkHandler) GetOpenAPI(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(handler.Spec.Raw())
+}
+
+func (handler *NetworkHandler) ValidateBody(method, route string, next httprouter.Handle) httprouter.Handle {
+	// Body that doesn't match OpenAPI document doesn't reach handler
+	if handler.Spec == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		bodyBytes, _ := io.ReadAll(r.Body)
+
+		problems, err := handler.Spec.ValidateBody(method, route, bodyBytes)
+
+		if err != nil {
+			handler.writeProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error(), []consumer.Problem{})
+			return
+		}
+
+		if len(problems) != 0 {
+			handler.writeProblem(w, http.StatusUnprocessableEntity, CodeValidationFailed, "body doesn't match schema, see errors",
+				schemaProblems(problems))
+			return
+		}
+
+		// Handler reads body once more
+		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		next(w, r, params)
+	}
+}
+
+func schemaProblems(errs []openapi.ValidationError) []consumer.Problem {
+	// Path /order/1/amount is position 1 and field amount, like problems of order parsing
+	problems := make([]consumer.Problem, 0, len(errs))
+
+	for _, schemaErr := range errs {
+		problem := consumer.Problem{Reason: schemaErr.Reason}
+		segments := strings.Split(strings.TrimPrefix(schemaErr.Path, "/"), "/")
+
+		for i := len(segments) - 1; i >= 0; i-- {
+			if index, err := strconv.Atoi(segments[i]); err == nil {
+				problem.Index = &index
+				segments = segments[i+1:]
+
+				break
+			}
+		}
+
+		problem.Field = strings.Join(segments, ".")
+		problems = append(problems, problem)
+	}
+
+	return problems
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delonce/apishop/internal/delivery/openapi"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBody(t *testing.T) {
+	testTable := []struct {
+		name               string
+		inputBody          string
+		expectedStatusCode int
+		expectedReqBody    string
+	}{
+		{
+			name:               "OK body reaches handler unchanged",
+			inputBody:          `{"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":10}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":10}`,
+		},
+
+		{
+			name:               "Not JSON",
+			inputBody:          `name=apple`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"type":"about:blank","title":"Bad Request","status":400,"detail":"body should be JSON: invalid character 'a' in literal null (expecting 'u')","code":"invalid_request","errors":[]}`,
+		},
+
+		{
+			name:               "Wrong fields",
+			inputBody:          `{"name":"apple","cost":"200"}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"body doesn't match schema, see errors","code":"validation_failed","errors":[{"field":"amount","reason":"is required"},{"field":"cost","reason":"should be object"}]}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			router := httprouter.New()
+			transport := &NetworkHandler{Router: router, Spec: openapi.MustLoad()}
+
+			// Handler just echoes body
+			router.POST("/products", transport.ValidateBody("POST", "/products", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				bodyBytes, _ := io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusCreated)
+				w.Write(bodyBytes)
+			}))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/products", bytes.NewBufferString(testCase.inputBody)))
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+		})
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	transport := &NetworkHandler{Spec: openapi.MustLoad()}
+
+	w := httptest.NewRecorder()
+	transport.GetOpenAPI(w, httptest.NewRequest("GET", "/openapi.json", nil), nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"openapi": "3.0.3"`)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/service/consumer"
+)
+
+// Body isn't order at all, so there is nothing to validate
+var errMalformedOrder = errors.New("you need to send list of products with key 'order'")
+
+// Positions of one product are summed, every amount fits in int64, but their sum can't
+var errAmountOverflow = errors.New("sum of amounts of one product is too big")
+
+func parseOrder(bodyBytes []byte) (consumer.Request, error) {
+	// Consider POST query like map with string keys (product) and int64 values (amount)
+	order := map[string]int64{}
+
+	list, dataType, _, err := jsonparser.Get(bodyBytes, "order")
+
+	// Query without key 'order' is just empty order
+	if errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return consumer.Request{Order: order}, consumer.ValidateOrder(order)
+	}
+
+	if err != nil || dataType != jsonparser.Array {
+		return consumer.Request{}, errMalformedOrder
+	}
+
+	// All positions are checked, so client can fix everything at once
+	problems := []consumer.Problem{}
+	index := 0
+
+	// Promo code is optional
+	promoCode, err := jsonparser.GetString(bodyBytes, "promo_code")
+
+	if err != nil && !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		problems = append(problems, consumer.Problem{Field: "promo_code",
+			Reason: "you need to send string value with key 'promo_code'"})
+	}
+
+	var overflowErr error
+
+	_, err = jsonparser.ArrayEach(list, func(value []byte, dataType jsonparser.ValueType, offset int, _ error) {
+		position := index
+		index++
+
+		positionProblems, err := parsePosition(order, position, value)
+
+		if err != nil && overflowErr == nil {
+			overflowErr = err
+		}
+
+		problems = append(problems, positionProblems...)
+	})
+
+	if err != nil {
+		return consumer.Request{}, errMalformedOrder
+	}
+
+	if overflowErr != nil {
+		return consumer.Request{}, overflowErr
+	}
+
+	if len(problems) != 0 {
+		return consumer.Request{}, &consumer.ValidationError{Problems: problems}
+	}
+
+	return consumer.Request{Order: order, PromoCode: promoCode}, consumer.ValidateOrder(order)
+}
+
+func parsePosition(order map[string]int64, index int, value []byte) ([]consumer.Problem, error) {
+	problems := []consumer.Problem{}
+
+	productName, err := jsonparser.GetString(value, "product")
+
+	if err != nil {
+		problems = append(problems, consumer.Problem{Index: &index, Field: "product",
+			Reason: "you need to send string value with key 'product'"})
+	}
+
+	// Get nessesary amount of product
+	amountProduct, err := jsonparser.GetInt(value, "amount")
+
+	if err != nil {
+		problems = append(problems, consumer.Problem{Index: &index, Field: "amount",
+			Reason: "you need to send integer value with key 'amount'"})
+	} else if amountProduct < 0 {
+		problems = append(problems, consumer.Problem{Index: &index, Field: "amount",
+			Reason: "field 'amount' can't be less than 0"})
+	}
+
+	if len(problems) == 0 {
+		// Protect from POST query like [{"product":"apple","amount":2},{"product":"apple","amount":2}]
+		// Amounts aren't negative here, so only overflow over MaxInt64 is checked, like in money.Add
+		if order[productName] > math.MaxInt64-amountProduct {
+			return problems, fmt.Errorf("%w, product: %s", errAmountOverflow, productName)
+		}
+
+		order[productName] += amountProduct
+	}
+
+	return problems, nil
+}
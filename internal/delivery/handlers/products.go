@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/buger/jsonparser"
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/service/consumer"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/julienschmidt/httprouter"
+)
+
+// FOR REPLY TO BACK-OFFICE
+type ProductReply struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Cost        money.Money `json:"cost"`
+	Amount      int64       `json:"amount"`
+	TaxCategory string      `json:"tax_category"`
+}
+
+// Product from POST, PUT and PATCH queries
+// Nil field means that the key wasn't sent
+type productForm struct {
+	Name        *string
+	Cost        *money.Money
+	Amount      *int64
+	TaxCategory *string
+}
+
+func (handler *NetworkHandler) GetProducts(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	products, err := handler.Storage.SelectProducts()
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	reply := make([]ProductReply, 0, len(products))
+
+	for _, product := range products {
+		reply = append(reply, createProductReply(product))
+	}
+
+	handler.writeJson(w, http.StatusOK, reply)
+}
+
+func (handler *NetworkHandler) GetProduct(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	product, err := handler.Storage.SelectProductByName(params.ByName("name"))
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusOK, createProductReply(*product))
+}
+
+func (handler *NetworkHandler) CreateProduct(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	// New product needs all fields
+	form, err := parseProductForm(bodyBytes, false)
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if handler.rejectTaxCategory(w, form) {
+		return
+	}
+
+	product := database.Product{}
+	form.applyTo(&product)
+
+	product.ID, err = handler.Storage.InsertProduct(product)
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusCreated, createProductReply(product))
+}
+
+func (handler *NetworkHandler) ReplaceProduct(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	// PUT replaces whole product, so all fields are required
+	form, err := parseProductForm(bodyBytes, false)
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if handler.rejectTaxCategory(w, form) {
+		return
+	}
+
+	handler.updateProduct(w, params.ByName("name"), form)
+}
+
+func (handler *NetworkHandler) PatchProduct(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+
+	// PATCH changes only sent fields
+	form, err := parseProductForm(bodyBytes, true)
+
+	if err != nil {
+		handler.writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if handler.rejectTaxCategory(w, form) {
+		return
+	}
+
+	handler.updateProduct(w, params.ByName("name"), form)
+}
+
+func (handler *NetworkHandler) DeleteProduct(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	err := handler.Storage.DeleteProduct(params.ByName("name"))
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *NetworkHandler) updateProduct(w http.ResponseWriter, productName string, form productForm) {
+	updated := database.Product{}
+
+	// Read and write in one transaction, so reply has exactly the row that was written
+	// Row is locked, otherwise purchase between read and write would get its stock back
+	err := database.WithinTransaction(handler.Storage, func(tx database.ProductDB) error {
+		product, err := tx.SelectProductForUpdate(productName)
+
+		if err != nil {
+			return err
+		}
+
+		form.applyTo(product)
+
+		if err = tx.UpdateProduct(productName, *product); err != nil {
+			return err
+		}
+
+		updated = *product
+
+		return nil
+	})
+
+	if err != nil {
+		handler.writeStorageError(w, err)
+		return
+	}
+
+	handler.writeJson(w, http.StatusOK, createProductReply(updated))
+}
+
+func (handler *NetworkHandler) rejectTaxCategory(w http.ResponseWriter, form productForm) bool {
+	// Purchase of product without rate of its category would fail, so such product isn't written
+	if form.TaxCategory == nil {
+		return false
+	}
+
+	if _, ok := handler.TaxRates[*form.TaxCategory]; ok {
+		return false
+	}
+
+	handler.writeProblem(w, http.StatusUnprocessableEntity, CodeValidationFailed, "product has problems, see errors",
+		[]consumer.Problem{{Field: "tax_category", Reason: fmt.Sprintf("rate of tax category %s isn't configured", *form.TaxCategory)}})
+
+	return true
+}
+
+func parseProductForm(bodyBytes []byte, partial bool) (productForm, error) {
+	// Missing key is an error only if whole product is expected
+	form := productForm{}
+
+	productName, err := jsonparser.GetString(bodyBytes, "name")
+
+	if err == nil {
+		if productName == "" {
+			return form, errors.New("field 'name' can't be empty")
+		}
+
+		form.Name = &productName
+	} else if !partial || !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return form, errors.New("you need to send string value with key 'name'")
+	}
+
+	// Cost is sent in minor units with its currency: {"amount": 1999, "currency": "USD"}
+	costBytes, dataType, _, err := jsonparser.Get(bodyBytes, "cost")
+
+	if err == nil && dataType == jsonparser.Object {
+		cost, err := parseMoney(costBytes, "cost")
+
+		if err != nil {
+			return form, err
+		}
+
+		form.Cost = &cost
+	} else if !partial || !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return form, errors.New("you need to send object with keys 'amount' and 'currency' with key 'cost'")
+	}
+
+	amount, err := jsonparser.GetInt(bodyBytes, "amount")
+
+	if err == nil {
+		if amount < 0 {
+			return form, errors.New("field 'amount' can't be less than 0")
+		}
+
+		form.Amount = &amount
+	} else if !partial || !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return form, errors.New("you need to send integer value with key 'amount'")
+	}
+
+	// Tax category is optional, whole product without it gets default one
+	taxCategory, err := jsonparser.GetString(bodyBytes, "tax_category")
+
+	if err == nil {
+		if !database.TaxCategoryName.MatchString(taxCategory) {
+			return form, fmt.Errorf("field 'tax_category' should be lowercase word like %s, got %q", database.DefaultTaxCategory, taxCategory)
+		}
+
+		form.TaxCategory = &taxCategory
+	} else if !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return form, errors.New("you need to send string value with key 'tax_category'")
+	} else if !partial {
+		taxCategory = database.DefaultTaxCategory
+		form.TaxCategory = &taxCategory
+	}
+
+	// Protect from PATCH query that doesn't change anything
+	if form.Name == nil && form.Cost == nil && form.Amount == nil && form.TaxCategory == nil {
+		return form, errors.New("you need to send at least one of keys 'name', 'cost', 'amount', 'tax_category'")
+	}
+
+	return form, nil
+}
+
+func parseMoney(valueBytes []byte, key string) (money.Money, error) {
+	// Money is sent in minor units with its currency, key is used in errors
+	amount, err := jsonparser.GetInt(valueBytes, "amount")
+
+	if err != nil {
+		return money.Money{}, fmt.Errorf("you need to send integer value with key '%s.amount'", key)
+	}
+
+	if amount < 0 {
+		return money.Money{}, fmt.Errorf("field '%s.amount' can't be less than 0", key)
+	}
+
+	currency, err := jsonparser.GetString(valueBytes, "currency")
+
+	if err != nil {
+		return money.Money{}, fmt.Errorf("you need to send string value with key '%s.currency'", key)
+	}
+
+	value, err := money.New(amount, currency)
+
+	if err != nil {
+		return money.Money{}, fmt.Errorf("field '%s.currency' should be ISO 4217 code like USD, got %q", key, currency)
+	}
+
+	return value, nil
+}
+
+func (form productForm) applyTo(product *database.Product) {
+	// Copies sent fields into model
+	if form.Name != nil {
+		product.Name = *form.Name
+	}
+
+	if form.Cost != nil {
+		product.Cost = *form.Cost
+	}
+
+	if form.Amount != nil {
+		product.Amount = *form.Amount
+	}
+
+	if form.TaxCategory != nil {
+		product.TaxCategory = *form.TaxCategory
+	}
+}
+
+func createProductReply(product database.Product) ProductReply {
+	return ProductReply{
+		ID:          product.ID,
+		Name:        product.Name,
+		Cost:        product.Cost,
+		Amount:      product.Amount,
+		TaxCategory: product.TaxCategory,
+	}
+}
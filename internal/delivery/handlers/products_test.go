@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/internal/database/memory"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductHandlers(t *testing.T) {
+	type mockBehavior func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx)
+
+	apple := database.Product{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50, TaxCategory: "standard"}
+
+	testRequestTable := []struct {
+		name               string
+		method             string
+		path               string
+		inputBody          string
+		expectedStatusCode int
+		expectedReqBody    string
+		mockBehavior       mockBehavior
+	}{
+		{
+			name:               "List products",
+			method:             "GET",
+			path:               "/products",
+			expectedStatusCode: 200,
+			expectedReqBody:    `[{"id":1,"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":50,"tax_category":"standard"},{"id":2,"name":"melon","cost":{"amount":300,"currency":"USD"},"amount":10,"tax_category":"standard"}]`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().SelectProducts().Return([]database.Product{apple, {ID: 2, Name: "melon", Cost: money.Money{Amount: 300, Currency: "USD"}, Amount: 10, TaxCategory: "standard"}}, nil)
+			},
+		},
+
+		{
+			name:               "Empty catalogue",
+			method:             "GET",
+			path:               "/products",
+			expectedStatusCode: 200,
+			expectedReqBody:    `[]`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().SelectProducts().Return([]database.Product{}, nil)
+			},
+		},
+
+		{
+			name:               "Get product",
+			method:             "GET",
+			path:               "/products/apple",
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"id":1,"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":50,"tax_category":"standard"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().SelectProductByName("apple").Return(&apple, nil)
+			},
+		},
+
+		{
+			name:               "Get not existing product",
+			method:             "GET",
+			path:               "/products/kiwi",
+			expectedStatusCode: 404,
+			expectedReqBody:    `{"critical_error":"product doesn't exist, name: kiwi","code":"product_not_found"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().SelectProductByName("kiwi").Return(nil, fmt.Errorf("%w, name: kiwi", database.ErrProductNotFound))
+			},
+		},
+
+		{
+			name:               "Storage failure is hidden",
+			method:             "GET",
+			path:               "/products",
+			expectedStatusCode: 500,
+			expectedReqBody:    `{"critical_error":"internal storage error, try again later","code":"internal_error"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().SelectProducts().Return(nil, errors.New("connection refused"))
+			},
+		},
+
+		{
+			name:               "Create product",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"kiwi","cost":{"amount":150,"currency":"USD"},"amount":5}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":3,"name":"kiwi","cost":{"amount":150,"currency":"USD"},"amount":5,"tax_category":"standard"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().InsertProduct(database.Product{Name: "kiwi", Cost: money.Money{Amount: 150, Currency: "USD"}, Amount: 5, TaxCategory: "standard"}).Return(int64(3), nil)
+			},
+		},
+
+		{
+			name:               "Create existing product",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"apple","cost":{"amount":150,"currency":"USD"},"amount":5}`,
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"product already exists, name: apple","code":"product_exists"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 150, Currency: "USD"}, Amount: 5, TaxCategory: "standard"}).Return(int64(0), fmt.Errorf("%w, name: apple", database.ErrProductExists))
+			},
+		},
+
+		{
+			name:               "Create product without cost",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"kiwi","amount":5}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send object with keys 'amount' and 'currency' with key 'cost'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Create product with minus amount",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"kiwi","cost":{"amount":150,"currency":"USD"},"amount":-5}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'amount' can't be less than 0","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Create product with empty name",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"","cost":{"amount":150,"currency":"USD"},"amount":5}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'name' can't be empty","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Create product with tax category",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"bread","cost":{"amount":100,"currency":"USD"},"amount":5,"tax_category":"food"}`,
+			expectedStatusCode: 201,
+			expectedReqBody:    `{"id":4,"name":"bread","cost":{"amount":100,"currency":"USD"},"amount":5,"tax_category":"food"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().InsertProduct(database.Product{Name: "bread", Cost: money.Money{Amount: 100, Currency: "USD"}, Amount: 5, TaxCategory: "food"}).Return(int64(4), nil)
+			},
+		},
+
+		{
+			name:               "Create product with wrong tax category",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"bread","cost":{"amount":100,"currency":"USD"},"amount":5,"tax_category":"Food 10%"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'tax_category' should be lowercase word like standard, got \"Food 10%\"","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			// Every purchase of such product would fail, so it isn't written
+			name:               "Create product with not configured tax category",
+			method:             "POST",
+			path:               "/products",
+			inputBody:          `{"name":"bread","cost":{"amount":100,"currency":"USD"},"amount":5,"tax_category":"luxury"}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"product has problems, see errors","code":"validation_failed","errors":[{"field":"tax_category","reason":"rate of tax category luxury isn't configured"}]}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Replace product",
+			method:             "PUT",
+			path:               "/products/apple",
+			inputBody:          `{"name":"green apple","cost":{"amount":250,"currency":"USD"},"amount":40}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"id":1,"name":"green apple","cost":{"amount":250,"currency":"USD"},"amount":40,"tax_category":"standard"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				current := apple
+
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().SelectProductForUpdate("apple").Return(&current, nil)
+				tx.EXPECT().UpdateProduct("apple", database.Product{ID: 1, Name: "green apple", Cost: money.Money{Amount: 250, Currency: "USD"}, Amount: 40, TaxCategory: "standard"}).Return(nil)
+				tx.EXPECT().Commit().Return(nil)
+			},
+		},
+
+		{
+			name:               "Replace product needs all fields",
+			method:             "PUT",
+			path:               "/products/apple",
+			inputBody:          `{"name":"apple","cost":{"amount":250,"currency":"USD"}}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send integer value with key 'amount'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Patch product",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{"amount":70}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"id":1,"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":70,"tax_category":"standard"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				current := apple
+
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().SelectProductForUpdate("apple").Return(&current, nil)
+				tx.EXPECT().UpdateProduct("apple", database.Product{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 70, TaxCategory: "standard"}).Return(nil)
+				tx.EXPECT().Commit().Return(nil)
+			},
+		},
+
+		{
+			name:               "Patch not configured tax category",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{"tax_category":"luxury"}`,
+			expectedStatusCode: 422,
+			expectedReqBody:    `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"product has problems, see errors","code":"validation_failed","errors":[{"field":"tax_category","reason":"rate of tax category luxury isn't configured"}]}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Patch tax category",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{"tax_category":"food"}`,
+			expectedStatusCode: 200,
+			expectedReqBody:    `{"id":1,"name":"apple","cost":{"amount":200,"currency":"USD"},"amount":50,"tax_category":"food"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				current := apple
+
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().SelectProductForUpdate("apple").Return(&current, nil)
+				tx.EXPECT().UpdateProduct("apple", database.Product{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50, TaxCategory: "food"}).Return(nil)
+				tx.EXPECT().Commit().Return(nil)
+			},
+		},
+
+		{
+			name:               "Patch not existing product",
+			method:             "PATCH",
+			path:               "/products/kiwi",
+			inputBody:          `{"amount":70}`,
+			expectedStatusCode: 404,
+			expectedReqBody:    `{"critical_error":"product doesn't exist, name: kiwi","code":"product_not_found"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().SelectProductForUpdate("kiwi").Return(nil, fmt.Errorf("%w, name: kiwi", database.ErrProductNotFound))
+				tx.EXPECT().Rollback().Return(nil)
+			},
+		},
+
+		{
+			name:               "Patch without fields",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send at least one of keys 'name', 'cost', 'amount', 'tax_category'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Patch with string cost",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{"cost":"100"}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send object with keys 'amount' and 'currency' with key 'cost'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Patch with unknown currency",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{"cost":{"amount":100,"currency":"XYZ"}}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"field 'cost.currency' should be ISO 4217 code like USD, got \"XYZ\"","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Patch cost without amount",
+			method:             "PATCH",
+			path:               "/products/apple",
+			inputBody:          `{"cost":{"currency":"EUR"}}`,
+			expectedStatusCode: 400,
+			expectedReqBody:    `{"critical_error":"you need to send integer value with key 'cost.amount'","code":"invalid_request"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name:               "Delete product",
+			method:             "DELETE",
+			path:               "/products/apple",
+			expectedStatusCode: 204,
+			expectedReqBody:    ``,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().DeleteProduct("apple").Return(nil)
+			},
+		},
+
+		{
+			name:               "Delete product from checks",
+			method:             "DELETE",
+			path:               "/products/apple",
+			expectedStatusCode: 409,
+			expectedReqBody:    `{"critical_error":"product is used in checks, name: apple","code":"product_in_use"}`,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx) {
+				s.EXPECT().DeleteProduct("apple").Return(fmt.Errorf("%w, name: apple", database.ErrProductInUse))
+			},
+		},
+	}
+
+	for _, testCase := range testRequestTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+			tx := mock_db.NewMockProductTx(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(prodDB, tx)
+
+			router := httprouter.New()
+
+			// Handler with Storage (mock)
+			transport := &NetworkHandler{
+				Storage:       prodDB,
+				HandlerLogger: nil,
+				Router:        router,
+				TaxRates:      map[string]int64{"standard": 2000, "food": 1000},
+			}
+
+			// Catalogue paths
+			router.GET("/products", transport.GetProducts)
+			router.POST("/products", transport.CreateProduct)
+			router.GET("/products/:name", transport.GetProduct)
+			router.PUT("/products/:name", transport.ReplaceProduct)
+			router.PATCH("/products/:name", transport.PatchProduct)
+			router.DELETE("/products/:name", transport.DeleteProduct)
+
+			// Send input body
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.method, testCase.path, bytes.NewBufferString(testCase.inputBody))
+
+			// Start server
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedReqBody, w.Body.String())
+
+			// Products and errors are json, problems of product are RFC 7807, 204 has no body at all
+			if testCase.expectedStatusCode == 422 {
+				assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			} else if testCase.expectedReqBody != "" {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestPatchDoesntReturnReservedStock(t *testing.T) {
+	// Purchases reserve stock while cost is changed, every reserved item stays sold (run it with -race)
+	prodDB := memory.NewStorage()
+	_, err := prodDB.InsertProduct(database.Product{Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 100})
+	assert.Nil(t, err)
+
+	router := httprouter.New()
+	transport := &NetworkHandler{Storage: prodDB, Router: router}
+	router.PATCH("/products/:name", transport.PatchProduct)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			assert.Nil(t, database.WithinTransaction(prodDB, func(tx database.ProductDB) error {
+				return tx.ReserveProducts(map[string]int64{"apple": 1})
+			}))
+		}()
+
+		go func(cost int) {
+			defer wg.Done()
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("PATCH", "/products/apple",
+				bytes.NewBufferString(fmt.Sprintf(`{"cost":{"amount":%d,"currency":"USD"}}`, cost))))
+			assert.Equal(t, 200, w.Code)
+		}(200 + i)
+	}
+
+	wg.Wait()
+
+	apple, err := prodDB.SelectProductByName("apple")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50), apple.Amount)
+}
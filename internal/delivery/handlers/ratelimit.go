@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delonce/apishop/pkg/ratelimit"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/julienschmidt/httprouter"
+)
+
+// How long API key found in storage is taken as valid by rate limiter without new query
+const knownKeyTTL = time.Minute
+
+// Lets client in while its bucket of route has tokens, otherwise replies 429
+// Client is API key that was found in storage recently, otherwise address of client
+// Unknown key is looked up only after token of address is taken, so rejected query costs nothing
+// and random keys can't be used to get new buckets
+func (handler *NetworkHandler) RateLimit(limiter *ratelimit.Limiter, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		rawKey := r.Header.Get(apiKeyHeader)
+		keyHash := secret.Hash(rawKey)
+		client := "ip:" + handler.clientIP(r)
+
+		if rawKey != "" && handler.isKnownKey(keyHash) {
+			client = "key:" + keyHash
+		}
+
+		decision := limiter.Allow(client)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+
+		if !decision.Allowed {
+			retryAfter := seconds(decision.RetryAfter)
+
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			handler.writeError(w, http.StatusTooManyRequests, CodeRateLimited,
+				fmt.Sprintf("too many requests, try again in %d seconds", retryAfter))
+			return
+		}
+
+		// Found key is kept in context, so RequireRole doesn't read it again
+		// Next queries with it are limited by its own bucket
+		if rawKey != "" && client != "key:"+keyHash {
+			if key, err := handler.Storage.SelectAPIKey(keyHash); err == nil {
+				handler.knownKeys.Store(keyHash, time.Now().Add(knownKeyTTL))
+				r = r.WithContext(context.WithValue(r.Context(), apiKeyKey{}, *key))
+			}
+		}
+
+		handle(w, r, params)
+	}
+}
+
+func (handler *NetworkHandler) isKnownKey(keyHash string) bool {
+	// Revoked key keeps its bucket till TTL ends, RequireRole still reads key from storage
+	expiresAt, ok := handler.knownKeys.Load(keyHash)
+
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(expiresAt.(time.Time)) {
+		handler.knownKeys.Delete(keyHash)
+		return false
+	}
+
+	return true
+}
+
+func (handler *NetworkHandler) clientIP(r *http.Request) string {
+	// Proxy appends address of its client to the end, addresses before it are sent by client and can be forged
+	if handler.TrustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+
+			if last := strings.TrimSpace(addresses[len(addresses)-1]); last != "" {
+				return last
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func seconds(duration time.Duration) int {
+	// Headers have whole seconds, client that waits less would be rejected again
+	return int(math.Ceil(duration.Seconds()))
+}
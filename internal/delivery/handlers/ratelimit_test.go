@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/ratelimit"
+	"github.com/delonce/apishop/pkg/secret"
+	"github.com/golang/mock/gomock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitClients(t *testing.T) {
+	// Bucket of one query per minute shows which queries are taken as one client
+	type query struct {
+		apiKey     string
+		forwarded  string
+		remoteAddr string
+	}
+
+	type mockBehavior func(s *mock_db.MockProductDB)
+
+	testTable := []struct {
+		name              string
+		trustForwardedFor bool
+		queries           []query
+		expectedCodes     []int
+		mockBehavior      mockBehavior
+	}{
+		{
+			name:          "Address of client",
+			queries:       []query{{remoteAddr: "10.0.0.1:5000"}, {remoteAddr: "10.0.0.1:5001"}},
+			expectedCodes: []int{200, 429},
+			mockBehavior:  func(s *mock_db.MockProductDB) {},
+		},
+
+		{
+			name:          "Different addresses",
+			queries:       []query{{remoteAddr: "10.0.0.1:5000"}, {remoteAddr: "10.0.0.2:5000"}},
+			expectedCodes: []int{200, 200},
+			mockBehavior:  func(s *mock_db.MockProductDB) {},
+		},
+
+		{
+			// First query is paid by address, key is found and gets own bucket
+			name: "API key from different addresses",
+			queries: []query{
+				{apiKey: "apishop_operator", remoteAddr: "10.0.0.1:5000"},
+				{apiKey: "apishop_operator", remoteAddr: "10.0.0.2:5000"},
+				{apiKey: "apishop_operator", remoteAddr: "10.0.0.3:5000"},
+			},
+			expectedCodes: []int{200, 200, 429},
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_operator")).Return(&database.APIKey{ID: 1, Role: database.RoleOperator}, nil).Times(1)
+			},
+		},
+
+		{
+			// Second key isn't looked up, address has no tokens
+			name: "Unknown keys don't give new buckets",
+			queries: []query{
+				{apiKey: "apishop_first", remoteAddr: "10.0.0.1:5000"},
+				{apiKey: "apishop_second", remoteAddr: "10.0.0.1:5000"},
+			},
+			expectedCodes: []int{200, 429},
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectAPIKey(secret.Hash("apishop_first")).Return(nil, database.ErrAPIKeyNotFound)
+			},
+		},
+
+		{
+			name: "Forwarded address isn't trusted",
+			queries: []query{
+				{forwarded: "192.168.1.1", remoteAddr: "10.0.0.1:5000"},
+				{forwarded: "192.168.1.2", remoteAddr: "10.0.0.1:5000"},
+			},
+			expectedCodes: []int{200, 429},
+			mockBehavior:  func(s *mock_db.MockProductDB) {},
+		},
+
+		{
+			name:              "Client behind proxy",
+			trustForwardedFor: true,
+			queries: []query{
+				{forwarded: "1.1.1.1, 192.168.1.1", remoteAddr: "10.0.0.1:5000"},
+				{forwarded: "1.1.1.1, 192.168.1.2", remoteAddr: "10.0.0.1:5000"},
+			},
+			expectedCodes: []int{200, 200},
+			mockBehavior:  func(s *mock_db.MockProductDB) {},
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+
+			// Starts nessesary behavior
+			testCase.mockBehavior(prodDB)
+
+			router := httprouter.New()
+
+			transport := &NetworkHandler{
+				Storage:           prodDB,
+				HandlerLogger:     nil,
+				Router:            router,
+				TrustForwardedFor: testCase.trustForwardedFor,
+			}
+
+			limiter := ratelimit.NewLimiter(ratelimit.Limit{Requests: 1, Period: time.Minute})
+			router.GET("/quote", transport.RateLimit(limiter, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				w.Write([]byte("ok"))
+			}))
+
+			codes := []int{}
+
+			for _, q := range testCase.queries {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/quote", nil)
+				req.RemoteAddr = q.remoteAddr
+
+				if q.apiKey != "" {
+					req.Header.Set("X-API-Key", q.apiKey)
+				}
+
+				if q.forwarded != "" {
+					req.Header.Set("X-Forwarded-For", q.forwarded)
+				}
+
+				router.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+
+			assert.Equal(t, testCase.expectedCodes, codes)
+		})
+	}
+}
+
+func TestRateLimitKeepsAPIKey(t *testing.T) {
+	// Key found by limiter is checked by RequireRole without second query to storage
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	prodDB := mock_db.NewMockProductDB(c)
+	prodDB.EXPECT().SelectAPIKey(secret.Hash("apishop_customer")).Return(&database.APIKey{ID: 3, Role: database.RoleCustomer}, nil).Times(1)
+
+	router := httprouter.New()
+	transport := &NetworkHandler{Storage: prodDB, Router: router}
+
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Requests: 5, Period: time.Minute})
+	router.POST("/products", transport.RateLimit(limiter, transport.RequireRole(database.RoleOperator,
+		func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Write([]byte("ok"))
+		})))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/products", nil)
+	req.Header.Set("X-API-Key", "apishop_customer")
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
+}
@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Contract of http API, handlers are checked against it by tests and bodies are validated by it
+//
+//go:embed openapi.json
+var document []byte
+
+// Methods of Path Item Object, other keys (e.g. parameters) aren't operations
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+type Spec struct {
+	raw   []byte
+	paths map[string]map[string]operation
+	// Schemas of components, referenced by "$ref"
+	schemas map[string]*Schema
+}
+
+// Method and route in httprouter form, e.g. GET /products/:name
+type Operation struct {
+	Method string
+	Route  string
+}
+
+type operation struct {
+	RequestBody *struct {
+		Required bool                 `json:"required"`
+		Content  map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Problem of request body, Path is JSON pointer to wrong value
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+func Load() (*Spec, error) {
+	var parsed struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+
+	if err := json.Unmarshal(document, &parsed); err != nil {
+		return nil, fmt.Errorf("openapi document: %w", err)
+	}
+
+	spec := &Spec{
+		raw:     document,
+		paths:   make(map[string]map[string]operation, len(parsed.Paths)),
+		schemas: parsed.Components.Schemas,
+	}
+
+	for path, item := range parsed.Paths {
+		spec.paths[path] = make(map[string]operation)
+
+		for _, method := range operationMethods {
+			rawOperation, ok := item[method]
+
+			if !ok {
+				continue
+			}
+
+			var op operation
+
+			if err := json.Unmarshal(rawOperation, &op); err != nil {
+				return nil, fmt.Errorf("openapi operation %s %s: %w", method, path, err)
+			}
+
+			spec.paths[path][method] = op
+		}
+	}
+
+	return spec, nil
+}
+
+func MustLoad() *Spec {
+	// Document is embedded and checked by tests, so error here is a bug of build
+	spec, err := Load()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return spec
+}
+
+func (spec *Spec) Raw() []byte {
+	return spec.raw
+}
+
+func (spec *Spec) Operations() []Operation {
+	operations := []Operation{}
+
+	for path, item := range spec.paths {
+		for method := range item {
+			operations = append(operations, Operation{Method: strings.ToUpper(method), Route: routeOf(path)})
+		}
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Route != operations[j].Route {
+			return operations[i].Route < operations[j].Route
+		}
+
+		return operations[i].Method < operations[j].Method
+	})
+
+	return operations
+}
+
+func (spec *Spec) Describes(method, route string) bool {
+	_, ok := spec.paths[pathOf(route)][strings.ToLower(method)]
+	return ok
+}
+
+func (spec *Spec) ValidateBody(method, route string, body []byte) ([]ValidationError, error) {
+	// Route without request body in document accepts anything, handler ignores body
+	schema := spec.bodySchema(method, route)
+
+	if schema == nil {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Integers stay exact, float64 would lose big values
+	decoder.UseNumber()
+
+	var value interface{}
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("body should be JSON: %w", err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("body should be one JSON value")
+	}
+
+	problems := []ValidationError{}
+	schema.validate(spec, "", value, &problems)
+
+	return problems, nil
+}
+
+func (spec *Spec) bodySchema(method, route string) *Schema {
+	op, ok := spec.paths[pathOf(route)][strings.ToLower(method)]
+
+	if !ok || op.RequestBody == nil {
+		return nil
+	}
+
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+func pathOf(route string) string {
+	// /products/:name is /products/{name} in document
+	parts := strings.Split(route, "/")
+
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func routeOf(path string) string {
+	parts := strings.Split(path, "/")
+
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = ":" + part[1:len(part)-1]
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
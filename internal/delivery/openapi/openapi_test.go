@@ -0,0 +1,113 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	spec, err := Load()
+	assert.Nil(t, err)
+
+	// Routes are in httprouter form
+	assert.True(t, spec.Describes("POST", "/"))
+	assert.True(t, spec.Describes("PATCH", "/products/:name"))
+	assert.False(t, spec.Describes("POST", "/checks"))
+	assert.Contains(t, spec.Operations(), Operation{Method: "GET", Route: "/checks/:id"})
+}
+
+func TestValidateBody(t *testing.T) {
+	spec := MustLoad()
+
+	testTable := []struct {
+		name             string
+		method           string
+		route            string
+		body             string
+		expectedProblems []ValidationError
+		expectedError    string
+	}{
+		{
+			name:             "OK order",
+			method:           "POST",
+			route:            "/",
+			body:             `{"order":[{"product":"apple","amount":45},{"product":"melon","amount":0}]}`,
+			expectedProblems: []ValidationError{},
+		},
+
+		{
+			name:   "Wrong positions",
+			method: "POST",
+			route:  "/",
+			body:   `{"order":[{"product":"apple","amount":-1},{"amount":"5"},{"product":"","amount":1.5}]}`,
+			expectedProblems: []ValidationError{
+				{Path: "/order/0/amount", Reason: "should be at least 0"},
+				{Path: "/order/1/product", Reason: "is required"},
+				{Path: "/order/1/amount", Reason: "should be integer"},
+				{Path: "/order/2/amount", Reason: "should be integer"},
+				{Path: "/order/2/product", Reason: "should have at least 1 characters"},
+			},
+		},
+
+		{
+			name:   "Empty order",
+			method: "POST",
+			route:  "/",
+			body:   `{"order":[]}`,
+			expectedProblems: []ValidationError{
+				{Path: "/order", Reason: "should have at least 1 items"},
+			},
+		},
+
+		{
+			name:   "Without order",
+			method: "POST",
+			route:  "/",
+			body:   `{}`,
+			expectedProblems: []ValidationError{
+				{Path: "/order", Reason: "is required"},
+			},
+		},
+
+		{
+			name:   "Nothing to patch",
+			method: "PATCH",
+			route:  "/products/:name",
+			body:   `{}`,
+			expectedProblems: []ValidationError{
+				{Path: "", Reason: "should have at least 1 of keys"},
+			},
+		},
+
+		{
+			name:          "Not JSON",
+			method:        "POST",
+			route:         "/products",
+			body:          `{"name":`,
+			expectedError: "body should be JSON: unexpected EOF",
+		},
+
+		{
+			name:             "Route without body",
+			method:           "GET",
+			route:            "/products",
+			body:             `not json at all`,
+			expectedProblems: nil,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			problems, err := spec.ValidateBody(testCase.method, testCase.route, []byte(testCase.body))
+
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expectedProblems, problems)
+		})
+	}
+}
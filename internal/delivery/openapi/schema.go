@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Part of JSON Schema that is used in document
+// Unknown keywords (format, description, ...) are ignored by validation
+type Schema struct {
+	Ref string `json:"$ref"`
+
+	Type       string             `json:"type"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *Schema            `json:"items"`
+
+	// false or schema of values
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+
+	Minimum       *float64 `json:"minimum"`
+	Maximum       *float64 `json:"maximum"`
+	MinLength     *int     `json:"minLength"`
+	MaxLength     *int     `json:"maxLength"`
+	MinItems      *int     `json:"minItems"`
+	MinProperties *int     `json:"minProperties"`
+}
+
+func (schema *Schema) validate(spec *Spec, path string, value interface{}, problems *[]ValidationError) {
+	if schema.Ref != "" {
+		// Only local references to component schemas are used
+		referenced, ok := spec.schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+
+		if !ok {
+			*problems = append(*problems, ValidationError{Path: path, Reason: "unknown schema " + schema.Ref})
+			return
+		}
+
+		referenced.validate(spec, path, value, problems)
+
+		return
+	}
+
+	addProblem := func(format string, args ...interface{}) {
+		*problems = append(*problems, ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+
+		if !ok {
+			addProblem("should be object")
+			return
+		}
+
+		schema.validateObject(spec, path, object, problems)
+	case "array":
+		array, ok := value.([]interface{})
+
+		if !ok {
+			addProblem("should be array")
+			return
+		}
+
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			addProblem("should have at least %d items", *schema.MinItems)
+		}
+
+		if schema.Items != nil {
+			for i, item := range array {
+				schema.Items.validate(spec, path+"/"+strconv.Itoa(i), item, problems)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+
+		if !ok {
+			addProblem("should be string")
+			return
+		}
+
+		if schema.MinLength != nil && utf8.RuneCountInString(str) < *schema.MinLength {
+			addProblem("should have at least %d characters", *schema.MinLength)
+		}
+
+		if schema.MaxLength != nil && utf8.RuneCountInString(str) > *schema.MaxLength {
+			addProblem("should have at most %d characters", *schema.MaxLength)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+
+		if !ok {
+			addProblem("should be %s", schema.Type)
+			return
+		}
+
+		// Integer has to fit into int64 like fields of models
+		if _, err := number.Int64(); schema.Type == "integer" && err != nil {
+			addProblem("should be integer")
+			return
+		}
+
+		parsed, _ := number.Float64()
+
+		if schema.Minimum != nil && parsed < *schema.Minimum {
+			addProblem("should be at least %v", *schema.Minimum)
+		}
+
+		if schema.Maximum != nil && parsed > *schema.Maximum {
+			addProblem("should be at most %v", *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			addProblem("should be boolean")
+		}
+	}
+}
+
+func (schema *Schema) validateObject(spec *Spec, path string, object map[string]interface{}, problems *[]ValidationError) {
+	for _, key := range schema.Required {
+		if _, ok := object[key]; !ok {
+			*problems = append(*problems, ValidationError{Path: path + "/" + key, Reason: "is required"})
+		}
+	}
+
+	if schema.MinProperties != nil && len(object) < *schema.MinProperties {
+		*problems = append(*problems, ValidationError{Path: path,
+			Reason: fmt.Sprintf("should have at least %d of keys", *schema.MinProperties)})
+	}
+
+	var additional *Schema
+	forbidden := string(schema.AdditionalProperties) == "false"
+
+	if len(schema.AdditionalProperties) != 0 && !forbidden {
+		json.Unmarshal(schema.AdditionalProperties, &additional)
+	}
+
+	// Keys are checked in stable order, so problems are always the same
+	keys := make([]string, 0, len(object))
+
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if property, ok := schema.Properties[key]; ok {
+			property.validate(spec, path+"/"+key, object[key], problems)
+		} else if forbidden {
+			*problems = append(*problems, ValidationError{Path: path + "/" + key, Reason: "is unknown"})
+		} else if additional != nil {
+			additional.validate(spec, path+"/"+key, object[key], problems)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/logging"
+)
+
+// Reads all products of order with one query
+// Other subscribers use this snapshot instead of asking database for every product
+type CatalogueSubscriber struct {
+	name   string
+	prodDB database.ProductDB
+	logger *logging.Logger
+}
+
+func GetCatalogue(name string, prodDB database.ProductDB, logger *logging.Logger) Consumer {
+	return &CatalogueSubscriber{
+		name:   name,
+		prodDB: prodDB,
+		logger: logger,
+	}
+}
+
+func (catalogue *CatalogueSubscriber) GetName() string {
+	return catalogue.name
+}
+
+func (catalogue *CatalogueSubscriber) Produces() []string {
+	return []string{CatalogueArtifact}
+}
+
+func (catalogue *CatalogueSubscriber) DependsOn() []string {
+	return nil
+}
+
+func (catalogue *CatalogueSubscriber) Update(ctx context.Context, purchase *Purchase) error {
+	names := sortedNames(purchase.Order)
+
+	// If error happened other subscribers are cancelled by subject, they don't wait for snapshot
+	products, err := catalogue.prodDB.SelectProductsByNames(names)
+
+	if err != nil {
+		return err
+	}
+
+	snapshot := make(map[string]database.Product, len(products))
+
+	for _, product := range products {
+		snapshot[product.Name] = product
+	}
+
+	// Order with unknown product can't be processed at all
+	for _, name := range names {
+		if _, ok := snapshot[name]; !ok {
+			return fmt.Errorf("%w, name: %s", database.ErrProductNotFound, name)
+		}
+	}
+
+	purchase.Publish(CatalogueArtifact, snapshot)
+
+	return nil
+}
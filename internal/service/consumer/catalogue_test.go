@@ -0,0 +1,104 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func publishCatalogue(purchase *Purchase, products []*database.Product) {
+	// Catalogue's part for tests of other subscribers
+	snapshot := map[string]database.Product{}
+
+	for _, product := range products {
+		snapshot[product.Name] = *product
+	}
+
+	purchase.Publish(CatalogueArtifact, snapshot)
+}
+
+func TestCatalogueUpdate(t *testing.T) {
+	// Test checks that the whole order is read with one query
+	type mockBehavior func(s *mock_db.MockProductDB)
+
+	apple := database.Product{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50}
+	melon := database.Product{ID: 2, Name: "melon", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10}
+
+	testTable := []struct {
+		name             string
+		inputOrder       map[string]int64
+		expectedSnapshot map[string]database.Product
+		expectedError    error
+		mockBehavior     mockBehavior
+	}{
+		{
+			name: "OK",
+			inputOrder: map[string]int64{
+				"melon": 1,
+				"apple": 10,
+			},
+			expectedSnapshot: map[string]database.Product{"apple": apple, "melon": melon},
+			expectedError:    nil,
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectProductsByNames([]string{"apple", "melon"}).Return([]database.Product{apple, melon}, nil)
+			},
+		},
+
+		{
+			name: "Unknown product",
+			inputOrder: map[string]int64{
+				"apple": 10,
+				"kiwi":  1,
+			},
+			expectedError: errors.New("product doesn't exist, name: kiwi"),
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectProductsByNames([]string{"apple", "kiwi"}).Return([]database.Product{apple}, nil)
+			},
+		},
+
+		{
+			name: "Error from database",
+			inputOrder: map[string]int64{
+				"apple": 10,
+			},
+			expectedError: errors.New("db mock error"),
+			mockBehavior: func(s *mock_db.MockProductDB) {
+				s.EXPECT().SelectProductsByNames([]string{"apple"}).Return(nil, errors.New("db mock error"))
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+			testCase.mockBehavior(prodDB)
+
+			catalogue := GetCatalogue("Test Catalogue Sub", prodDB, nil)
+			purchase := NewPurchase(Request{Order: testCase.inputOrder})
+
+			err := catalogue.Update(context.TODO(), purchase)
+
+			// Snapshot isn't published if error happened
+			published, _ := purchase.Result(CatalogueArtifact)
+			snapshot, _ := published.(map[string]database.Product)
+
+			if testCase.expectedError == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.expectedError.Error())
+			}
+
+			assert.Equal(t, testCase.expectedSnapshot, snapshot)
+		})
+	}
+}
@@ -6,22 +6,24 @@ import (
 
 	"github.com/delonce/apishop/internal/database"
 	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/metrics"
 )
 
 type CheckCreatorSubscriber struct {
 	name   string
 	prodDB database.ProductDB
 	logger *logging.Logger
-	reply  chan ClientCheck
+
+	// Confirmed and rejected purchases
+	checks *metrics.CounterVec
 }
 
-func GetCheckCreator(name string, prodDB database.ProductDB,
-	logger *logging.Logger, clientForm chan ClientCheck) Consumer {
+func GetCheckCreator(name string, prodDB database.ProductDB, logger *logging.Logger, registry *metrics.Registry) Consumer {
 	return &CheckCreatorSubscriber{
 		name:   name,
 		prodDB: prodDB,
 		logger: logger,
-		reply:  clientForm,
+		checks: registry.Counter("apishop_checks_total", "Purchase checks by result.", "result"),
 	}
 }
 
@@ -29,67 +31,104 @@ func (creator *CheckCreatorSubscriber) GetName() string {
 	return creator.name
 }
 
-func (creator *CheckCreatorSubscriber) Update(ctx context.Context, order map[string]int64) error {
-	select {
-	case <-ctx.Done():
-		// Handle Cancelation
-		return ctx.Err()
-	case repForm := <-creator.reply:
-		if creator.logger != nil {
-			creator.logger.Trace("Starting Check Creator...")
-		}
+func (creator *CheckCreatorSubscriber) Produces() []string {
+	return []string{CheckIDArtifact}
+}
 
-		// Waiting for check from order (PostReader)
-		// If empty check end func
-		if repForm.IsConf {
-			// Initialize new check
-			purchCheck := database.Check{
-				IsConfirmed: repForm.IsConf,
-				DateAt:      time.Now(),
-			}
+func (creator *CheckCreatorSubscriber) DependsOn() []string {
+	return []string{CatalogueArtifact, VerdictArtifact}
+}
 
-			// Get created check's id
-			checkId, err := creator.prodDB.InsertCheck(purchCheck)
+func (creator *CheckCreatorSubscriber) Update(ctx context.Context, purchase *Purchase) error {
+	// Waiting for check from order (Validator)
+	repForm, err := purchase.Verdict(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if creator.logger != nil {
+		creator.logger.Trace("Starting Check Creator...")
+	}
+
+	// Ids of products for positions
+	snapshot, err := purchase.Catalogue(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// Not confirmed check isn't written, so it doesn't have id
+	var checkId int64
+
+	if repForm.IsConf {
+		// Check, its positions and stock reservation are written all together or not written at all
+		err = database.WithinTransaction(creator.prodDB, func(tx database.ProductDB) error {
+			// Validator has seen enough products, but somebody could buy them after that
+			// Decrement stock first, so confirmed check always has really reserved products
+			err := tx.ReserveProducts(purchase.Order)
 
 			if err != nil {
 				return err
 			}
-			err = creator.addPositions(checkId, order)
+
+			// Initialize new check, its sums are written as client has seen them
+			purchCheck := database.Check{
+				IsConfirmed: repForm.IsConf,
+				DateAt:      time.Now().UTC(),
+				CustomerID:  purchase.CustomerID,
+				Subtotal:    repForm.Subtotal,
+				Tax:         repForm.Tax,
+				Total:       repForm.TotalSum,
+			}
+
+			// Get created check's id
+			checkId, err = tx.InsertCheck(purchCheck)
 
 			if err != nil {
 				return err
 			}
 
-			return nil
+			return creator.addPositions(tx, checkId, repForm.Positions, snapshot)
+		})
 
-		} else {
-			return nil
+		if err != nil {
+			return err
 		}
+
+		creator.checks.Inc("confirmed")
+	} else {
+		creator.checks.Inc("rejected")
 	}
-}
 
-func (creator *CheckCreatorSubscriber) addPositions(checkId int64, order map[string]int64) error {
-	// Add all position from check to order table
-	for name, reqAmount := range order {
-		// Initialize new position
-		order := database.Order{}
+	// Replier shows id to client, so he can read his check later
+	purchase.Publish(CheckIDArtifact, checkId)
 
-		// Get info about some product
-		product, err := creator.prodDB.SelectProductByName(name)
+	return nil
+}
 
-		if err != nil {
-			return err
+func (creator *CheckCreatorSubscriber) addPositions(tx database.ProductDB, checkId int64, positions []ProductPosition,
+	snapshot map[string]database.Product) error {
+	// Add all position from check to order table
+	for _, priced := range positions {
+		// Id of product doesn't change, so it can be taken from snapshot
+		// If product was deleted after that, foreign key doesn't let insert position
+		// Name and costs are written as client has seen them, so check doesn't change with product
+		position := database.Order{
+			CheckID:     checkId,
+			ProductID:   snapshot[priced.Product].ID,
+			ReqAmount:   priced.ReqAmount,
+			TaxCategory: priced.TaxCategory,
+			TaxRate:     priced.TaxRate,
+			Tax:         priced.Tax,
+			ProductName: priced.Product,
+			ProductCost: snapshot[priced.Product].Cost,
+			LineTotal:   priced.PosCost,
+			Discount:    priced.Discount,
 		}
 
-		// Fill order fields
-		order.CheckID = checkId
-		order.ReqAmount = reqAmount
-		order.ProductID = product.ID
-
 		// Insert position
-		err = creator.prodDB.InsertProductFromCheck(order)
-
-		if err != nil {
+		if err := tx.InsertProductFromCheck(position); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,189 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCreatorUpdate(t *testing.T) {
+	// Test checks Update function in Check Creator
+	type mockBehavior func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64)
+
+	testTable := []struct {
+		name          string
+		inputOrder    map[string]int64
+		inputCheck    ClientCheck
+		expectedID    int64
+		expectedError error
+		mockBehavior  mockBehavior
+	}{
+		{
+			name: "OK",
+			inputOrder: map[string]int64{
+				"apple": 10,
+			},
+			inputCheck: ClientCheck{
+				Subtotal: money.Money{Amount: 1800, Currency: "USD"},
+				Tax:      money.Money{Amount: 360, Currency: "USD"},
+				TotalSum: money.Money{Amount: 2160, Currency: "USD"},
+				Positions: []ProductPosition{{Product: "apple", PosCost: money.Money{Amount: 2000, Currency: "USD"}, ReqAmount: 10,
+					TaxCategory: "standard", TaxRate: 2000, Tax: money.Money{Amount: 360, Currency: "USD"}, Discount: money.Money{Amount: 200, Currency: "USD"}}},
+				IsConf: true,
+			},
+			expectedID:    7,
+			expectedError: nil,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64) {
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().ReserveProducts(order).Return(nil)
+				// Sums are written as client has seen them
+				tx.EXPECT().InsertCheck(gomock.Any()).DoAndReturn(func(purchCheck database.Check) (int64, error) {
+					assert.Equal(t, money.Money{Amount: 1800, Currency: "USD"}, purchCheck.Subtotal)
+					assert.Equal(t, money.Money{Amount: 360, Currency: "USD"}, purchCheck.Tax)
+					assert.Equal(t, money.Money{Amount: 2160, Currency: "USD"}, purchCheck.Total)
+					// Check belongs to buyer
+					assert.Equal(t, int64(3), purchCheck.CustomerID)
+
+					return int64(7), nil
+				})
+				// Position keeps product as it was at purchase and its discount
+				tx.EXPECT().InsertProductFromCheck(database.Order{CheckID: 7, ProductID: 1, ReqAmount: 10,
+					TaxCategory: "standard", TaxRate: 2000, Tax: money.Money{Amount: 360, Currency: "USD"},
+					ProductName: "apple", ProductCost: money.Money{Amount: 200, Currency: "USD"},
+					LineTotal: money.Money{Amount: 2000, Currency: "USD"}, Discount: money.Money{Amount: 200, Currency: "USD"}}).Return(nil)
+				tx.EXPECT().Commit().Return(nil)
+			},
+		},
+
+		{
+			name: "Not confirmed check",
+			inputOrder: map[string]int64{
+				"apple": 200,
+			},
+			inputCheck: ClientCheck{
+				IsConf: false,
+				Error:  []string{"product: apple, requested_amount: 200, actually amount: 50"},
+			},
+			expectedError: nil,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64) {
+				s.EXPECT()
+			},
+		},
+
+		{
+			name: "Stock was bought by someone else",
+			inputOrder: map[string]int64{
+				"apple": 10,
+			},
+			inputCheck: ClientCheck{
+				IsConf: true,
+			},
+			expectedError: database.ErrInsufficientStock,
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64) {
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().ReserveProducts(order).Return(database.ErrInsufficientStock)
+				tx.EXPECT().Rollback().Return(nil)
+			},
+		},
+
+		{
+			name: "Database Error",
+			inputOrder: map[string]int64{
+				"apple": 10,
+			},
+			inputCheck: ClientCheck{
+				IsConf: true,
+			},
+			expectedError: errors.New("db mock error"),
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64) {
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().ReserveProducts(order).Return(nil)
+				tx.EXPECT().InsertCheck(gomock.Any()).Return(int64(0), errors.New("db mock error"))
+				tx.EXPECT().Rollback().Return(nil)
+			},
+		},
+
+		{
+			name: "Position insert error rolls back check",
+			inputOrder: map[string]int64{
+				"apple": 10,
+				"melon": 1,
+			},
+			inputCheck: ClientCheck{
+				Positions: []ProductPosition{
+					{Product: "apple", ReqAmount: 10},
+					{Product: "melon", ReqAmount: 1},
+				},
+				IsConf: true,
+			},
+			expectedError: errors.New("db mock error"),
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64) {
+				s.EXPECT().Begin().Return(tx, nil)
+				tx.EXPECT().ReserveProducts(order).Return(nil)
+				tx.EXPECT().InsertCheck(gomock.Any()).Return(int64(7), nil)
+				tx.EXPECT().InsertProductFromCheck(gomock.Any()).Return(nil)
+				tx.EXPECT().InsertProductFromCheck(gomock.Any()).Return(errors.New("db mock error"))
+				tx.EXPECT().Rollback().Return(nil)
+			},
+		},
+
+		{
+			name: "Transaction can't be started",
+			inputOrder: map[string]int64{
+				"apple": 10,
+			},
+			inputCheck: ClientCheck{
+				IsConf: true,
+			},
+			expectedError: errors.New("db mock error"),
+			mockBehavior: func(s *mock_db.MockProductDB, tx *mock_db.MockProductTx, order map[string]int64) {
+				s.EXPECT().Begin().Return(nil, errors.New("db mock error"))
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+			tx := mock_db.NewMockProductTx(c)
+
+			// Define behavior
+			testCase.mockBehavior(prodDB, tx, testCase.inputOrder)
+
+			creator := GetCheckCreator("Test Creator Sub", prodDB, nil, nil)
+
+			// Catalogue's and Validator's parts
+			purchase := NewPurchase(Request{Order: testCase.inputOrder, CustomerID: 3})
+			publishCatalogue(purchase, []*database.Product{
+				{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50},
+				{ID: 2, Name: "melon", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 10},
+			})
+			purchase.Publish(VerdictArtifact, testCase.inputCheck)
+
+			// Appeal to Consumer interface, do some subscriber's work
+			err := creator.Update(context.TODO(), purchase)
+
+			// Check id is published only if check was written or not needed
+			checkID, published := purchase.Result(CheckIDArtifact)
+
+			if testCase.expectedError == nil {
+				assert.True(t, published)
+				assert.Equal(t, testCase.expectedID, checkID)
+			} else {
+				assert.False(t, published)
+			}
+
+			// Assert error
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
@@ -1,24 +1,70 @@
 package consumer
 
-import "context"
+import (
+	"context"
+	"sort"
+
+	"github.com/delonce/apishop/pkg/money"
+)
 
 //go:generate mockgen -source=consumer.go -destination=mocks/mock.go
 
 type Consumer interface {
-	GetName() string                                          // Return name of subscriber for handy debug and logging
-	Update(ctx context.Context, order map[string]int64) error // Do main work
+	GetName() string                                      // Return name of subscriber for handy debug and logging
+	Produces() []string                                   // Names of results that subscriber publishes in purchase
+	DependsOn() []string                                  // Names of results that have to be published before Update
+	Update(ctx context.Context, purchase *Purchase) error // Do main work with state of one purchase query
+}
+
+// Purchase query of client, everything that subscribers know about it
+type Request struct {
+	Order      map[string]int64 // Amount of every product
+	PromoCode  string           // Empty if client hasn't sent it
+	CustomerID int64            // Authenticated buyer, 0 for anonymous purchase
 }
 
 // FOR REPLY TO CLIENTS
 type ClientCheck struct {
-	TotalSum  int64             `json:"total_cost"`
+	CheckID   int64             `json:"check_id,omitempty"`
+	Subtotal  money.Money       `json:"subtotal"` // Without tax
+	Tax       money.Money       `json:"tax"`
+	TotalSum  money.Money       `json:"total_cost"`
 	Positions []ProductPosition `json:"positions"`
 	IsConf    bool              `json:"is_confirmed"`
 	Error     []string          `json:"error"`
+	// Discounts of the whole cart, they are taken after discounts of positions
+	Discounts []AppliedDiscount `json:"discounts,omitempty"`
 }
 
 type ProductPosition struct {
-	Product   string `json:"product"`
-	PosCost   int64  `json:"pos_cost"`
-	ReqAmount int64  `json:"req_amount"`
+	Product     string      `json:"product"`
+	PosCost     money.Money `json:"pos_cost"` // Before discounts
+	ReqAmount   int64       `json:"req_amount"`
+	TaxCategory string      `json:"tax_category"`
+	TaxRate     int64       `json:"tax_rate"` // Basis points, 2000 is 20%
+	Tax         money.Money `json:"tax"`      // Of cost after all discounts
+	// Discounts of position and its part of discounts of cart, PosCost - Discount is taxed
+	Discount  money.Money       `json:"discount"`
+	Discounts []AppliedDiscount `json:"discounts,omitempty"`
+}
+
+// Discount that has reduced cost of position or of cart
+type AppliedDiscount struct {
+	DiscountID  int64       `json:"discount_id"`
+	PromoCode   string      `json:"promo_code,omitempty"`
+	Description string      `json:"description"`
+	Amount      money.Money `json:"amount"`
+}
+
+func sortedNames(order map[string]int64) []string {
+	// Maps are iterated in random order, but positions and errors in reply should be stable
+	names := make([]string, 0, len(order))
+
+	for name := range order {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
 }
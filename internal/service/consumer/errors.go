@@ -0,0 +1,54 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// One problem of order, client gets all of them at once
+type Problem struct {
+	// Position in list 'order' of query, nil if problem is about the whole order
+	Index  *int   `json:"index,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Order that can't be processed at all, client has to change it
+// Not enough stock isn't validation error, such order gets rejected check
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Problems))
+
+	for _, problem := range e.Problems {
+		reasons = append(reasons, problem.Reason)
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+// Pricer checks it too, subjects can be notified not only by handlers
+var emptyOrderProblem = Problem{Field: "order", Reason: "your order is empty, try to add something in POST query"}
+
+func ValidateOrder(order map[string]int64) error {
+	// Checks what doesn't depend on database, so wrong order doesn't start subscribers
+	if len(order) == 0 {
+		return &ValidationError{Problems: []Problem{emptyOrderProblem}}
+	}
+
+	problems := []Problem{}
+
+	for _, name := range sortedNames(order) {
+		if order[name] < 0 {
+			problems = append(problems, Problem{Field: "amount", Reason: fmt.Sprintf("field 'amount' in product %s should be more than 0", name)})
+		}
+	}
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
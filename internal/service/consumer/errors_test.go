@@ -0,0 +1,52 @@
+package consumer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOrder(t *testing.T) {
+	testTable := []struct {
+		name             string
+		inputOrder       map[string]int64
+		expectedProblems []Problem
+	}{
+		{
+			name:             "OK",
+			inputOrder:       map[string]int64{"apple": 10, "melon": 0},
+			expectedProblems: nil,
+		},
+
+		{
+			name:             "Empty order",
+			inputOrder:       map[string]int64{},
+			expectedProblems: []Problem{{Field: "order", Reason: "your order is empty, try to add something in POST query"}},
+		},
+
+		{
+			name:       "All wrong amounts are reported",
+			inputOrder: map[string]int64{"melon": -1, "apple": -5, "kiwi": 1},
+			expectedProblems: []Problem{
+				{Field: "amount", Reason: "field 'amount' in product apple should be more than 0"},
+				{Field: "amount", Reason: "field 'amount' in product melon should be more than 0"},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := ValidateOrder(testCase.inputOrder)
+
+			if testCase.expectedProblems == nil {
+				assert.Nil(t, err)
+				return
+			}
+
+			var validationErr *ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, testCase.expectedProblems, validationErr.Problems)
+		})
+	}
+}
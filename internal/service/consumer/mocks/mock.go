@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	consumer "github.com/delonce/apishop/internal/service/consumer"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,6 +35,20 @@ func (m *MockConsumer) EXPECT() *MockConsumerMockRecorder {
 	return m.recorder
 }
 
+// DependsOn mocks base method.
+func (m *MockConsumer) DependsOn() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DependsOn")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// DependsOn indicates an expected call of DependsOn.
+func (mr *MockConsumerMockRecorder) DependsOn() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DependsOn", reflect.TypeOf((*MockConsumer)(nil).DependsOn))
+}
+
 // GetName mocks base method.
 func (m *MockConsumer) GetName() string {
 	m.ctrl.T.Helper()
@@ -48,16 +63,30 @@ func (mr *MockConsumerMockRecorder) GetName() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetName", reflect.TypeOf((*MockConsumer)(nil).GetName))
 }
 
+// Produces mocks base method.
+func (m *MockConsumer) Produces() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Produces")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Produces indicates an expected call of Produces.
+func (mr *MockConsumerMockRecorder) Produces() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Produces", reflect.TypeOf((*MockConsumer)(nil).Produces))
+}
+
 // Update mocks base method.
-func (m *MockConsumer) Update(ctx context.Context, order map[string]int64) error {
+func (m *MockConsumer) Update(ctx context.Context, purchase *consumer.Purchase) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", ctx, order)
+	ret := m.ctrl.Call(m, "Update", ctx, purchase)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockConsumerMockRecorder) Update(ctx, order interface{}) *gomock.Call {
+func (mr *MockConsumerMockRecorder) Update(ctx, purchase interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockConsumer)(nil).Update), ctx, order)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockConsumer)(nil).Update), ctx, purchase)
 }
@@ -0,0 +1,273 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/money"
+)
+
+// Counts cost of every position, applies discounts that work at the moment of purchase and counts taxes
+// Order that can't be priced (mixed currencies, too big cost, wrong promo code) is rejected here
+type PriceSubscriber struct {
+	name   string
+	prodDB database.ProductDB
+	taxes  TaxPolicy
+	logger *logging.Logger
+
+	// Time limits of discounts are checked by it, tests replace it
+	now func() time.Time
+}
+
+func GetPricer(name string, prodDB database.ProductDB, taxes TaxPolicy, logger *logging.Logger) Consumer {
+	return &PriceSubscriber{
+		name:   name,
+		prodDB: prodDB,
+		taxes:  taxes,
+		logger: logger,
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+func (pricer *PriceSubscriber) GetName() string {
+	return pricer.name
+}
+
+func (pricer *PriceSubscriber) Produces() []string {
+	return []string{PricesArtifact}
+}
+
+func (pricer *PriceSubscriber) DependsOn() []string {
+	return []string{CatalogueArtifact}
+}
+
+func (pricer *PriceSubscriber) Update(ctx context.Context, purchase *Purchase) error {
+	if pricer.logger != nil {
+		pricer.logger.Trace("Starting Pricer...")
+	}
+
+	discounts, err := pricer.prodDB.SelectActiveDiscounts(purchase.PromoCode, pricer.now())
+
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := purchase.Catalogue(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	prices, problems := priceOrder(purchase.Request, snapshot, discounts)
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	if err = pricer.taxes.apply(&prices, snapshot); err != nil {
+		return err
+	}
+
+	purchase.Publish(PricesArtifact, prices)
+
+	return nil
+}
+
+func priceOrder(request Request, snapshot map[string]database.Product, discounts []database.Discount) (ClientCheck, []Problem) {
+	positions, totalSum, problems := pricePositions(request.Order, snapshot)
+
+	if len(problems) != 0 {
+		return ClientCheck{}, problems
+	}
+
+	// Discounts are read by promo code, so there is no discount of unknown code
+	if request.PromoCode != "" && !hasPromoCode(discounts, request.PromoCode) {
+		return ClientCheck{}, []Problem{{Field: "promo_code",
+			Reason: fmt.Sprintf("promo code %s doesn't exist or has expired", request.PromoCode)}}
+	}
+
+	prices := ClientCheck{Positions: positions}
+	// Total is counted again from costs after discounts
+	totalSum = money.Money{Currency: totalSum.Currency}
+
+	// Discounts of one product are taken one by one from what is left after previous ones
+	for i := range prices.Positions {
+		position := &prices.Positions[i]
+		cost := position.PosCost
+
+		for _, discount := range discounts {
+			if discount.Product != position.Product {
+				continue
+			}
+
+			off := discountOf(discount, cost, snapshot[position.Product].Cost, position.ReqAmount)
+
+			if off.Amount > 0 {
+				position.Discounts = append(position.Discounts, applyDiscount(discount, off))
+				// Discount isn't bigger than cost, so it can't fail
+				cost, _ = cost.Sub(off)
+			}
+		}
+
+		// Sum of costs before discounts fits, so sum of smaller ones fits too
+		totalSum, _ = totalSum.Add(cost)
+	}
+
+	// Then discounts of the whole cart
+	for _, discount := range discounts {
+		if discount.Product != "" {
+			continue
+		}
+
+		off := discountOf(discount, totalSum, money.Money{}, 0)
+
+		if off.Amount > 0 {
+			prices.Discounts = append(prices.Discounts, applyDiscount(discount, off))
+			totalSum, _ = totalSum.Sub(off)
+		}
+	}
+
+	prices.TotalSum = totalSum
+
+	// Code that exists, but is for other products (or other currency), is a mistake of client too
+	if request.PromoCode != "" && !isPromoCodeApplied(prices, request.PromoCode) {
+		return ClientCheck{}, []Problem{{Field: "promo_code",
+			Reason: fmt.Sprintf("promo code %s can't be applied to this order", request.PromoCode)}}
+	}
+
+	return prices, nil
+}
+
+func pricePositions(order map[string]int64, snapshot map[string]database.Product) ([]ProductPosition, money.Money, []Problem) {
+	// Сount all positions, the whole order is paid in currency of its first product
+	names := sortedNames(order)
+
+	// Without positions there is no currency of order
+	if len(names) == 0 {
+		return nil, money.Money{}, []Problem{emptyOrderProblem}
+	}
+
+	positions := []ProductPosition{}
+	problems := []Problem{}
+	totalSum := money.Money{Currency: snapshot[names[0]].Cost.Currency}
+
+	for _, name := range names {
+		reqAmount := order[name]
+		product := snapshot[name]
+
+		if product.Cost.Currency != totalSum.Currency {
+			problems = append(problems, Problem{Field: "product", Reason: fmt.Sprintf(
+				"product %s is sold in %s, but order is in %s, buy it separately", name, product.Cost.Currency, totalSum.Currency)})
+			continue
+		}
+
+		posCost, err := product.Cost.Mul(reqAmount)
+
+		if err != nil {
+			problems = append(problems, Problem{Field: "amount", Reason: fmt.Sprintf("cost of product %s is too big, try to buy less", name)})
+			continue
+		}
+
+		// Add position in list
+		positions = append(positions, ProductPosition{
+			Product:   product.Name,
+			PosCost:   posCost,
+			ReqAmount: reqAmount,
+		})
+
+		// Find part
+		totalSum, err = totalSum.Add(posCost)
+
+		if err != nil {
+			return nil, money.Money{}, append(problems, Problem{Field: "order", Reason: "total cost of order is too big, try to buy less"})
+		}
+	}
+
+	return positions, totalSum, problems
+}
+
+func discountOf(discount database.Discount, cost, unitCost money.Money, reqAmount int64) money.Money {
+	// Part of cost that discount takes, it is never bigger than cost
+	off := money.Money{Currency: cost.Currency}
+
+	switch discount.Kind {
+	case database.DiscountPercent:
+		off = cost.Percent(discount.Percent)
+	case database.DiscountFixed:
+		// Fixed discount in another currency doesn't work
+		if discount.Fixed.Currency == cost.Currency {
+			off = discount.Fixed
+		}
+	case database.DiscountBuyXGetY:
+		free := reqAmount / (discount.Buy + discount.Free) * discount.Free
+
+		if unitCost.Currency == cost.Currency {
+			freeCost, err := unitCost.Mul(free)
+
+			// Free units can't cost more than position, so overflow means the whole position
+			if err != nil {
+				freeCost = cost
+			}
+
+			off = freeCost
+		}
+	}
+
+	if off.Amount > cost.Amount {
+		off = cost
+	}
+
+	return off
+}
+
+func applyDiscount(discount database.Discount, off money.Money) AppliedDiscount {
+	applied := AppliedDiscount{
+		DiscountID: discount.ID,
+		PromoCode:  discount.PromoCode,
+		Amount:     off,
+	}
+
+	switch discount.Kind {
+	case database.DiscountPercent:
+		applied.Description = fmt.Sprintf("%d%% off", discount.Percent)
+	case database.DiscountFixed:
+		applied.Description = fmt.Sprintf("%s off", discount.Fixed)
+	case database.DiscountBuyXGetY:
+		applied.Description = fmt.Sprintf("buy %d get %d free", discount.Buy, discount.Free)
+	}
+
+	return applied
+}
+
+func hasPromoCode(discounts []database.Discount, promoCode string) bool {
+	for _, discount := range discounts {
+		if discount.PromoCode == promoCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPromoCodeApplied(prices ClientCheck, promoCode string) bool {
+	for _, discount := range prices.Discounts {
+		if discount.PromoCode == promoCode {
+			return true
+		}
+	}
+
+	for _, position := range prices.Positions {
+		for _, discount := range position.Discounts {
+			if discount.PromoCode == promoCode {
+				return true
+			}
+		}
+	}
+
+	return false
+}
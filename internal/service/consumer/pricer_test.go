@@ -0,0 +1,255 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func untaxed(prices ClientCheck) ClientCheck {
+	// Prices of order without tax, all products have standard category with zero rate
+	// Positions without discounts have zero discount
+	prices.Subtotal = prices.TotalSum
+	prices.Tax = money.Money{Currency: prices.TotalSum.Currency}
+
+	for i := range prices.Positions {
+		prices.Positions[i].TaxCategory = "standard"
+		prices.Positions[i].Tax = money.Money{Currency: prices.TotalSum.Currency}
+
+		if prices.Positions[i].Discount.Currency == "" {
+			prices.Positions[i].Discount = money.Money{Currency: prices.TotalSum.Currency}
+		}
+	}
+
+	return prices
+}
+
+func TestPricerUpdate(t *testing.T) {
+	// Test checks that positions are priced and discounts are applied in order
+	type mockBehavior func(s *mock_db.MockProductDB, promoCode string, at time.Time)
+
+	at := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: amount, Currency: "USD"}
+	}
+
+	apple := &database.Product{ID: 1, Name: "apple", Cost: usd(200), Amount: 50, TaxCategory: "standard"}
+	melon := &database.Product{ID: 2, Name: "melon", Cost: usd(300), Amount: 10, TaxCategory: "standard"}
+
+	appleSale := database.Discount{ID: 1, Kind: database.DiscountPercent, Product: "apple", Percent: 10}
+	cartSale := database.Discount{ID: 2, PromoCode: "SALE", Kind: database.DiscountFixed, Fixed: usd(100)}
+	melonSale := database.Discount{ID: 3, PromoCode: "MELON", Kind: database.DiscountBuyXGetY, Product: "melon", Buy: 2, Free: 1}
+
+	testTable := []struct {
+		name           string
+		request        Request
+		product        []*database.Product
+		mockBehavior   mockBehavior
+		expectedPrices ClientCheck
+		expectedError  error
+	}{
+		{
+			name:    "Without discounts",
+			request: Request{Order: map[string]int64{"apple": 10}},
+			product: []*database.Product{apple},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{}, nil)
+			},
+			expectedPrices: untaxed(ClientCheck{
+				TotalSum:  usd(2000),
+				Positions: []ProductPosition{{Product: "apple", PosCost: usd(2000), ReqAmount: 10, TaxCategory: "standard"}},
+			}),
+		},
+
+		{
+			name:    "Percent of product",
+			request: Request{Order: map[string]int64{"apple": 10}},
+			product: []*database.Product{apple},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{appleSale}, nil)
+			},
+			expectedPrices: untaxed(ClientCheck{
+				TotalSum: usd(1800),
+				Positions: []ProductPosition{{Product: "apple", PosCost: usd(2000), ReqAmount: 10, Discount: usd(200), Discounts: []AppliedDiscount{
+					{DiscountID: 1, Description: "10% off", Amount: usd(200)},
+				}}},
+			}),
+		},
+
+		{
+			name:    "Promo code for cart",
+			request: Request{Order: map[string]int64{"apple": 10}, PromoCode: "SALE"},
+			product: []*database.Product{apple},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{cartSale}, nil)
+			},
+			expectedPrices: untaxed(ClientCheck{
+				TotalSum:  usd(1900),
+				Positions: []ProductPosition{{Product: "apple", PosCost: usd(2000), ReqAmount: 10, TaxCategory: "standard", Discount: usd(100)}},
+				Discounts: []AppliedDiscount{{DiscountID: 2, PromoCode: "SALE", Description: "1.00 USD off", Amount: usd(100)}},
+			}),
+		},
+
+		{
+			name:    "Buy two get one free",
+			request: Request{Order: map[string]int64{"melon": 7}, PromoCode: "MELON"},
+			product: []*database.Product{melon},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{melonSale}, nil)
+			},
+			expectedPrices: untaxed(ClientCheck{
+				TotalSum: usd(1500),
+				Positions: []ProductPosition{{Product: "melon", PosCost: usd(2100), ReqAmount: 7, Discount: usd(600), Discounts: []AppliedDiscount{
+					{DiscountID: 3, PromoCode: "MELON", Description: "buy 2 get 1 free", Amount: usd(600)},
+				}}},
+			}),
+		},
+
+		{
+			name:    "Discounts are stacked",
+			request: Request{Order: map[string]int64{"apple": 10, "melon": 1}, PromoCode: "SALE"},
+			product: []*database.Product{apple, melon},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{appleSale, cartSale}, nil)
+			},
+			expectedPrices: untaxed(ClientCheck{
+				TotalSum: usd(2000),
+				Positions: []ProductPosition{
+					// Discount of cart is divided in proportion to costs after discounts of positions, 1800 and 300
+					{Product: "apple", PosCost: usd(2000), ReqAmount: 10, Discount: usd(286), Discounts: []AppliedDiscount{
+						{DiscountID: 1, Description: "10% off", Amount: usd(200)},
+					}},
+					{Product: "melon", PosCost: usd(300), ReqAmount: 1, Discount: usd(14)},
+				},
+				Discounts: []AppliedDiscount{{DiscountID: 2, PromoCode: "SALE", Description: "1.00 USD off", Amount: usd(100)}},
+			}),
+		},
+
+		{
+			name:    "Unknown promo code",
+			request: Request{Order: map[string]int64{"apple": 10}, PromoCode: "NOPE"},
+			product: []*database.Product{apple},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{appleSale}, nil)
+			},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "promo_code", Reason: "promo code NOPE doesn't exist or has expired"},
+			}},
+		},
+
+		{
+			name:    "Promo code for another product",
+			request: Request{Order: map[string]int64{"apple": 10}, PromoCode: "MELON"},
+			product: []*database.Product{apple},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{melonSale}, nil)
+			},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "promo_code", Reason: "promo code MELON can't be applied to this order"},
+			}},
+		},
+
+		{
+			name:    "Different currencies",
+			request: Request{Order: map[string]int64{"apple": 1, "melon": 1}},
+			product: []*database.Product{
+				apple,
+				{ID: 2, Name: "melon", Cost: money.Money{Amount: 200, Currency: "EUR"}, Amount: 10, TaxCategory: "standard"},
+			},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{}, nil)
+			},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "product", Reason: "product melon is sold in EUR, but order is in USD, buy it separately"},
+			}},
+		},
+
+		{
+			name:    "Cost overflows",
+			request: Request{Order: map[string]int64{"apple": 3, "melon": 1}},
+			product: []*database.Product{
+				{ID: 1, Name: "apple", Cost: usd(math.MaxInt64 / 2), Amount: 50, TaxCategory: "standard"},
+				{ID: 2, Name: "melon", Cost: usd(math.MaxInt64), Amount: 10, TaxCategory: "standard"},
+			},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{}, nil)
+			},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "amount", Reason: "cost of product apple is too big, try to buy less"},
+			}},
+		},
+
+		{
+			name:    "Total overflows",
+			request: Request{Order: map[string]int64{"apple": 1, "melon": 1}},
+			product: []*database.Product{
+				{ID: 1, Name: "apple", Cost: usd(math.MaxInt64), Amount: 50, TaxCategory: "standard"},
+				{ID: 2, Name: "melon", Cost: usd(1), Amount: 10, TaxCategory: "standard"},
+			},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{}, nil)
+			},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "order", Reason: "total cost of order is too big, try to buy less"},
+			}},
+		},
+
+		{
+			// Order isn't checked by handler if subject is notified directly
+			name:    "Empty order",
+			request: Request{Order: map[string]int64{}},
+			product: []*database.Product{},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return([]database.Discount{}, nil)
+			},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "order", Reason: "your order is empty, try to add something in POST query"},
+			}},
+		},
+
+		{
+			name:    "Storage error",
+			request: Request{Order: map[string]int64{"apple": 10}},
+			product: []*database.Product{apple},
+			mockBehavior: func(s *mock_db.MockProductDB, promoCode string, at time.Time) {
+				s.EXPECT().SelectActiveDiscounts(promoCode, at).Return(nil, errors.New("connection refused"))
+			},
+			expectedError: errors.New("connection refused"),
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			prodDB := mock_db.NewMockProductDB(c)
+			testCase.mockBehavior(prodDB, testCase.request.PromoCode, at)
+
+			pricer := GetPricer("Test Pricer", prodDB, TaxPolicy{Rates: map[string]int64{"standard": 0}}, nil)
+			pricer.(*PriceSubscriber).now = func() time.Time { return at }
+
+			// Catalogue's part
+			purchase := NewPurchase(testCase.request)
+			publishCatalogue(purchase, testCase.product)
+
+			err := pricer.Update(context.TODO(), purchase)
+
+			// Prices aren't published if error happened
+			published, _ := purchase.Result(PricesArtifact)
+			result, _ := published.(ClientCheck)
+
+			assert.Equal(t, testCase.expectedPrices, result)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/delonce/apishop/internal/database"
+)
+
+// Names of results that subscribers publish for each other
+const (
+	CatalogueArtifact = "catalogue" // map[string]database.Product, all products of order read once, made by Catalogue
+	PricesArtifact    = "prices"    // ClientCheck with positions, discounts and total, made by Pricer
+	VerdictArtifact   = "verdict"   // Prices with IsConf and Error, made by Validator
+	CheckIDArtifact   = "check_id"  // int64, id of written check (0 if it isn't written), made by Check Creator
+	ReplyArtifact     = "reply"     // []byte, json for client, made by Replier
+)
+
+// State of one purchase query
+// Every Notify creates its own Purchase, so subscribers never see results of another query
+type Purchase struct {
+	Request
+
+	mu        sync.Mutex
+	results   map[string]interface{}
+	published map[string]chan struct{}
+}
+
+func NewPurchase(request Request) *Purchase {
+	return &Purchase{
+		Request:   request,
+		results:   make(map[string]interface{}),
+		published: make(map[string]chan struct{}),
+	}
+}
+
+func (purchase *Purchase) Publish(artifact string, value interface{}) {
+	// Saves result and wakes up everyone who waits for it
+	// Result can be published only once, next values are ignored
+	purchase.mu.Lock()
+	defer purchase.mu.Unlock()
+
+	if _, ok := purchase.results[artifact]; ok {
+		return
+	}
+
+	purchase.results[artifact] = value
+	close(purchase.signal(artifact))
+}
+
+func (purchase *Purchase) Await(ctx context.Context, artifact string) (interface{}, error) {
+	// Blocks until result is published or context is done (e.g. another subscriber has failed)
+	purchase.mu.Lock()
+	published := purchase.signal(artifact)
+	purchase.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-published:
+		value, _ := purchase.Result(artifact)
+		return value, nil
+	}
+}
+
+func (purchase *Purchase) Result(artifact string) (interface{}, bool) {
+	// Doesn't wait, false means that result isn't published yet
+	purchase.mu.Lock()
+	defer purchase.mu.Unlock()
+
+	value, ok := purchase.results[artifact]
+
+	return value, ok
+}
+
+func (purchase *Purchase) Catalogue(ctx context.Context) (map[string]database.Product, error) {
+	value, err := purchase.Await(ctx, CatalogueArtifact)
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, ok := value.(map[string]database.Product)
+
+	if !ok {
+		return nil, fmt.Errorf("artifact %s has type %T", CatalogueArtifact, value)
+	}
+
+	return snapshot, nil
+}
+
+func (purchase *Purchase) Prices(ctx context.Context) (ClientCheck, error) {
+	value, err := purchase.Await(ctx, PricesArtifact)
+
+	if err != nil {
+		return ClientCheck{}, err
+	}
+
+	prices, ok := value.(ClientCheck)
+
+	if !ok {
+		return ClientCheck{}, fmt.Errorf("artifact %s has type %T", PricesArtifact, value)
+	}
+
+	return prices, nil
+}
+
+func (purchase *Purchase) Verdict(ctx context.Context) (ClientCheck, error) {
+	value, err := purchase.Await(ctx, VerdictArtifact)
+
+	if err != nil {
+		return ClientCheck{}, err
+	}
+
+	verdict, ok := value.(ClientCheck)
+
+	if !ok {
+		return ClientCheck{}, fmt.Errorf("artifact %s has type %T", VerdictArtifact, value)
+	}
+
+	return verdict, nil
+}
+
+func (purchase *Purchase) CheckID(ctx context.Context) (int64, error) {
+	value, err := purchase.Await(ctx, CheckIDArtifact)
+
+	if err != nil {
+		return 0, err
+	}
+
+	checkID, ok := value.(int64)
+
+	if !ok {
+		return 0, fmt.Errorf("artifact %s has type %T", CheckIDArtifact, value)
+	}
+
+	return checkID, nil
+}
+
+func (purchase *Purchase) Reply() ([]byte, bool) {
+	// Reply is read by subject after all subscribers are done, so it doesn't wait
+	value, ok := purchase.Result(ReplyArtifact)
+
+	if !ok {
+		return nil, false
+	}
+
+	reply, ok := value.([]byte)
+
+	return reply, ok
+}
+
+func (purchase *Purchase) signal(artifact string) chan struct{} {
+	// Channel is closed when artifact is published, mutex should be locked by caller
+	published, ok := purchase.published[artifact]
+
+	if !ok {
+		published = make(chan struct{})
+		purchase.published[artifact] = published
+	}
+
+	return published
+}
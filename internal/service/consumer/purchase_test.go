@@ -0,0 +1,80 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurchaseAwait(t *testing.T) {
+	// Test checks that subscribers get published results and don't hang if query is cancelled
+	testTable := []struct {
+		name          string
+		publish       bool
+		cancel        bool
+		expectedValue interface{}
+		expectedError error
+	}{
+		{
+			name:          "Published later",
+			publish:       true,
+			expectedValue: int64(7),
+			expectedError: nil,
+		},
+
+		{
+			name:          "Cancelled query",
+			cancel:        true,
+			expectedValue: nil,
+			expectedError: context.Canceled,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			purchase := NewPurchase(Request{Order: map[string]int64{"apple": 10}})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go func(publish, cancelQuery bool) {
+				// Another subscriber works some time
+				time.Sleep(10 * time.Millisecond)
+
+				if publish {
+					purchase.Publish(CheckIDArtifact, int64(7))
+				}
+
+				if cancelQuery {
+					cancel()
+				}
+			}(testCase.publish, testCase.cancel)
+
+			value, err := purchase.Await(ctx, CheckIDArtifact)
+
+			assert.Equal(t, testCase.expectedValue, value)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestPurchasePublishOnce(t *testing.T) {
+	// Test checks that the first published result can't be replaced
+	purchase := NewPurchase(Request{Order: map[string]int64{"apple": 10}})
+
+	_, ok := purchase.Reply()
+	assert.False(t, ok)
+
+	purchase.Publish(ReplyArtifact, []byte("first"))
+	purchase.Publish(ReplyArtifact, []byte("second"))
+
+	reply, ok := purchase.Reply()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("first"), reply)
+
+	// Typed getter doesn't accept result of wrong type
+	purchase.Publish(VerdictArtifact, "not a check")
+	_, err := purchase.Verdict(context.Background())
+	assert.EqualError(t, err, "artifact verdict has type string")
+}
@@ -0,0 +1,89 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/delonce/apishop/pkg/logging"
+)
+
+// FOR REPLY TO QUOTE QUERIES
+// The same as check of purchase, but nothing is written and stock isn't reserved
+type QuoteReply struct {
+	ClientCheck
+	Availability []LineAvailability `json:"availability"`
+}
+
+type LineAvailability struct {
+	Product   string `json:"product"`
+	ReqAmount int64  `json:"req_amount"`
+	Available int64  `json:"available"`
+	InStock   bool   `json:"in_stock"`
+}
+
+// Replier of quote subject, it doesn't wait for Check Creator
+type QuoteSubscriber struct {
+	name   string
+	logger *logging.Logger
+}
+
+func GetQuoter(name string, logger *logging.Logger) Consumer {
+	return &QuoteSubscriber{
+		name:   name,
+		logger: logger,
+	}
+}
+
+func (quoter *QuoteSubscriber) GetName() string {
+	return quoter.name
+}
+
+func (quoter *QuoteSubscriber) Produces() []string {
+	return []string{ReplyArtifact}
+}
+
+func (quoter *QuoteSubscriber) DependsOn() []string {
+	return []string{CatalogueArtifact, VerdictArtifact}
+}
+
+func (quoter *QuoteSubscriber) Update(ctx context.Context, purchase *Purchase) error {
+	verdict, err := purchase.Verdict(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if quoter.logger != nil {
+		quoter.logger.Trace("Starting Quoter...")
+	}
+
+	snapshot, err := purchase.Catalogue(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	reply := QuoteReply{
+		ClientCheck:  verdict,
+		Availability: []LineAvailability{},
+	}
+
+	for _, name := range sortedNames(purchase.Order) {
+		reply.Availability = append(reply.Availability, LineAvailability{
+			Product:   name,
+			ReqAmount: purchase.Order[name],
+			Available: snapshot[name].Amount,
+			InStock:   snapshot[name].Amount >= purchase.Order[name],
+		})
+	}
+
+	rawBytes, err := json.Marshal(reply)
+
+	if err != nil {
+		return err
+	}
+
+	purchase.Publish(ReplyArtifact, rawBytes)
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoterUpdate(t *testing.T) {
+	// Test checks Update function in Quoter
+	testTable := []struct {
+		name          string
+		inputOrder    map[string]int64
+		product       []*database.Product
+		inputCheck    ClientCheck
+		expectedJson  []byte
+		expectedError error
+	}{
+		{
+			name: "Everything in stock",
+			inputOrder: map[string]int64{
+				"apple": 10,
+				"melon": 1,
+			},
+			product: []*database.Product{
+				{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50},
+				{ID: 2, Name: "melon", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 1},
+			},
+			inputCheck: ClientCheck{
+				Subtotal: money.Money{Amount: 2200, Currency: "USD"},
+				Tax:      money.Money{Currency: "USD"},
+				TotalSum: money.Money{Amount: 2200, Currency: "USD"},
+				Positions: []ProductPosition{
+					{Product: "apple", PosCost: money.Money{Amount: 2000, Currency: "USD"}, ReqAmount: 10, TaxCategory: "standard", Tax: money.Money{Currency: "USD"}, Discount: money.Money{Currency: "USD"}},
+					{Product: "melon", PosCost: money.Money{Amount: 200, Currency: "USD"}, ReqAmount: 1, TaxCategory: "standard", Tax: money.Money{Currency: "USD"}, Discount: money.Money{Currency: "USD"}},
+				},
+				IsConf: true,
+				Error:  []string{},
+			},
+			expectedJson:  []byte(`{"subtotal":{"amount":2200,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":2200,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":2000,"currency":"USD"},"req_amount":10,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"melon","pos_cost":{"amount":200,"currency":"USD"},"req_amount":1,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}],"is_confirmed":true,"error":[],"availability":[{"product":"apple","req_amount":10,"available":50,"in_stock":true},{"product":"melon","req_amount":1,"available":1,"in_stock":true}]}`),
+			expectedError: nil,
+		},
+
+		{
+			name: "Not enough melons",
+			inputOrder: map[string]int64{
+				"apple": 10,
+				"melon": 3,
+			},
+			product: []*database.Product{
+				{ID: 1, Name: "apple", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 50},
+				{ID: 2, Name: "melon", Cost: money.Money{Amount: 200, Currency: "USD"}, Amount: 1},
+			},
+			inputCheck: ClientCheck{
+				Subtotal: money.Money{Amount: 2600, Currency: "USD"},
+				Tax:      money.Money{Currency: "USD"},
+				TotalSum: money.Money{Amount: 2600, Currency: "USD"},
+				Positions: []ProductPosition{
+					{Product: "apple", PosCost: money.Money{Amount: 2000, Currency: "USD"}, ReqAmount: 10, TaxCategory: "standard", Tax: money.Money{Currency: "USD"}, Discount: money.Money{Currency: "USD"}},
+					{Product: "melon", PosCost: money.Money{Amount: 600, Currency: "USD"}, ReqAmount: 3, TaxCategory: "standard", Tax: money.Money{Currency: "USD"}, Discount: money.Money{Currency: "USD"}},
+				},
+				IsConf: false,
+				Error:  []string{"product: melon, requested_amount: 3, actually amount: 1"},
+			},
+			expectedJson:  []byte(`{"subtotal":{"amount":2600,"currency":"USD"},"tax":{"amount":0,"currency":"USD"},"total_cost":{"amount":2600,"currency":"USD"},"positions":[{"product":"apple","pos_cost":{"amount":2000,"currency":"USD"},"req_amount":10,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}},{"product":"melon","pos_cost":{"amount":600,"currency":"USD"},"req_amount":3,"tax_category":"standard","tax_rate":0,"tax":{"amount":0,"currency":"USD"},"discount":{"amount":0,"currency":"USD"}}],"is_confirmed":false,"error":["product: melon, requested_amount: 3, actually amount: 1"],"availability":[{"product":"apple","req_amount":10,"available":50,"in_stock":true},{"product":"melon","req_amount":3,"available":1,"in_stock":false}]}`),
+			expectedError: nil,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			quoter := GetQuoter("Test Quote Sub", nil)
+
+			// Catalogue's and Validator's parts, there is no Check Creator
+			purchase := NewPurchase(Request{Order: testCase.inputOrder})
+			publishCatalogue(purchase, testCase.product)
+			purchase.Publish(VerdictArtifact, testCase.inputCheck)
+
+			err := quoter.Update(context.TODO(), purchase)
+
+			resJson, _ := purchase.Reply()
+
+			assert.Equal(t, testCase.expectedJson, resJson)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
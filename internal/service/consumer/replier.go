@@ -4,27 +4,19 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/delonce/apishop/internal/database"
 	"github.com/delonce/apishop/pkg/logging"
 )
 
 // Gets json reply to client with his check
 type ReplySubscriber struct {
-	name      string
-	prodDB    database.ProductDB
-	logger    *logging.Logger
-	reply     chan ClientCheck
-	jsonReply chan []byte
+	name   string
+	logger *logging.Logger
 }
 
-func GetReplier(name string, prodDB database.ProductDB,
-	logger *logging.Logger, clientForm chan ClientCheck, jsChan chan []byte) Consumer {
+func GetReplier(name string, logger *logging.Logger) Consumer {
 	return &ReplySubscriber{
-		name:      name,
-		prodDB:    prodDB,
-		logger:    logger,
-		reply:     clientForm,
-		jsonReply: jsChan,
+		name:   name,
+		logger: logger,
 	}
 }
 
@@ -32,47 +24,40 @@ func (rep *ReplySubscriber) GetName() string {
 	return rep.name
 }
 
-func (rep *ReplySubscriber) Update(ctx context.Context, order map[string]int64) error {
-	select {
-	case <-ctx.Done():
-		// Handle Cancelation
-		return ctx.Err()
-	case repForm := <-rep.reply:
-		if rep.logger != nil {
-			rep.logger.Trace("Starting Replier...")
-		}
-
-		// Сount all positions
-		for name, reqAmount := range order {
-			// Get some product from database
-			product, err := rep.prodDB.SelectProductByName(name)
-
-			// If error happened writes empty structure in channel
-			if err != nil {
-				return err
-			}
-
-			posCost := product.Cost * reqAmount
-
-			// Add position in list
-			repForm.Positions = append(repForm.Positions, ProductPosition{
-				Product:   product.Name,
-				PosCost:   posCost,
-				ReqAmount: reqAmount,
-			})
-
-			// Find part
-			repForm.TotalSum = repForm.TotalSum + posCost
-
-		}
-		// Create json bytes for reply
-		return rep.makeJsonReply(repForm)
+func (rep *ReplySubscriber) Produces() []string {
+	return []string{ReplyArtifact}
+}
+
+func (rep *ReplySubscriber) DependsOn() []string {
+	return []string{VerdictArtifact, CheckIDArtifact}
+}
+
+func (rep *ReplySubscriber) Update(ctx context.Context, purchase *Purchase) error {
+	// Waiting for check from order (Validator), it is already priced
+	repForm, err := purchase.Verdict(ctx)
+
+	if err != nil {
+		return err
 	}
+
+	if rep.logger != nil {
+		rep.logger.Trace("Starting Replier...")
+	}
+
+	// Confirmed check gets its id only after Check Creator has written it
+	repForm.CheckID, err = purchase.CheckID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// Create json bytes for reply
+	return rep.makeJsonReply(purchase, repForm)
 }
 
-func (rep *ReplySubscriber) makeJsonReply(check ClientCheck) error {
+func (rep *ReplySubscriber) makeJsonReply(purchase *Purchase, check ClientCheck) error {
 	// Makes json reply for client, structure ClientCheck
-	// Send json in []byte channel to subject
+	// Subject takes it from purchase after all subscribers are done
 	rawBytes, err := json.Marshal(check)
 
 	if err != nil {
@@ -80,8 +65,7 @@ func (rep *ReplySubscriber) makeJsonReply(check ClientCheck) error {
 		return err
 	}
 
-	// Writes in channel
-	rep.jsonReply <- rawBytes
+	purchase.Publish(ReplyArtifact, rawBytes)
 
 	return nil
 }
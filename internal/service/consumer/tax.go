@@ -0,0 +1,142 @@
+package consumer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/money"
+)
+
+// Rates are kept in basis points, so 5.5% is exact
+const fullRate = 10000
+
+// How taxes of order are counted
+type TaxPolicy struct {
+	Rates     map[string]int64 // Rate of every tax category in basis points, 2000 is 20%
+	Inclusive bool             // Costs of products already include tax, otherwise tax is added to them
+}
+
+func ParseTaxRates(raw string) (map[string]int64, error) {
+	// Rates are percents with at most two decimals: "standard=20,reduced=5.5,zero=0"
+	rates := map[string]int64{}
+
+	for _, pair := range strings.Split(raw, ",") {
+		category, percent, found := strings.Cut(strings.TrimSpace(pair), "=")
+
+		if !found || !database.TaxCategoryName.MatchString(category) {
+			return nil, fmt.Errorf("tax rate should look like category=percent, got %q", pair)
+		}
+
+		value, err := strconv.ParseFloat(percent, 64)
+
+		if err != nil || value < 0 || value > 100 || math.Round(value*100) != value*100 {
+			return nil, fmt.Errorf("rate of tax category %s should be percent from 0 to 100 with at most two decimals, got %q",
+				category, percent)
+		}
+
+		rates[category] = int64(math.Round(value * 100))
+	}
+
+	// Every product without category has default one
+	if _, ok := rates[database.DefaultTaxCategory]; !ok {
+		return nil, fmt.Errorf("rate of tax category %s is required", database.DefaultTaxCategory)
+	}
+
+	return rates, nil
+}
+
+func (policy TaxPolicy) apply(prices *ClientCheck, snapshot map[string]database.Product) error {
+	// Tax of every position is counted from its cost after all discounts
+	// Discounts of cart are divided between positions in proportion to their costs
+	bases := taxBases(*prices)
+	tax := money.Money{Currency: prices.TotalSum.Currency}
+
+	for i := range prices.Positions {
+		position := &prices.Positions[i]
+		category := snapshot[position.Product].TaxCategory
+		rate, ok := policy.Rates[category]
+
+		// Category of product isn't in config, client can't fix it
+		if !ok {
+			return fmt.Errorf("rate of tax category %s of product %s isn't configured", category, position.Product)
+		}
+
+		position.TaxCategory = category
+		position.TaxRate = rate
+		// Base isn't bigger than cost
+		position.Discount, _ = position.PosCost.Sub(bases[i])
+
+		// Rate is not more than 100%, so tax is not more than base and can't overflow
+		if policy.Inclusive {
+			net, _ := bases[i].Share(fullRate, fullRate+rate)
+			position.Tax, _ = bases[i].Sub(net)
+		} else {
+			position.Tax, _ = bases[i].Share(rate, fullRate)
+		}
+
+		// Sum of taxes is not more than total, which fits
+		tax, _ = tax.Add(position.Tax)
+	}
+
+	prices.Tax = tax
+
+	if policy.Inclusive {
+		prices.Subtotal, _ = prices.TotalSum.Sub(tax)
+		return nil
+	}
+
+	prices.Subtotal = prices.TotalSum
+	total, err := prices.TotalSum.Add(tax)
+
+	if err != nil {
+		return &ValidationError{Problems: []Problem{{Field: "order", Reason: "total cost of order with tax is too big, try to buy less"}}}
+	}
+
+	prices.TotalSum = total
+
+	return nil
+}
+
+func taxBases(prices ClientCheck) []money.Money {
+	// Costs of positions after their own discounts and their parts of discounts of cart
+	bases := make([]money.Money, len(prices.Positions))
+	costs := money.Money{Currency: prices.TotalSum.Currency}
+	cartOff := money.Money{Currency: prices.TotalSum.Currency}
+
+	for i, position := range prices.Positions {
+		bases[i] = position.PosCost
+
+		for _, discount := range position.Discounts {
+			bases[i], _ = bases[i].Sub(discount.Amount)
+		}
+
+		costs, _ = costs.Add(bases[i])
+	}
+
+	for _, discount := range prices.Discounts {
+		cartOff, _ = cartOff.Add(discount.Amount)
+	}
+
+	if cartOff.Amount == 0 {
+		return bases
+	}
+
+	// Parts are counted from cumulative sums, so rounding doesn't lose or add a cent
+	// Discount of cart isn't bigger than costs, so nothing here overflows
+	counted := money.Money{Currency: cartOff.Currency}
+	cumulative := money.Money{Currency: cartOff.Currency}
+
+	for i := range bases {
+		cumulative, _ = cumulative.Add(bases[i])
+		part, _ := cartOff.Share(cumulative.Amount, costs.Amount)
+		share, _ := part.Sub(counted)
+
+		bases[i], _ = bases[i].Sub(share)
+		counted = part
+	}
+
+	return bases
+}
@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTaxRates(t *testing.T) {
+	rates, err := ParseTaxRates("standard=20, reduced=5.5,zero=0")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"standard": 2000, "reduced": 550, "zero": 0}, rates)
+
+	_, err = ParseTaxRates("reduced=5")
+	assert.EqualError(t, err, "rate of tax category standard is required")
+
+	_, err = ParseTaxRates("standard=20%")
+	assert.EqualError(t, err, `rate of tax category standard should be percent from 0 to 100 with at most two decimals, got "20%"`)
+
+	_, err = ParseTaxRates("standard=5.555")
+	assert.Error(t, err)
+
+	_, err = ParseTaxRates("Standard")
+	assert.EqualError(t, err, `tax rate should look like category=percent, got "Standard"`)
+}
+
+func TestTaxPolicyApply(t *testing.T) {
+	// Test checks tax of positions and breakdown of total
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: amount, Currency: "USD"}
+	}
+
+	rates := map[string]int64{"standard": 2000, "food": 1000}
+	snapshot := map[string]database.Product{
+		"apple": {Name: "apple", TaxCategory: "food"},
+		"knife": {Name: "knife", TaxCategory: "standard"},
+		"gift":  {Name: "gift", TaxCategory: "gifts"},
+	}
+
+	testTable := []struct {
+		name           string
+		policy         TaxPolicy
+		prices         ClientCheck
+		expectedPrices ClientCheck
+		expectedError  error
+	}{
+		{
+			name:   "Tax is added",
+			policy: TaxPolicy{Rates: rates},
+			prices: ClientCheck{TotalSum: usd(1500), Positions: []ProductPosition{
+				{Product: "apple", PosCost: usd(500)},
+				{Product: "knife", PosCost: usd(1000)},
+			}},
+			expectedPrices: ClientCheck{Subtotal: usd(1500), Tax: usd(250), TotalSum: usd(1750), Positions: []ProductPosition{
+				{Product: "apple", PosCost: usd(500), TaxCategory: "food", TaxRate: 1000, Tax: usd(50), Discount: usd(0)},
+				{Product: "knife", PosCost: usd(1000), TaxCategory: "standard", TaxRate: 2000, Tax: usd(200), Discount: usd(0)},
+			}},
+		},
+
+		{
+			name:   "Tax is included",
+			policy: TaxPolicy{Rates: rates, Inclusive: true},
+			prices: ClientCheck{TotalSum: usd(1750), Positions: []ProductPosition{
+				{Product: "apple", PosCost: usd(550)},
+				{Product: "knife", PosCost: usd(1200)},
+			}},
+			expectedPrices: ClientCheck{Subtotal: usd(1500), Tax: usd(250), TotalSum: usd(1750), Positions: []ProductPosition{
+				{Product: "apple", PosCost: usd(550), TaxCategory: "food", TaxRate: 1000, Tax: usd(50), Discount: usd(0)},
+				{Product: "knife", PosCost: usd(1200), TaxCategory: "standard", TaxRate: 2000, Tax: usd(200), Discount: usd(0)},
+			}},
+		},
+
+		{
+			name:   "Tax of cost after discounts",
+			policy: TaxPolicy{Rates: rates},
+			// Knife has 1.00 off, then 1.50 off the whole cart is divided as 0.50 and 1.00
+			prices: ClientCheck{TotalSum: usd(1250), Discounts: []AppliedDiscount{{Amount: usd(150)}}, Positions: []ProductPosition{
+				{Product: "apple", PosCost: usd(500)},
+				{Product: "knife", PosCost: usd(1100), Discounts: []AppliedDiscount{{Amount: usd(100)}}},
+			}},
+			expectedPrices: ClientCheck{Subtotal: usd(1250), Tax: usd(225), TotalSum: usd(1475),
+				Discounts: []AppliedDiscount{{Amount: usd(150)}}, Positions: []ProductPosition{
+					{Product: "apple", PosCost: usd(500), TaxCategory: "food", TaxRate: 1000, Tax: usd(45), Discount: usd(50)},
+					{Product: "knife", PosCost: usd(1100), TaxCategory: "standard", TaxRate: 2000, Tax: usd(180), Discount: usd(200),
+						Discounts: []AppliedDiscount{{Amount: usd(100)}}},
+				}},
+		},
+
+		{
+			name:   "Rate of category isn't configured",
+			policy: TaxPolicy{Rates: rates},
+			prices: ClientCheck{TotalSum: usd(100), Positions: []ProductPosition{
+				{Product: "gift", PosCost: usd(100)},
+			}},
+			expectedError: errors.New("rate of tax category gifts of product gift isn't configured"),
+		},
+
+		{
+			name:   "Total with tax overflows",
+			policy: TaxPolicy{Rates: rates},
+			prices: ClientCheck{TotalSum: usd(math.MaxInt64), Positions: []ProductPosition{
+				{Product: "knife", PosCost: usd(math.MaxInt64)},
+			}},
+			expectedError: &ValidationError{Problems: []Problem{
+				{Field: "order", Reason: "total cost of order with tax is too big, try to buy less"},
+			}},
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			prices := testCase.prices
+			err := testCase.policy.apply(&prices, snapshot)
+
+			assert.Equal(t, testCase.expectedError, err)
+
+			if err == nil {
+				assert.Equal(t, testCase.expectedPrices, prices)
+			}
+		})
+	}
+}
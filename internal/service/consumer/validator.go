@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/delonce/apishop/internal/database"
 	"github.com/delonce/apishop/pkg/logging"
 )
 
@@ -12,20 +11,14 @@ import (
 // Checks opportunity of making recieved order
 
 type ValidateSubscriber struct {
-	name      string
-	prodDB    database.ProductDB
-	logger    *logging.Logger
-	reply     chan ClientCheck
-	subAmount int
+	name   string
+	logger *logging.Logger
 }
 
-func GetValidateSubscriber(name string, prodDB database.ProductDB,
-	logger *logging.Logger, clientForm chan ClientCheck) *ValidateSubscriber {
+func GetValidateSubscriber(name string, logger *logging.Logger) Consumer {
 	return &ValidateSubscriber{
 		name:   name,
-		prodDB: prodDB,
 		logger: logger,
-		reply:  clientForm,
 	}
 }
 
@@ -33,21 +26,36 @@ func (validator *ValidateSubscriber) GetName() string {
 	return validator.name
 }
 
-func (validator *ValidateSubscriber) Update(ctx context.Context, order map[string]int64) error {
+func (validator *ValidateSubscriber) Produces() []string {
+	return []string{VerdictArtifact}
+}
+
+func (validator *ValidateSubscriber) DependsOn() []string {
+	return []string{CatalogueArtifact, PricesArtifact}
+}
+
+func (validator *ValidateSubscriber) Update(ctx context.Context, purchase *Purchase) error {
+	// Products of order, Catalogue has checked that all of them exist
+	snapshot, err := purchase.Catalogue(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// Order that can't be priced has been rejected by Pricer
+	verdict, err := purchase.Prices(ctx)
+
+	if err != nil {
+		return err
+	}
+
 	errString := []string{}
 	isConf := true
 
-	for name, reqAmount := range order {
-		// Get some product from database
-		product, err := validator.prodDB.SelectProductByName(name)
-
-		// If error happened writes empty structure in channel
-		if err != nil {
-			validator.writeInChan(ClientCheck{})
-			return err
-		}
+	for _, name := range sortedNames(purchase.Order) {
+		reqAmount := purchase.Order[name]
+		product := snapshot[name]
 
-		// Creating channel for CheckSender
 		// Remembers all order that have more amount of some product than we have
 		if product.Amount < reqAmount {
 			isConf = false
@@ -57,23 +65,11 @@ func (validator *ValidateSubscriber) Update(ctx context.Context, order map[strin
 		}
 	}
 
-	// If all data pass test writes in channel true value
-	// validator.isConf <- true
-	validator.writeInChan(ClientCheck{
-		IsConf: isConf,
-		Error:  errString,
-	})
+	// Check Creator and Replier wait for verdict
+	verdict.IsConf = isConf
+	verdict.Error = errString
 
-	return nil
-}
+	purchase.Publish(VerdictArtifact, verdict)
 
-func (validator *ValidateSubscriber) writeInChan(check ClientCheck) {
-	for i := 0; i < validator.subAmount; i++ {
-		validator.reply <- check
-	}
-}
-
-func (validator *ValidateSubscriber) SetSubAmount(amount int) {
-	// Subcriber amount setter
-	validator.subAmount = amount
+	return nil
 }
@@ -2,27 +2,21 @@ package consumer
 
 import (
 	"context"
-	"errors"
 	"testing"
 
 	"github.com/delonce/apishop/internal/database"
-	mock_db "github.com/delonce/apishop/internal/database/mocks"
-	"github.com/golang/mock/gomock"
+	"github.com/delonce/apishop/pkg/money"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/sync/errgroup"
 )
 
 func TestValidatorUpdate(t *testing.T) {
 	// Test checks Update function in Validator
-	type mockBehavior func(s *mock_db.MockProductDB, name string, retProd *database.Product)
-
 	testTable := []struct {
 		name          string
 		inputOrder    map[string]int64
 		product       []*database.Product
 		expectedCheck ClientCheck
 		expectedError error
-		mockBehavior  mockBehavior
 	}{
 		{
 			name: "OK",
@@ -33,7 +27,7 @@ func TestValidatorUpdate(t *testing.T) {
 				{
 					ID:     1,
 					Name:   "apple",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 50,
 				},
 			},
@@ -42,9 +36,6 @@ func TestValidatorUpdate(t *testing.T) {
 				Error:  []string{},
 			},
 			expectedError: nil,
-			mockBehavior: func(s *mock_db.MockProductDB, name string, retProd *database.Product) {
-				s.EXPECT().SelectProductByName(name).Return(retProd, nil)
-			},
 		},
 
 		{
@@ -56,7 +47,7 @@ func TestValidatorUpdate(t *testing.T) {
 				{
 					ID:     1,
 					Name:   "apple",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 50,
 				},
 			},
@@ -65,29 +56,6 @@ func TestValidatorUpdate(t *testing.T) {
 				Error:  []string{"product: apple, requested_amount: 200, actually amount: 50"},
 			},
 			expectedError: nil,
-			mockBehavior: func(s *mock_db.MockProductDB, name string, retProd *database.Product) {
-				s.EXPECT().SelectProductByName(name).Return(retProd, nil)
-			},
-		},
-
-		{
-			name: "Error from database",
-			inputOrder: map[string]int64{
-				"apple": 10,
-			},
-			product: []*database.Product{
-				{
-					ID:     1,
-					Name:   "apple",
-					Cost:   200,
-					Amount: 50,
-				},
-			},
-			expectedCheck: ClientCheck{},
-			expectedError: errors.New("db mock error"),
-			mockBehavior: func(s *mock_db.MockProductDB, name string, retProd *database.Product) {
-				s.EXPECT().SelectProductByName(name).Return(nil, errors.New("db mock error"))
-			},
 		},
 
 		{
@@ -100,14 +68,14 @@ func TestValidatorUpdate(t *testing.T) {
 				{
 					ID:     1,
 					Name:   "apple",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 50,
 				},
 
 				{
 					ID:     2,
 					Name:   "melon",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 10,
 				},
 			},
@@ -116,9 +84,6 @@ func TestValidatorUpdate(t *testing.T) {
 				Error:  []string{"product: melon, requested_amount: 11, actually amount: 10"},
 			},
 			expectedError: nil,
-			mockBehavior: func(s *mock_db.MockProductDB, name string, retProd *database.Product) {
-				s.EXPECT().SelectProductByName(name).Return(retProd, nil)
-			},
 		},
 
 		{
@@ -131,14 +96,14 @@ func TestValidatorUpdate(t *testing.T) {
 				{
 					ID:     1,
 					Name:   "apple",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 50,
 				},
 
 				{
 					ID:     2,
 					Name:   "melon",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 10,
 				},
 			},
@@ -147,9 +112,6 @@ func TestValidatorUpdate(t *testing.T) {
 				Error:  []string{},
 			},
 			expectedError: nil,
-			mockBehavior: func(s *mock_db.MockProductDB, name string, retProd *database.Product) {
-				s.EXPECT().SelectProductByName(name).Return(retProd, nil)
-			},
 		},
 
 		{
@@ -162,14 +124,14 @@ func TestValidatorUpdate(t *testing.T) {
 				{
 					ID:     1,
 					Name:   "apple",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 50,
 				},
 
 				{
 					ID:     2,
 					Name:   "melon",
-					Cost:   200,
+					Cost:   money.Money{Amount: 200, Currency: "USD"},
 					Amount: 10,
 				},
 			},
@@ -178,51 +140,31 @@ func TestValidatorUpdate(t *testing.T) {
 				Error:  []string{"product: apple, requested_amount: 200, actually amount: 50", "product: melon, requested_amount: 200, actually amount: 10"},
 			},
 			expectedError: nil,
-			mockBehavior: func(s *mock_db.MockProductDB, name string, retProd *database.Product) {
-				s.EXPECT().SelectProductByName(name).Return(retProd, nil)
-			},
 		},
 	}
 
 	for _, testCase := range testTable {
 
 		t.Run(testCase.name, func(t *testing.T) {
-			c := gomock.NewController(t)
-			defer c.Finish()
-
-			prodDB := mock_db.NewMockProductDB(c)
-
-			// Define behavior
-			for _, product := range testCase.product {
-				testCase.mockBehavior(prodDB, product.Name, product)
-			}
-
-			resCheck := make(chan ClientCheck)
-
-			validator := GetValidateSubscriber("Test Val Sub", prodDB, nil, resCheck)
-			validator.SetSubAmount(1)
-
-			// Use errgroup to catch errors
-			g, ctx := errgroup.WithContext(context.TODO())
-			g.Go(func() error {
-				// Appeal to Consumer interface, do some subscriber's work
-				// Looking for an error
-				if err := validator.Update(ctx, testCase.inputOrder); err != nil {
-					return err
-				}
+			validator := GetValidateSubscriber("Test Val Sub", nil)
 
-				return nil
-			})
+			// Catalogue's and Pricer's parts
+			purchase := NewPurchase(Request{Order: testCase.inputOrder})
+			publishCatalogue(purchase, testCase.product)
+			purchase.Publish(PricesArtifact, ClientCheck{TotalSum: money.Money{Amount: 100, Currency: "USD"}})
 
-			// Waiting for creating json reply to client
-			result := <-resCheck
+			// Appeal to Consumer interface, do some subscriber's work
+			err := validator.Update(context.TODO(), purchase)
 
-			// Just return error to caller
-			err := g.Wait()
+			// Verdict isn't published if error happened
+			published, _ := purchase.Result(VerdictArtifact)
+			result, _ := published.(ClientCheck)
 
 			// Assert error
 			assert.Equal(t, testCase.expectedCheck.IsConf, result.IsConf)
 			assert.Equal(t, testCase.expectedCheck.Error, result.Error)
+			// Prices are kept in verdict
+			assert.Equal(t, money.Money{Amount: 100, Currency: "USD"}, result.TotalSum)
 			assert.Equal(t, testCase.expectedError, err)
 		})
 	}
@@ -0,0 +1,152 @@
+package subject
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/delonce/apishop/internal/service/consumer"
+)
+
+// Subscriber with its declarations, they are asked once at Subscribe
+type node struct {
+	sub       consumer.Consumer
+	produces  []string
+	dependsOn []string
+}
+
+// Order of launching subscribers built from their declarations
+type dependencyGraph struct {
+	// Names of subscribers that have to finish before subscriber starts
+	deps map[string][]string
+	// Results that somebody needs but nobody produces, Notify can't work while they exist
+	missing []string
+}
+
+func newNode(sub consumer.Consumer) node {
+	return node{
+		sub:       sub,
+		produces:  sub.Produces(),
+		dependsOn: sub.DependsOn(),
+	}
+}
+
+func buildGraph(nodes map[string]node) (*dependencyGraph, error) {
+	// Finds producer of every result
+	producers := map[string]string{}
+
+	for _, name := range sortedNodeNames(nodes) {
+		for _, artifact := range nodes[name].produces {
+			if producer, ok := producers[artifact]; ok {
+				return nil, fmt.Errorf("result %s is produced by %s and %s", artifact, producer, name)
+			}
+
+			producers[artifact] = name
+		}
+	}
+
+	graph := &dependencyGraph{
+		deps: make(map[string][]string, len(nodes)),
+	}
+
+	// Dependency on result turns into dependency on its producer
+	for _, name := range sortedNodeNames(nodes) {
+		graph.deps[name] = []string{}
+
+		for _, artifact := range nodes[name].dependsOn {
+			producer, ok := producers[artifact]
+
+			if !ok {
+				graph.missing = append(graph.missing, fmt.Sprintf("%s (needed by %s)", artifact, name))
+				continue
+			}
+
+			graph.deps[name] = append(graph.deps[name], producer)
+		}
+	}
+
+	if cycle := graph.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("subscribers have dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return graph, nil
+}
+
+func (graph *dependencyGraph) missingError() error {
+	if len(graph.missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("nobody produces results: %s", strings.Join(graph.missing, ", "))
+}
+
+func (graph *dependencyGraph) findCycle() []string {
+	// Depth-first search, subscriber in stack met again means cycle
+	const (
+		unvisited = iota
+		inStack
+		finished
+	)
+
+	state := map[string]int{}
+	stack := []string{}
+
+	var visit func(name string) []string
+
+	visit = func(name string) []string {
+		state[name] = inStack
+		stack = append(stack, name)
+
+		for _, dep := range graph.deps[name] {
+			switch state[dep] {
+			case inStack:
+				// Cycle is the part of stack from dep to the end
+				for i, stacked := range stack {
+					if stacked == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = finished
+
+		return nil
+	}
+
+	names := make([]string, 0, len(graph.deps))
+
+	for name := range graph.deps {
+		names = append(names, name)
+	}
+
+	// Same graph always reports the same cycle
+	sort.Strings(names)
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+func sortedNodeNames(nodes map[string]node) []string {
+	names := make([]string, 0, len(nodes))
+
+	for name := range nodes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,260 @@
+package subject
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delonce/apishop/internal/service/consumer"
+	mock_consumer "github.com/delonce/apishop/internal/service/consumer/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type declaration struct {
+	name      string
+	produces  []string
+	dependsOn []string
+}
+
+func newDeclaredConsumer(c *gomock.Controller, decl declaration) *mock_consumer.MockConsumer {
+	con := mock_consumer.NewMockConsumer(c)
+	con.EXPECT().GetName().Return(decl.name).AnyTimes()
+	con.EXPECT().Produces().Return(decl.produces).AnyTimes()
+	con.EXPECT().DependsOn().Return(decl.dependsOn).AnyTimes()
+
+	return con
+}
+
+func TestSubscribeGraph(t *testing.T) {
+	// Test checks that wrong declarations are rejected at Subscribe
+	testTable := []struct {
+		name          string
+		declarations  []declaration
+		expectedError error
+		expectedSubs  int
+	}{
+		{
+			name: "OK",
+			declarations: []declaration{
+				{name: "a", produces: []string{"x"}},
+				{name: "b", produces: []string{"y"}, dependsOn: []string{"x"}},
+				{name: "c", produces: []string{"z"}, dependsOn: []string{"x", "y"}},
+			},
+			expectedSubs: 3,
+		},
+
+		{
+			name: "Cycle",
+			declarations: []declaration{
+				{name: "a", produces: []string{"x"}, dependsOn: []string{"y"}},
+				{name: "b", produces: []string{"y"}, dependsOn: []string{"x"}},
+			},
+			expectedError: errors.New("can't subscribe b: subscribers have dependency cycle: a -> b -> a"),
+			expectedSubs:  1,
+		},
+
+		{
+			name: "Self dependency",
+			declarations: []declaration{
+				{name: "a", produces: []string{"x"}, dependsOn: []string{"x"}},
+			},
+			expectedError: errors.New("can't subscribe a: subscribers have dependency cycle: a -> a"),
+			expectedSubs:  0,
+		},
+
+		{
+			name: "Two producers",
+			declarations: []declaration{
+				{name: "a", produces: []string{"x"}},
+				{name: "b", produces: []string{"x"}},
+			},
+			expectedError: errors.New("can't subscribe b: result x is produced by a and b"),
+			expectedSubs:  1,
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			subject := GetPurchaseSubj(context.Background(), nil, nil)
+
+			var err error
+
+			for _, decl := range testCase.declarations {
+				if err = subject.Subscribe(newDeclaredConsumer(c, decl)); err != nil {
+					break
+				}
+			}
+
+			// Rejected subscriber isn't added
+			if testCase.expectedError == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.expectedError.Error())
+			}
+			assert.Equal(t, testCase.expectedSubs, subject.GetSubAmount())
+		})
+	}
+}
+
+func TestNotifyMissingProducer(t *testing.T) {
+	// Subscriber can wait for producer that comes later, but Notify isn't launched without it
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	subject := GetPurchaseSubj(context.Background(), nil, nil)
+	assert.Nil(t, subject.Subscribe(newDeclaredConsumer(c, declaration{
+		name: "Replier", produces: []string{consumer.ReplyArtifact}, dependsOn: []string{consumer.VerdictArtifact},
+	})))
+
+	rawBytes, err := subject.Notify(consumer.Request{Order: map[string]int64{"apple": 1}})
+
+	assert.EqualError(t, err, "nobody produces results: verdict (needed by Replier)")
+	assert.Nil(t, rawBytes)
+}
+
+func TestNotifyOrder(t *testing.T) {
+	// Independent subscribers run at the same time, dependent one starts after them
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	var mu sync.Mutex
+	events := []string{}
+
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	// Both independent subscribers have to be started before any of them finishes
+	bothStarted := make(chan struct{})
+	var startedWg sync.WaitGroup
+	startedWg.Add(2)
+
+	go func() {
+		startedWg.Wait()
+		close(bothStarted)
+	}()
+
+	independent := func(name, artifact string) *mock_consumer.MockConsumer {
+		con := newDeclaredConsumer(c, declaration{name: name, produces: []string{artifact}})
+		con.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, purchase *consumer.Purchase) error {
+			startedWg.Done()
+
+			select {
+			case <-bothStarted:
+			case <-time.After(time.Second):
+				return errors.New(name + " was launched sequentially")
+			}
+
+			record(name)
+			purchase.Publish(artifact, true)
+			return nil
+		})
+
+		return con
+	}
+
+	replier := newDeclaredConsumer(c, declaration{
+		name: "Replier", produces: []string{consumer.ReplyArtifact}, dependsOn: []string{"x", "y"},
+	})
+	replier.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, purchase *consumer.Purchase) error {
+		// Dependencies are published before launch, so results don't need to be awaited
+		_, okX := purchase.Result("x")
+		_, okY := purchase.Result("y")
+		assert.True(t, okX && okY)
+
+		record("Replier")
+		purchase.Publish(consumer.ReplyArtifact, []byte("reply"))
+		return nil
+	})
+
+	subject := GetPurchaseSubj(context.Background(), nil, nil)
+
+	// Dependent subscriber is subscribed first, order of subscribing doesn't matter
+	assert.Nil(t, subject.Subscribe(replier))
+	assert.Nil(t, subject.Subscribe(independent("a", "x")))
+	assert.Nil(t, subject.Subscribe(independent("b", "y")))
+
+	rawBytes, err := subject.Notify(consumer.Request{Order: map[string]int64{"apple": 1}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("reply"), rawBytes)
+	assert.Len(t, events, 3)
+	assert.Equal(t, "Replier", events[2])
+}
+
+func TestReady(t *testing.T) {
+	testTable := []struct {
+		name          string
+		declarations  []declaration
+		drain         bool
+		expectedError string
+	}{
+		{
+			name: "OK",
+			declarations: []declaration{
+				{name: "Validator", produces: []string{consumer.VerdictArtifact}},
+				{name: "Replier", produces: []string{consumer.ReplyArtifact}, dependsOn: []string{consumer.VerdictArtifact}},
+			},
+		},
+
+		{
+			name: "Missing producer",
+			declarations: []declaration{
+				{name: "Replier", produces: []string{consumer.ReplyArtifact}, dependsOn: []string{consumer.VerdictArtifact}},
+			},
+			expectedError: "nobody produces results: verdict (needed by Replier)",
+		},
+
+		{
+			name: "Without reply",
+			declarations: []declaration{
+				{name: "Validator", produces: []string{consumer.VerdictArtifact}},
+			},
+			expectedError: "nobody produces result reply",
+		},
+
+		{
+			name: "Draining",
+			declarations: []declaration{
+				{name: "Replier", produces: []string{consumer.ReplyArtifact}},
+			},
+			drain:         true,
+			expectedError: ErrSubjectClosed.Error(),
+		},
+	}
+
+	for _, testCase := range testTable {
+
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			subject := GetPurchaseSubj(context.Background(), nil, nil)
+
+			for _, decl := range testCase.declarations {
+				assert.Nil(t, subject.Subscribe(newDeclaredConsumer(c, decl)))
+			}
+
+			if testCase.drain {
+				assert.Nil(t, subject.Drain(context.Background()))
+			}
+
+			err := subject.Ready()
+
+			if testCase.expectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.expectedError)
+			}
+		})
+	}
+}
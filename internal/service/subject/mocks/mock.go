@@ -5,6 +5,7 @@
 package mock_subject
 
 import (
+	context "context"
 	reflect "reflect"
 
 	consumer "github.com/delonce/apishop/internal/service/consumer"
@@ -34,6 +35,20 @@ func (m *MockSubject) EXPECT() *MockSubjectMockRecorder {
 	return m.recorder
 }
 
+// Drain mocks base method.
+func (m *MockSubject) Drain(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Drain", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Drain indicates an expected call of Drain.
+func (mr *MockSubjectMockRecorder) Drain(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockSubject)(nil).Drain), ctx)
+}
+
 // GetSubAmount mocks base method.
 func (m *MockSubject) GetSubAmount() int {
 	m.ctrl.T.Helper()
@@ -49,24 +64,40 @@ func (mr *MockSubjectMockRecorder) GetSubAmount() *gomock.Call {
 }
 
 // Notify mocks base method.
-func (m *MockSubject) Notify(order map[string]int64) ([]byte, error) {
+func (m *MockSubject) Notify(request consumer.Request) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Notify", order)
+	ret := m.ctrl.Call(m, "Notify", request)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Notify indicates an expected call of Notify.
-func (mr *MockSubjectMockRecorder) Notify(order interface{}) *gomock.Call {
+func (mr *MockSubjectMockRecorder) Notify(request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockSubject)(nil).Notify), order)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockSubject)(nil).Notify), request)
+}
+
+// Ready mocks base method.
+func (m *MockSubject) Ready() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockSubjectMockRecorder) Ready() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockSubject)(nil).Ready))
 }
 
 // Subscribe mocks base method.
-func (m *MockSubject) Subscribe(arg0 consumer.Consumer) {
+func (m *MockSubject) Subscribe(arg0 consumer.Consumer) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Subscribe", arg0)
+	ret := m.ctrl.Call(m, "Subscribe", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Subscribe indicates an expected call of Subscribe.
@@ -0,0 +1,108 @@
+package subject
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/delonce/apishop/internal/database"
+	mock_db "github.com/delonce/apishop/internal/database/mocks"
+	"github.com/delonce/apishop/internal/service/consumer"
+	"github.com/delonce/apishop/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentPurchasesDontCross(t *testing.T) {
+	// Test launches many queries with real subscribers at the same time
+	// Every query has to get reply made from its own order (run it with -race)
+	const purchaseAmount = 100
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	prodDB := mock_db.NewMockProductDB(c)
+
+	// Product "product-N" has id N, so check id and positions show which order was processed
+	productID := func(name string) int64 {
+		id, _ := strconv.ParseInt(strings.TrimPrefix(name, "product-"), 10, 64)
+		return id
+	}
+
+	// Catalogue reads the whole order once, nobody else asks database for products
+	prodDB.EXPECT().SelectProductsByNames(gomock.Any()).DoAndReturn(func(names []string) ([]database.Product, error) {
+		products := []database.Product{}
+
+		for _, name := range names {
+			products = append(products, database.Product{ID: productID(name), Name: name, Cost: money.Money{Amount: 3, Currency: "USD"}, Amount: purchaseAmount,
+				TaxCategory: database.DefaultTaxCategory})
+		}
+
+		return products, nil
+	}).Times(purchaseAmount)
+	prodDB.EXPECT().SelectActiveDiscounts("", gomock.Any()).Return([]database.Discount{}, nil).Times(purchaseAmount)
+	prodDB.EXPECT().Begin().DoAndReturn(func() (database.ProductTx, error) {
+		// Every purchase has its own transaction
+		tx := mock_db.NewMockProductTx(c)
+		checkID := int64(0)
+
+		tx.EXPECT().ReserveProducts(gomock.Any()).DoAndReturn(func(order map[string]int64) error {
+			for name := range order {
+				checkID = productID(name)
+			}
+
+			return nil
+		})
+		tx.EXPECT().InsertCheck(gomock.Any()).DoAndReturn(func(purchCheck database.Check) (int64, error) {
+			return checkID, nil
+		})
+		tx.EXPECT().InsertProductFromCheck(gomock.Any()).DoAndReturn(func(position database.Order) error {
+			// Id of product is taken from snapshot
+			assert.Equal(t, checkID, position.ProductID)
+			return nil
+		})
+		tx.EXPECT().Commit().Return(nil)
+
+		return tx, nil
+	}).Times(purchaseAmount)
+
+	subject := GetPurchaseSubj(context.Background(), nil, nil)
+	assert.Nil(t, subject.Subscribe(consumer.GetCheckCreator("Check Creator", prodDB, nil, nil)))
+	assert.Nil(t, subject.Subscribe(consumer.GetPricer("Pricer", prodDB, consumer.TaxPolicy{Rates: map[string]int64{database.DefaultTaxCategory: 0}}, nil)))
+	assert.Nil(t, subject.Subscribe(consumer.GetValidateSubscriber("Validator", nil)))
+	assert.Nil(t, subject.Subscribe(consumer.GetReplier("Replier", nil)))
+	assert.Nil(t, subject.Subscribe(consumer.GetCatalogue("Catalogue", prodDB, nil)))
+
+	var wg sync.WaitGroup
+
+	for i := 1; i <= purchaseAmount; i++ {
+		wg.Add(1)
+
+		go func(id int64) {
+			defer wg.Done()
+
+			productName := fmt.Sprintf("product-%d", id)
+			rawBytes, err := subject.Notify(consumer.Request{Order: map[string]int64{productName: id}})
+
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			reply := consumer.ClientCheck{}
+			assert.Nil(t, json.Unmarshal(rawBytes, &reply))
+
+			// Reply contains only this order
+			assert.Equal(t, id, reply.CheckID)
+			assert.Equal(t, money.Money{Amount: 3 * id, Currency: "USD"}, reply.TotalSum)
+			assert.Equal(t, []consumer.ProductPosition{{Product: productName, PosCost: money.Money{Amount: 3 * id, Currency: "USD"}, ReqAmount: id,
+				TaxCategory: database.DefaultTaxCategory, Tax: money.Money{Currency: "USD"},
+				Discount: money.Money{Currency: "USD"}}}, reply.Positions)
+		}(int64(i))
+	}
+
+	wg.Wait()
+}
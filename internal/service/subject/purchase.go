@@ -3,67 +3,239 @@ package subject
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/delonce/apishop/internal/service/consumer"
 	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/metrics"
 	"golang.org/x/sync/errgroup"
 )
 
+// Notify was called after Drain, application is stopping
+var ErrSubjectClosed = errors.New("subject is closed")
+
 type PurchaseSubject struct {
-	ctx             context.Context
-	logger          *logging.Logger
-	consumers       map[string]consumer.Consumer
-	jsonClientReply chan []byte
+	ctx    context.Context
+	logger *logging.Logger
+
+	// Subscribers and their graph are replaced as a whole, so Notify can use them without lock
+	nodesMu sync.RWMutex
+	nodes   map[string]node
+	graph   *dependencyGraph
+
+	// Launched Notify calls, Drain waits for them
+	inFlight sync.WaitGroup
+	closeMu  sync.RWMutex
+	closed   bool
+
+	updateDuration *metrics.HistogramVec
+	updateErrors   *metrics.CounterVec
 }
 
-func GetPurchaseSubj(ctx context.Context, logger *logging.Logger, jsChan chan []byte) Subject {
+func GetPurchaseSubj(ctx context.Context, logger *logging.Logger, registry *metrics.Registry) Subject {
 	return &PurchaseSubject{
-		ctx:             ctx,
-		logger:          logger,
-		consumers:       make(map[string]consumer.Consumer),
-		jsonClientReply: jsChan,
+		ctx:    ctx,
+		logger: logger,
+		nodes:  make(map[string]node),
+		graph:  &dependencyGraph{},
+
+		updateDuration: registry.Histogram("apishop_subscriber_update_duration_seconds",
+			"Duration of subscriber's Update in purchase query.", metrics.DefBuckets, "subscriber"),
+		updateErrors: registry.Counter("apishop_subscriber_update_errors_total",
+			"Errors returned by subscriber's Update.", "subscriber"),
 	}
 }
 
-func (subject *PurchaseSubject) Subscribe(subscriber consumer.Consumer) {
-	subject.consumers[subscriber.GetName()] = subscriber
+func (subject *PurchaseSubject) Subscribe(subscriber consumer.Consumer) error {
+	name := subscriber.GetName()
+	subscriberNode := newNode(subscriber)
+
+	subject.nodesMu.Lock()
+	defer subject.nodesMu.Unlock()
+
+	nodes := subject.copyNodes()
+	nodes[name] = subscriberNode
+
+	// Wrong declarations are found here, not in the middle of purchase
+	graph, err := buildGraph(nodes)
+
+	if err != nil {
+		return fmt.Errorf("can't subscribe %s: %w", name, err)
+	}
+
+	subject.nodes = nodes
+	subject.graph = graph
+
+	return nil
 }
 
 func (subject *PurchaseSubject) Unsubscribe(subscriber consumer.Consumer) {
-	delete(subject.consumers, subscriber.GetName())
+	subject.nodesMu.Lock()
+	defer subject.nodesMu.Unlock()
+
+	nodes := subject.copyNodes()
+	delete(nodes, subscriber.GetName())
+
+	// Removing of subscriber can't make cycle, but can leave somebody without producer
+	graph, err := buildGraph(nodes)
+
+	if err != nil {
+		return
+	}
+
+	subject.nodes = nodes
+	subject.graph = graph
 }
 
-func (subject *PurchaseSubject) Notify(order map[string]int64) ([]byte, error) {
-	if len(subject.consumers) == 0 {
+func (subject *PurchaseSubject) Notify(request consumer.Request) ([]byte, error) {
+	subject.nodesMu.RLock()
+	nodes, graph := subject.nodes, subject.graph
+	subject.nodesMu.RUnlock()
+
+	if len(nodes) == 0 {
 		return nil, errors.New("subject doen't have any subscribers")
 	}
+
+	if err := graph.missingError(); err != nil {
+		return nil, err
+	}
+
+	// Closed flag and counter are changed together, so Drain can't miss started query
+	subject.closeMu.RLock()
+
+	if subject.closed {
+		subject.closeMu.RUnlock()
+		return nil, ErrSubjectClosed
+	}
+
+	subject.inFlight.Add(1)
+	subject.closeMu.RUnlock()
+
+	defer subject.inFlight.Done()
+
+	// Subscribers exchange results only through state of this query
+	purchase := consumer.NewPurchase(request)
+
+	// Closed channel means that subscriber has published its results
+	finished := make(map[string]chan struct{}, len(nodes))
+
+	for name := range nodes {
+		finished[name] = make(chan struct{})
+	}
+
 	// Use errgroup to catch errors
 	g, ctx := errgroup.WithContext(subject.ctx)
-	for _, sub := range subject.consumers {
-		callFunc := sub
-		// Concurrent launch some subscriber
+	for name, subscriberNode := range nodes {
+		name, callFunc, deps := name, subscriberNode.sub, graph.deps[name]
+		// Concurrent launch of independent subscribers, dependent ones wait for their producers
 		g.Go(func() error {
+			for _, dep := range deps {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-finished[dep]:
+				}
+			}
+
 			// Appeal to Consumer interface, do some subscriber's work
 			// Looking for an error
-			if err := callFunc.Update(ctx, order); err != nil {
+			start := time.Now()
+			err := callFunc.Update(ctx, purchase)
+			subject.updateDuration.Observe(time.Since(start).Seconds(), name)
+
+			if err != nil {
+				subject.updateErrors.Inc(name)
 				return err
 			}
 
+			close(finished[name])
+
 			return nil
 		})
 	}
 
-	// Waiting for creating json reply to client
-	jsonBytes := <-subject.jsonClientReply
-
 	// Just return error to caller
 	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 
+	// Waiting for creating json reply to client
+	jsonBytes, ok := purchase.Reply()
+
+	if !ok {
+		return nil, errors.New("subscribers haven't made reply")
+	}
+
 	return jsonBytes, nil
 }
 
+func (subject *PurchaseSubject) Ready() error {
+	subject.closeMu.RLock()
+	closed := subject.closed
+	subject.closeMu.RUnlock()
+
+	// Draining subject shouldn't get new queries from balancer
+	if closed {
+		return ErrSubjectClosed
+	}
+
+	subject.nodesMu.RLock()
+	nodes, graph := subject.nodes, subject.graph
+	subject.nodesMu.RUnlock()
+
+	if err := graph.missingError(); err != nil {
+		return err
+	}
+
+	// Without reply Notify can't answer to client
+	for _, subscriberNode := range nodes {
+		for _, artifact := range subscriberNode.produces {
+			if artifact == consumer.ReplyArtifact {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("nobody produces result %s", consumer.ReplyArtifact)
+}
+
 func (subject *PurchaseSubject) GetSubAmount() int {
-	return len(subject.consumers)
+	subject.nodesMu.RLock()
+	defer subject.nodesMu.RUnlock()
+
+	return len(subject.nodes)
+}
+
+func (subject *PurchaseSubject) Drain(ctx context.Context) error {
+	subject.closeMu.Lock()
+	subject.closed = true
+	subject.closeMu.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		subject.inFlight.Wait()
+		close(drained)
+	}()
+
+	// Launched queries are not cancelled here, caller decides what to do after deadline
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-drained:
+		return nil
+	}
+}
+
+func (subject *PurchaseSubject) copyNodes() map[string]node {
+	// Lock should be held by caller
+	nodes := make(map[string]node, len(subject.nodes)+1)
+
+	for name, subscriberNode := range subject.nodes {
+		nodes[name] = subscriberNode
+	}
+
+	return nodes
 }
@@ -5,11 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/delonce/apishop/internal/service/consumer"
 	mock_consumer "github.com/delonce/apishop/internal/service/consumer/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/sync/errgroup"
 )
 
 func TestEmptySubscribers(t *testing.T) {
@@ -40,13 +41,10 @@ func TestEmptySubscribers(t *testing.T) {
 			// Init net context
 			ctx := context.Background()
 
-			// Init channel between subject and mock consumer
-			jsChan := make(chan []byte)
+			// Init new subject without consumers
+			subject := GetPurchaseSubj(ctx, nil, nil)
 
-			// Init new subject and subcribe mock consumer
-			subject := GetPurchaseSubj(ctx, nil, jsChan)
-
-			rawBytes, err := subject.Notify(testCase.inputOrder)
+			rawBytes, err := subject.Notify(consumer.Request{Order: testCase.inputOrder})
 
 			// Asserts
 			assert.Equal(t, testCase.expectedError, err)
@@ -56,7 +54,7 @@ func TestEmptySubscribers(t *testing.T) {
 }
 
 func TestNotify(t *testing.T) {
-	type updateBehavior func(s *mock_consumer.MockConsumer, ctx context.Context, order map[string]int64)
+	type updateBehavior func(s *mock_consumer.MockConsumer, json []byte)
 	type nameBehavior func(s *mock_consumer.MockConsumer)
 
 	testTable := []struct {
@@ -77,11 +75,33 @@ func TestNotify(t *testing.T) {
 			internalConsumerJson: []byte("mock message for success purchase service"),
 			expectedError:        nil,
 			expectedReply:        []byte("mock message for success purchase service"),
-			updateBehavior: func(s *mock_consumer.MockConsumer, ctx context.Context, order map[string]int64) {
-				s.EXPECT().Update(ctx, order).Return(nil)
+			updateBehavior: func(s *mock_consumer.MockConsumer, json []byte) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, purchase *consumer.Purchase) error {
+					purchase.Publish(consumer.ReplyArtifact, json)
+					return nil
+				})
+			},
+			nameBehavior: func(s *mock_consumer.MockConsumer) {
+				s.EXPECT().GetName().Return("Mock Consumer")
+				s.EXPECT().Produces().Return([]string{consumer.ReplyArtifact})
+				s.EXPECT().DependsOn().Return(nil)
+			},
+		},
+
+		{
+			name: "Consumers without reply",
+			inputOrder: map[string]int64{
+				"apple": 10,
+			},
+			expectedError: errors.New("subscribers haven't made reply"),
+			expectedReply: nil,
+			updateBehavior: func(s *mock_consumer.MockConsumer, json []byte) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			nameBehavior: func(s *mock_consumer.MockConsumer) {
 				s.EXPECT().GetName().Return("Mock Consumer")
+				s.EXPECT().Produces().Return([]string{consumer.ReplyArtifact})
+				s.EXPECT().DependsOn().Return(nil)
 			},
 		},
 
@@ -94,11 +114,16 @@ func TestNotify(t *testing.T) {
 			internalConsumerJson: []byte("mock message for success purchase service"),
 			expectedError:        errors.New("some mock error"),
 			expectedReply:        nil,
-			updateBehavior: func(s *mock_consumer.MockConsumer, ctx context.Context, order map[string]int64) {
-				s.EXPECT().Update(ctx, order).Return(errors.New("some mock error"))
+			updateBehavior: func(s *mock_consumer.MockConsumer, json []byte) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, purchase *consumer.Purchase) error {
+					purchase.Publish(consumer.ReplyArtifact, json)
+					return errors.New("some mock error")
+				})
 			},
 			nameBehavior: func(s *mock_consumer.MockConsumer) {
 				s.EXPECT().GetName().Return("Mock Consumer")
+				s.EXPECT().Produces().Return([]string{consumer.ReplyArtifact})
+				s.EXPECT().DependsOn().Return(nil)
 			},
 		},
 	}
@@ -114,27 +139,17 @@ func TestNotify(t *testing.T) {
 			// Init net context
 			ctx := context.Background()
 
-			// Create context for Consumer's Update function
-			_, updctx := errgroup.WithContext(ctx)
-
-			// Init channel between subject and mock consumer
-			jsChan := make(chan []byte)
-
 			// Start nessesary behavior
+			// Expected that subject return same information that was published by consumer
+			// If error in Update function was nil
 			testCase.nameBehavior(con)
-			testCase.updateBehavior(con, updctx, testCase.inputOrder)
+			testCase.updateBehavior(con, testCase.internalConsumerJson)
 
 			// Init new subject and subcribe mock consumer
-			subject := GetPurchaseSubj(ctx, nil, jsChan)
-			subject.Subscribe(con)
-
-			// Writes in channel for subject, expected that subject return same information that was sended in channel
-			// If error in Update function was nil
-			go func() {
-				jsChan <- testCase.internalConsumerJson
-			}()
+			subject := GetPurchaseSubj(ctx, nil, nil)
+			assert.Nil(t, subject.Subscribe(con))
 
-			rawBytes, err := subject.Notify(testCase.inputOrder)
+			rawBytes, err := subject.Notify(consumer.Request{Order: testCase.inputOrder})
 
 			// Asserts
 			assert.Equal(t, testCase.expectedError, err)
@@ -156,6 +171,8 @@ func TestSomeSubs(t *testing.T) {
 			expectedReply: 3,
 			nameBehavior: func(s *mock_consumer.MockConsumer, id int) {
 				s.EXPECT().GetName().Return(fmt.Sprintf("id: %d", id))
+				s.EXPECT().Produces().Return(nil)
+				s.EXPECT().DependsOn().Return(nil)
 			},
 		},
 
@@ -164,6 +181,8 @@ func TestSomeSubs(t *testing.T) {
 			expectedReply: 9,
 			nameBehavior: func(s *mock_consumer.MockConsumer, id int) {
 				s.EXPECT().GetName().Return(fmt.Sprintf("id: %d", id))
+				s.EXPECT().Produces().Return(nil)
+				s.EXPECT().DependsOn().Return(nil)
 			},
 		},
 
@@ -172,6 +191,8 @@ func TestSomeSubs(t *testing.T) {
 			expectedReply: 30,
 			nameBehavior: func(s *mock_consumer.MockConsumer, id int) {
 				s.EXPECT().GetName().Return(fmt.Sprintf("id: %d", id))
+				s.EXPECT().Produces().Return(nil)
+				s.EXPECT().DependsOn().Return(nil)
 			},
 		},
 	}
@@ -186,7 +207,7 @@ func TestSomeSubs(t *testing.T) {
 			ctx := context.Background()
 
 			// Init new subject and subcribe mock consumer
-			subject := GetPurchaseSubj(ctx, nil, make(chan []byte))
+			subject := GetPurchaseSubj(ctx, nil, nil)
 
 			for i := 0; i < testCase.expectedReply; i++ {
 				// Init new Consumer
@@ -195,7 +216,7 @@ func TestSomeSubs(t *testing.T) {
 				// Start nessesary behavior
 				testCase.nameBehavior(con, i)
 
-				subject.Subscribe(con)
+				assert.Nil(t, subject.Subscribe(con))
 			}
 
 			subAmount := subject.GetSubAmount()
@@ -205,3 +226,53 @@ func TestSomeSubs(t *testing.T) {
 		})
 	}
 }
+
+func TestDrain(t *testing.T) {
+	// Test checks that Drain waits for launched queries and rejects new ones
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	ctx := context.Background()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	con := mock_consumer.NewMockConsumer(c)
+	con.EXPECT().GetName().Return("Slow Consumer")
+	con.EXPECT().Produces().Return([]string{consumer.ReplyArtifact})
+	con.EXPECT().DependsOn().Return(nil)
+	con.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, purchase *consumer.Purchase) error {
+		// Query is in progress until test releases it
+		close(started)
+		<-release
+		purchase.Publish(consumer.ReplyArtifact, []byte("mock message for success purchase service"))
+		return nil
+	})
+
+	subject := GetPurchaseSubj(ctx, nil, nil)
+	assert.Nil(t, subject.Subscribe(con))
+
+	notifyErr := make(chan error)
+
+	go func() {
+		_, err := subject.Notify(consumer.Request{Order: map[string]int64{"apple": 10}})
+		notifyErr <- err
+	}()
+
+	<-started
+
+	// Launched query isn't finished before deadline
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, subject.Drain(shortCtx))
+
+	// New queries are rejected while subject is draining
+	rawBytes, err := subject.Notify(consumer.Request{Order: map[string]int64{"melon": 1}})
+	assert.Equal(t, ErrSubjectClosed, err)
+	assert.Nil(t, rawBytes)
+
+	// Launched query is finished normally
+	close(release)
+	assert.Nil(t, <-notifyErr)
+	assert.Nil(t, subject.Drain(ctx))
+}
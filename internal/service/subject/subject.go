@@ -1,6 +1,8 @@
 package subject
 
 import (
+	"context"
+
 	"github.com/delonce/apishop/internal/service/consumer"
 )
 
@@ -8,8 +10,10 @@ import (
 
 // Main subject for processing purchase queries
 type Subject interface {
-	GetSubAmount() int                             // Return amount of current subscribers
-	Subscribe(consumer.Consumer)                   // Add new subscriber
-	Unsubscribe(consumer.Consumer)                 // Delete some subscriber
-	Notify(order map[string]int64) ([]byte, error) // Launch subscribers to process purchase query
+	GetSubAmount() int                               // Return amount of current subscribers
+	Subscribe(consumer.Consumer) error               // Add new subscriber, fails if it makes dependency cycle
+	Unsubscribe(consumer.Consumer)                   // Delete some subscriber
+	Notify(request consumer.Request) ([]byte, error) // Launch subscribers to process purchase query
+	Drain(ctx context.Context) error                 // Reject new queries and wait for launched ones
+	Ready() error                                    // Nil if Notify can process queries now
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/delonce/apishop/pkg/logging"
+	"github.com/delonce/apishop/pkg/metrics"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -27,3 +28,33 @@ func NewPostgresConnection(logger *logging.Logger, username, password, host, por
 
 	return connPool
 }
+
+func RegisterPoolMetrics(registry *metrics.Registry, pool *pgxpool.Pool) {
+	// Statistics are read from pool on every scrape
+	gauges := map[string]func(stat *pgxpool.Stat) float64{
+		"apishop_db_pool_acquired_conns":     func(stat *pgxpool.Stat) float64 { return float64(stat.AcquiredConns()) },
+		"apishop_db_pool_idle_conns":         func(stat *pgxpool.Stat) float64 { return float64(stat.IdleConns()) },
+		"apishop_db_pool_total_conns":        func(stat *pgxpool.Stat) float64 { return float64(stat.TotalConns()) },
+		"apishop_db_pool_max_conns":          func(stat *pgxpool.Stat) float64 { return float64(stat.MaxConns()) },
+		"apishop_db_pool_constructing_conns": func(stat *pgxpool.Stat) float64 { return float64(stat.ConstructingConns()) },
+	}
+
+	counters := map[string]func(stat *pgxpool.Stat) float64{
+		"apishop_db_pool_acquires_total":          func(stat *pgxpool.Stat) float64 { return float64(stat.AcquireCount()) },
+		"apishop_db_pool_empty_acquires_total":    func(stat *pgxpool.Stat) float64 { return float64(stat.EmptyAcquireCount()) },
+		"apishop_db_pool_canceled_acquires_total": func(stat *pgxpool.Stat) float64 { return float64(stat.CanceledAcquireCount()) },
+		"apishop_db_pool_acquire_duration_seconds_total": func(stat *pgxpool.Stat) float64 {
+			return stat.AcquireDuration().Seconds()
+		},
+	}
+
+	for name, value := range gauges {
+		value := value
+		registry.GaugeFunc(name, "Statistics of pgx connection pool.", func() float64 { return value(pool.Stat()) })
+	}
+
+	for name, value := range counters {
+		value := value
+		registry.CounterFunc(name, "Statistics of pgx connection pool.", func() float64 { return value(pool.Stat()) })
+	}
+}
@@ -0,0 +1,343 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Buckets for durations in seconds, the same as default ones of Prometheus client
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collection of metrics written in Prometheus text format
+// Nil Registry is allowed and returns nil metrics, all methods of nil metrics do nothing
+// So code under test can work without metrics
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	help() string
+	kind() string
+	writeSamples(w io.Writer, name string)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+func (registry *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	if registry == nil {
+		return nil
+	}
+
+	vec := &CounterVec{
+		helpText: help,
+		labels:   labels,
+		values:   make(map[string]*counterValue),
+	}
+
+	return registry.register(name, vec).(*CounterVec)
+}
+
+func (registry *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if registry == nil {
+		return nil
+	}
+
+	vec := &HistogramVec{
+		helpText: help,
+		labels:   labels,
+		buckets:  append([]float64{}, buckets...),
+		values:   make(map[string]*histogramValue),
+	}
+
+	sort.Float64s(vec.buckets)
+
+	return registry.register(name, vec).(*HistogramVec)
+}
+
+func (registry *Registry) GaugeFunc(name, help string, value func() float64) {
+	// Value is asked on every scrape, e.g. statistics of connection pool
+	if registry == nil {
+		return
+	}
+
+	registry.register(name, &funcMetric{helpText: help, kindText: "gauge", value: value})
+}
+
+func (registry *Registry) CounterFunc(name, help string, value func() float64) {
+	// The same as GaugeFunc, but value should only grow
+	if registry == nil {
+		return
+	}
+
+	registry.register(name, &funcMetric{helpText: help, kindText: "counter", value: value})
+}
+
+func (registry *Registry) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	registry.mu.Lock()
+
+	names := make([]string, 0, len(registry.metrics))
+
+	for name := range registry.metrics {
+		names = append(names, name)
+	}
+
+	metrics := registry.metrics
+	registry.mu.Unlock()
+
+	// Sorted output is easier to read and to test
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := metrics[name]
+
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, escapeHelp(m.help()))
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, m.kind())
+		m.writeSamples(&buf, name)
+	}
+
+	return buf.WriteTo(w)
+}
+
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+
+	if registry == nil {
+		return
+	}
+
+	registry.WriteTo(w)
+}
+
+func (registry *Registry) register(name string, m metric) metric {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	// Several parts of application can ask for the same metric
+	if registered, ok := registry.metrics[name]; ok {
+		if registered.kind() != m.kind() {
+			panic(fmt.Sprintf("metric %s is already registered as %s", name, registered.kind()))
+		}
+
+		return registered
+	}
+
+	registry.metrics[name] = m
+
+	return m
+}
+
+type CounterVec struct {
+	helpText string
+	labels   []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+func (vec *CounterVec) Inc(labelValues ...string) {
+	vec.Add(1, labelValues...)
+}
+
+func (vec *CounterVec) Add(delta float64, labelValues ...string) {
+	if vec == nil {
+		return
+	}
+
+	key := seriesKey(vec.labels, labelValues)
+
+	vec.mu.Lock()
+	defer vec.mu.Unlock()
+
+	value, ok := vec.values[key]
+
+	if !ok {
+		value = &counterValue{labelValues: append([]string{}, labelValues...)}
+		vec.values[key] = value
+	}
+
+	value.value += delta
+}
+
+func (vec *CounterVec) help() string { return vec.helpText }
+func (vec *CounterVec) kind() string { return "counter" }
+
+func (vec *CounterVec) writeSamples(w io.Writer, name string) {
+	vec.mu.Lock()
+	defer vec.mu.Unlock()
+
+	for _, key := range sortedKeys(vec.values) {
+		value := vec.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(vec.labels, value.labelValues), formatFloat(value.value))
+	}
+}
+
+type HistogramVec struct {
+	helpText string
+	labels   []string
+	buckets  []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	// Not cumulative, every observation is counted in its first fitting bucket
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (vec *HistogramVec) Observe(value float64, labelValues ...string) {
+	if vec == nil {
+		return
+	}
+
+	key := seriesKey(vec.labels, labelValues)
+
+	vec.mu.Lock()
+	defer vec.mu.Unlock()
+
+	hist, ok := vec.values[key]
+
+	if !ok {
+		hist = &histogramValue{
+			labelValues: append([]string{}, labelValues...),
+			counts:      make([]uint64, len(vec.buckets)),
+		}
+		vec.values[key] = hist
+	}
+
+	// Observations bigger than every bound are counted only in +Inf bucket
+	if i := sort.SearchFloat64s(vec.buckets, value); i < len(vec.buckets) {
+		hist.counts[i]++
+	}
+
+	hist.sum += value
+	hist.count++
+}
+
+func (vec *HistogramVec) help() string { return vec.helpText }
+func (vec *HistogramVec) kind() string { return "histogram" }
+
+func (vec *HistogramVec) writeSamples(w io.Writer, name string) {
+	vec.mu.Lock()
+	defer vec.mu.Unlock()
+
+	bucketLabels := append(append([]string{}, vec.labels...), "le")
+
+	for _, key := range sortedKeys(vec.values) {
+		hist := vec.values[key]
+
+		// Prometheus buckets are cumulative
+		var cumulative uint64
+
+		for i, bound := range vec.buckets {
+			cumulative += hist.counts[i]
+			labelValues := append(append([]string{}, hist.labelValues...), formatFloat(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(bucketLabels, labelValues), cumulative)
+		}
+
+		labelValues := append(append([]string{}, hist.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(bucketLabels, labelValues), hist.count)
+
+		labels := formatLabels(vec.labels, hist.labelValues)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, hist.count)
+	}
+}
+
+type funcMetric struct {
+	helpText string
+	kindText string
+	value    func() float64
+}
+
+func (m *funcMetric) help() string { return m.helpText }
+func (m *funcMetric) kind() string { return m.kindText }
+
+func (m *funcMetric) writeSamples(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(m.value()))
+}
+
+func seriesKey(labels, labelValues []string) string {
+	// Wrong amount of values is mistake in code, not in data
+	if len(labels) != len(labelValues) {
+		panic(fmt.Sprintf("metric has %d labels, got %d values", len(labels), len(labelValues)))
+	}
+
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatLabels(labels, labelValues []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(labels))
+
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label, escapeLabelValue(labelValues[i]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.Counter("test_requests_total", "Handled requests.", "route", "code")
+	requests.Inc("/products", "200")
+	requests.Inc("/products", "200")
+	requests.Add(3, `/"quoted"`, "500")
+
+	duration := registry.Histogram("test_duration_seconds", "Duration of request.", []float64{1, 0.1}, "route")
+	duration.Observe(0.05, "/")
+	duration.Observe(0.1, "/")
+	duration.Observe(4, "/")
+
+	registry.GaugeFunc("test_conns", "Open connections.", func() float64 { return 7 })
+
+	expected := `# HELP test_conns Open connections.
+# TYPE test_conns gauge
+test_conns 7
+# HELP test_duration_seconds Duration of request.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/",le="0.1"} 2
+test_duration_seconds_bucket{route="/",le="1"} 2
+test_duration_seconds_bucket{route="/",le="+Inf"} 3
+test_duration_seconds_sum{route="/"} 4.15
+test_duration_seconds_count{route="/"} 3
+# HELP test_requests_total Handled requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/\"quoted\"",code="500"} 3
+test_requests_total{route="/products",code="200"} 2
+`
+
+	var buf bytes.Buffer
+	_, err := registry.WriteTo(&buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, buf.String())
+
+	// The same output through http
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, contentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, expected, w.Body.String())
+}
+
+func TestRegisterTwice(t *testing.T) {
+	registry := NewRegistry()
+
+	// The same metric is shared
+	first := registry.Counter("test_total", "Test.")
+	second := registry.Counter("test_total", "Test.")
+	assert.Same(t, first, second)
+
+	// Metric with another type is mistake in code
+	assert.Panics(t, func() {
+		registry.Histogram("test_total", "Test.", DefBuckets)
+	})
+}
+
+func TestNilRegistry(t *testing.T) {
+	// Code under test can work without metrics
+	var registry *Registry
+
+	assert.NotPanics(t, func() {
+		registry.Counter("test_total", "Test.", "label").Inc("value")
+		registry.Histogram("test_seconds", "Test.", DefBuckets).Observe(1)
+		registry.GaugeFunc("test_gauge", "Test.", func() float64 { return 1 })
+	})
+}
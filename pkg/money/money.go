@@ -0,0 +1,136 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currencies don't match")
+	ErrOverflow         = errors.New("amount of money overflows")
+)
+
+// Digits after decimal point of ISO 4217 currencies, i.e. how many minor units are in one major unit
+// Only currencies that can be met in shop, add new ones here
+var exponents = map[string]int{
+	"AED": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "ILS": 2, "INR": 2, "JPY": 0,
+	"KRW": 0, "KWD": 3, "KZT": 2, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "RUB": 2,
+	"SEK": 2, "SGD": 2, "TRY": 2, "UAH": 2, "USD": 2, "ZAR": 2,
+}
+
+// Amount of money in minor units (cents, kopecks, ...) of ISO 4217 currency
+// Arithmetic never mixes currencies and never overflows silently
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func New(amount int64, currency string) (Money, error) {
+	if !IsCurrency(currency) {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func IsCurrency(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, other)
+	}
+
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	if (other.Amount < 0 && m.Amount > math.MaxInt64+other.Amount) ||
+		(other.Amount > 0 && m.Amount < math.MinInt64+other.Amount) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, other)
+	}
+
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(factor int64) (Money, error) {
+	if m.Amount == 0 || factor == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+
+	// Division catches overflow except MinInt64 * -1, because MinInt64 / -1 overflows back
+	product := m.Amount * factor
+
+	if product/factor != m.Amount || (factor == -1 && m.Amount == math.MinInt64) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrOverflow, m, factor)
+	}
+
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Part of amount rounded towards zero, percent should be from 0 to 100
+// Amount is divided first, so it never overflows
+func (m Money) Percent(percent int64) Money {
+	return Money{Amount: m.Amount/100*percent + m.Amount%100*percent/100, Currency: m.Currency}
+}
+
+// Amount * numerator / denominator rounded half away from zero, e.g. tax of cost with rate 2000/10000
+// Product is counted in big integers, so only result can overflow, denominator should be positive
+func (m Money) Share(numerator, denominator int64) (Money, error) {
+	quotient, remainder := new(big.Int).QuoRem(
+		new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator)), big.NewInt(denominator), new(big.Int))
+
+	// Remainder has sign of dividend
+	if new(big.Int).Abs(new(big.Int).Lsh(remainder, 1)).Cmp(big.NewInt(denominator)) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(remainder.Sign())))
+	}
+
+	if !quotient.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s * %d / %d", ErrOverflow, m, numerator, denominator)
+	}
+
+	return Money{Amount: quotient.Int64(), Currency: m.Currency}, nil
+}
+
+// Formats amount in major units, e.g. "12.34 USD", "-0.05 EUR", "100 JPY"
+func (m Money) String() string {
+	exponent := exponents[m.Currency]
+
+	sign := ""
+	// uint64 holds absolute value of MinInt64 too
+	abs := uint64(m.Amount)
+
+	if m.Amount < 0 {
+		sign = "-"
+		abs = uint64(-(m.Amount + 1)) + 1
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+
+	if exponent > 0 {
+		if len(digits) <= exponent {
+			digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+		}
+
+		digits = digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+	}
+
+	return fmt.Sprintf("%s%s %s", sign, digits, m.Currency)
+}
@@ -0,0 +1,143 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	m, err := New(1234, "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, Money{Amount: 1234, Currency: "USD"}, m)
+
+	_, err = New(1234, "usd")
+	assert.True(t, errors.Is(err, ErrUnknownCurrency))
+	assert.EqualError(t, err, `unknown currency: "usd"`)
+}
+
+func TestArithmetic(t *testing.T) {
+	testTable := []struct {
+		name          string
+		calc          func() (Money, error)
+		expected      Money
+		expectedError error
+	}{
+		{
+			name:     "Add",
+			calc:     func() (Money, error) { return Money{150, "EUR"}.Add(Money{-200, "EUR"}) },
+			expected: Money{-50, "EUR"},
+		},
+
+		{
+			name:          "Add different currencies",
+			calc:          func() (Money, error) { return Money{150, "EUR"}.Add(Money{200, "USD"}) },
+			expectedError: ErrCurrencyMismatch,
+		},
+
+		{
+			name:          "Add overflows",
+			calc:          func() (Money, error) { return Money{math.MaxInt64, "USD"}.Add(Money{1, "USD"}) },
+			expectedError: ErrOverflow,
+		},
+
+		{
+			name:          "Subtract overflows",
+			calc:          func() (Money, error) { return Money{math.MinInt64, "USD"}.Add(Money{-1, "USD"}) },
+			expectedError: ErrOverflow,
+		},
+
+		{
+			name:     "Sub",
+			calc:     func() (Money, error) { return Money{150, "EUR"}.Sub(Money{200, "EUR"}) },
+			expected: Money{-50, "EUR"},
+		},
+
+		{
+			name:          "Sub overflows",
+			calc:          func() (Money, error) { return Money{math.MinInt64, "USD"}.Sub(Money{1, "USD"}) },
+			expectedError: ErrOverflow,
+		},
+
+		{
+			name:          "Sub different currencies",
+			calc:          func() (Money, error) { return Money{150, "EUR"}.Sub(Money{100, "USD"}) },
+			expectedError: ErrCurrencyMismatch,
+		},
+
+		{
+			name:     "Mul",
+			calc:     func() (Money, error) { return Money{250, "USD"}.Mul(4) },
+			expected: Money{1000, "USD"},
+		},
+
+		{
+			name:     "Mul by zero",
+			calc:     func() (Money, error) { return Money{math.MaxInt64, "USD"}.Mul(0) },
+			expected: Money{0, "USD"},
+		},
+
+		{
+			name:          "Mul overflows",
+			calc:          func() (Money, error) { return Money{math.MaxInt64 / 2, "USD"}.Mul(3) },
+			expectedError: ErrOverflow,
+		},
+
+		{
+			name:          "MinInt64 by -1",
+			calc:          func() (Money, error) { return Money{math.MinInt64, "USD"}.Mul(-1) },
+			expectedError: ErrOverflow,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := testCase.calc()
+
+			assert.Equal(t, testCase.expected, result)
+			assert.True(t, errors.Is(err, testCase.expectedError))
+		})
+	}
+}
+
+func TestPercent(t *testing.T) {
+	assert.Equal(t, Money{125, "USD"}, Money{1250, "USD"}.Percent(10))
+	// Cents are rounded down
+	assert.Equal(t, Money{33, "USD"}, Money{333, "USD"}.Percent(10))
+	assert.Equal(t, Money{333, "USD"}, Money{333, "USD"}.Percent(100))
+	assert.Equal(t, Money{math.MaxInt64, "USD"}, Money{math.MaxInt64, "USD"}.Percent(100))
+	assert.Equal(t, Money{math.MaxInt64 / 2, "USD"}, Money{math.MaxInt64, "USD"}.Percent(50))
+}
+
+func TestShare(t *testing.T) {
+	share := func(m Money, numerator, denominator int64) Money {
+		result, err := m.Share(numerator, denominator)
+		assert.Nil(t, err)
+
+		return result
+	}
+
+	assert.Equal(t, Money{200, "USD"}, share(Money{1000, "USD"}, 2000, 10000))
+	// Half is rounded away from zero
+	assert.Equal(t, Money{2, "USD"}, share(Money{15, "USD"}, 1, 10))
+	assert.Equal(t, Money{1, "USD"}, share(Money{14, "USD"}, 1, 10))
+	assert.Equal(t, Money{-2, "USD"}, share(Money{-15, "USD"}, 1, 10))
+	// Tax included in 1.20 with rate 20%
+	assert.Equal(t, Money{100, "EUR"}, share(Money{120, "EUR"}, 10000, 12000))
+	// Intermediate product doesn't fit int64, result does
+	assert.Equal(t, Money{math.MaxInt64 / 3, "USD"}, share(Money{math.MaxInt64, "USD"}, 10000, 30000))
+
+	_, err := Money{math.MaxInt64, "USD"}.Share(3, 2)
+	assert.True(t, errors.Is(err, ErrOverflow))
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "12.34 USD", Money{1234, "USD"}.String())
+	assert.Equal(t, "-0.05 EUR", Money{-5, "EUR"}.String())
+	assert.Equal(t, "0.00 GBP", Money{0, "GBP"}.String())
+	assert.Equal(t, "100 JPY", Money{100, "JPY"}.String())
+	assert.Equal(t, "1.005 KWD", Money{1005, "KWD"}.String())
+	assert.Equal(t, "-92233720368547758.08 USD", Money{math.MinInt64, "USD"}.String())
+}
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket holds Requests tokens and is refilled completely during Period,
+// so client can send Requests queries at once and then one query every Period/Requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func ParseLimit(raw string) (Limit, error) {
+	// Limit looks like requests/period: "10/1s", "100/1m", unit alone means one of it ("10/s")
+	requests, period, found := strings.Cut(strings.TrimSpace(raw), "/")
+
+	if !found {
+		return Limit{}, fmt.Errorf("limit should look like requests/period (e.g. 10/1s), got %q", raw)
+	}
+
+	amount, err := strconv.Atoi(requests)
+
+	if err != nil || amount < 1 {
+		return Limit{}, fmt.Errorf("requests of limit should be positive integer, got %q", requests)
+	}
+
+	if period != "" && strings.IndexAny(period[:1], "0123456789") < 0 {
+		period = "1" + period
+	}
+
+	duration, err := time.ParseDuration(period)
+
+	if err != nil || duration <= 0 {
+		return Limit{}, fmt.Errorf("period of limit should be positive duration (e.g. 1s, 1m), got %q", period)
+	}
+
+	// Token has to come back at least in a nanosecond
+	if duration < time.Duration(amount) {
+		return Limit{}, fmt.Errorf("limit %d/%s is too big", amount, duration)
+	}
+
+	return Limit{Requests: amount, Period: duration}, nil
+}
+
+func (limit Limit) String() string {
+	return fmt.Sprintf("%d/%s", limit.Requests, limit.Period)
+}
+
+// What limiter thinks about one query
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Size of bucket
+	Remaining  int           // Queries that can be sent right now
+	Reset      time.Duration // When bucket is full again
+	RetryAfter time.Duration // When next query is allowed, 0 if it is allowed now
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Token buckets of clients, one limiter is used for one route
+type Limiter struct {
+	limit Limit
+	// Time to get one token back
+	interval time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// Replaced in tests
+	now func() time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:    limit,
+		interval: limit.Period / time.Duration(limit.Requests),
+		buckets:  make(map[string]*bucket),
+		now:      time.Now,
+	}
+}
+
+// Takes token of client if there is one
+func (limiter *Limiter) Allow(client string) Decision {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	limiter.sweep(now)
+
+	clientBucket, ok := limiter.buckets[client]
+
+	if !ok {
+		clientBucket = &bucket{tokens: float64(limiter.limit.Requests), updated: now}
+		limiter.buckets[client] = clientBucket
+	}
+
+	limiter.refill(clientBucket, now)
+
+	decision := Decision{Limit: limiter.limit.Requests}
+
+	if clientBucket.tokens >= 1 {
+		clientBucket.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = limiter.timeFor(1 - clientBucket.tokens)
+	}
+
+	decision.Remaining = int(clientBucket.tokens)
+	decision.Reset = limiter.timeFor(float64(limiter.limit.Requests) - clientBucket.tokens)
+
+	return decision
+}
+
+func (limiter *Limiter) refill(clientBucket *bucket, now time.Time) {
+	elapsed := now.Sub(clientBucket.updated)
+
+	// Clock can go back, tokens aren't taken for it
+	if elapsed > 0 {
+		clientBucket.tokens = math.Min(float64(limiter.limit.Requests), clientBucket.tokens+float64(elapsed)/float64(limiter.interval))
+	}
+
+	clientBucket.updated = now
+}
+
+func (limiter *Limiter) timeFor(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens * float64(limiter.interval)))
+}
+
+func (limiter *Limiter) sweep(now time.Time) {
+	// Bucket that has been idle for the whole period is full, it is the same as absent one
+	// Otherwise every client that has ever come would stay in memory
+	if now.Sub(limiter.lastSweep) < limiter.limit.Period {
+		return
+	}
+
+	for client, clientBucket := range limiter.buckets {
+		if now.Sub(clientBucket.updated) >= limiter.limit.Period {
+			delete(limiter.buckets, client)
+		}
+	}
+
+	limiter.lastSweep = now
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	testTable := []struct {
+		raw           string
+		expected      Limit
+		expectedError string
+	}{
+		{raw: "10/1s", expected: Limit{Requests: 10, Period: time.Second}},
+		{raw: " 100/m", expected: Limit{Requests: 100, Period: time.Minute}},
+		{raw: "5/30s", expected: Limit{Requests: 5, Period: 30 * time.Second}},
+		{raw: "10", expectedError: `limit should look like requests/period (e.g. 10/1s), got "10"`},
+		{raw: "0/1s", expectedError: `requests of limit should be positive integer, got "0"`},
+		{raw: "10/week", expectedError: `period of limit should be positive duration (e.g. 1s, 1m), got "1week"`},
+		{raw: "2000/1us", expectedError: "limit 2000/1µs is too big"},
+		{raw: "10/0s", expectedError: `period of limit should be positive duration (e.g. 1s, 1m), got "0s"`},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.raw, func(t *testing.T) {
+			limit, err := ParseLimit(testCase.raw)
+
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, limit)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	// 3 queries at once, then one query every 2 seconds
+	limiter := NewLimiter(Limit{Requests: 3, Period: 6 * time.Second})
+	now := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		decision := limiter.Allow("ann")
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 3, decision.Limit)
+		assert.Equal(t, remaining, decision.Remaining)
+	}
+
+	decision := limiter.Allow("ann")
+	assert.Equal(t, Decision{Allowed: false, Limit: 3, Remaining: 0, Reset: 6 * time.Second, RetryAfter: 2 * time.Second}, decision)
+
+	// Other client has own bucket
+	assert.True(t, limiter.Allow("bob").Allowed)
+
+	// One token is back after 2 seconds
+	now = now.Add(time.Second)
+	assert.Equal(t, time.Second, limiter.Allow("ann").RetryAfter)
+
+	now = now.Add(time.Second)
+	decision = limiter.Allow("ann")
+	assert.Equal(t, Decision{Allowed: true, Limit: 3, Remaining: 0, Reset: 6 * time.Second}, decision)
+
+	// Bucket isn't filled over its size
+	now = now.Add(time.Hour)
+	assert.Equal(t, 2, limiter.Allow("ann").Remaining)
+}
+
+func TestLimiterForgetsIdleClients(t *testing.T) {
+	limiter := NewLimiter(Limit{Requests: 1, Period: time.Minute})
+	now := time.Date(2022, 12, 20, 10, 30, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("ann")
+	now = now.Add(30 * time.Second)
+	limiter.Allow("bob")
+
+	// Bucket of ann is full again, bob still waits for token
+	now = now.Add(40 * time.Second)
+	limiter.Allow("carl")
+
+	assert.Len(t, limiter.buckets, 2)
+	assert.NotContains(t, limiter.buckets, "ann")
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	// Tokens aren't given twice however many queries come at the same time (run it with -race)
+	limiter := NewLimiter(Limit{Requests: 50, Period: time.Hour})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if limiter.Allow("ann").Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 50, allowed)
+}
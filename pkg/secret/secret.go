@@ -0,0 +1,26 @@
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Random string of size bytes for tokens and keys, it is safe in urls and headers
+func New(size int) (string, error) {
+	randomBytes := make([]byte, size)
+
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// Storages keep only hash of secret, leaked table doesn't give access
+// Secret is long random string, so fast hash is enough, unlike passwords
+func Hash(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
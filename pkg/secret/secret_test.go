@@ -0,0 +1,24 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	first, err := New(32)
+	assert.Nil(t, err)
+
+	second, err := New(32)
+	assert.Nil(t, err)
+
+	// 32 bytes are 43 symbols of base64 without padding
+	assert.NotEqual(t, first, second)
+	assert.Len(t, first, 43)
+}
+
+func TestHash(t *testing.T) {
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", Hash("hello"))
+	assert.NotEqual(t, Hash("hello"), Hash("hello "))
+}